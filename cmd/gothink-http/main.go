@@ -3,22 +3,30 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/jsonstyle"
 	"github.com/rainmana/gothink/internal/middleware"
 	"github.com/rainmana/gothink/internal/models"
+	"github.com/rainmana/gothink/internal/sharing"
 	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/templates"
+	"github.com/rainmana/gothink/internal/tools"
+	"github.com/rainmana/gothink/internal/tracing"
 	"github.com/rainmana/gothink/internal/types"
+	"github.com/rainmana/gothink/internal/webhooks"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,6 +42,22 @@ func main() {
 		cfg.Port = port
 	}
 
+	if err := jsonstyle.Validate(cfg.JSONFieldStyle); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if err := types.ValidateConfidenceScale(cfg.ConfidenceScale); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if err := config.ValidateThoughtLengthMode(cfg.ThoughtLengthMode); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if err := config.ValidateLogOutput(cfg.LogOutput); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if err := config.ValidateAPIKeys(cfg.APIKeys); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
 	// Create storage
 	store, err := storage.New(cfg)
 	if err != nil {
@@ -43,39 +67,59 @@ func main() {
 	// Create mental models loader
 	logger := logrus.New()
 	logger.SetOutput(os.Stderr)
-	if cfg.LogLevel == "debug" {
-		logger.SetLevel(logrus.DebugLevel)
+	if cfg.LogOutput == config.LogOutputStdout {
+		logger.SetOutput(os.Stdout)
 	}
+	applyLogLevel(logger, cfg.LogLevel)
 	modelsLoader := models.NewLoader(logger)
+	modelsLoader.Configure(cfg)
+	templatesLoader := templates.NewLoader(logger)
+
+	// Deliver session events to the configured webhook
+	if cfg.WebhookURL != "" {
+		dispatcher := webhooks.New(cfg, store, logger)
+		defer dispatcher.Close()
+	}
+
+	// Set up tracing (spans are only exported when enabled)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Create MCP server
-	s := server.NewMCPServer(
-		"GoThink MCP Server",
-		"1.0.0",
-		server.WithToolCapabilities(true),
-		server.WithResourceCapabilities(false, false),
-		server.WithPromptCapabilities(false),
-	)
+	s := tools.NewServer(cfg, store)
 
 	// Add all the thinking tools
-	addThinkingTools(s, store, modelsLoader, cfg)
-	addSessionTools(s, store)
+	tools.AddThinkingTools(s, store, modelsLoader, cfg)
+	tools.AddSessionTools(s, store, cfg)
+	tools.AddTemplateTools(s, store, modelsLoader, templatesLoader, cfg)
+	tools.AddAdminTools(s, store, modelsLoader, cfg)
 
 	// Create HTTP router
 	router := mux.NewRouter()
 
 	// Apply middleware
-	router.Use(middleware.CORS())
-	router.Use(middleware.Logging(logger))
+	applyMiddleware(router, cfg, s, logger)
 
 	// Health check endpoint
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	router.HandleFunc("/health", healthCheckHandler(cfg)).Methods("GET")
 
 	// Root endpoint with server info
-	router.HandleFunc("/", rootHandler).Methods("GET")
+	router.HandleFunc("/", rootHandler(s, modelsLoader, cfg)).Methods("GET")
+
+	// Metrics endpoint
+	router.Handle("/metrics", metricsRoute(cfg, store)).Methods("GET")
+
+	// Live session updates
+	router.HandleFunc("/sessions/{id}/events", sessionEventsHandler(store)).Methods("GET")
+
+	// Read-only session exports for signed share tokens
+	router.HandleFunc("/exports/{token}", sharedExportHandler(store, cfg)).Methods("GET")
 
 	// Create SSE server for MCP
-	sseServer := server.NewSSEServer(s)
+	sseServer := server.NewSSEServer(s, server.WithSSEContextFunc(tracing.ContextFromRequest))
 
 	// Mount SSE handler
 	router.PathPrefix("/sse").Handler(sseServer)
@@ -100,6 +144,9 @@ func main() {
 		logger.Info("Endpoints:")
 		logger.Infof("  - Health Check: http://%s/health", addr)
 		logger.Infof("  - SSE Endpoint: http://%s/sse", addr)
+		logger.Infof("  - Metrics:      http://%s/metrics", addr)
+		logger.Infof("  - Events:       http://%s/sessions/{id}/events", addr)
+		logger.Infof("  - Exports:      http://%s/exports/{token}", addr)
 		logger.Infof("  - Root Info:    http://%s/", addr)
 
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -107,6 +154,11 @@ func main() {
 		}
 	}()
 
+	// Reload configuration and mental models on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go reloadOnSignal(reload, logger, modelsLoader)
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -121,306 +173,274 @@ func main() {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		logger.Errorf("Server forced to shutdown: %v", err)
 	}
+	store.Close()
 
 	logger.Info("Server exited")
 }
 
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "healthy",
-		"service": "gothink-mcp-server",
-		"version": "1.0.0",
-		"time":    time.Now().UTC().Format(time.RFC3339),
-	})
+// applyMiddleware installs the middleware every route is served through
+func applyMiddleware(router *mux.Router, cfg *config.Config, s *server.MCPServer, logger *logrus.Logger) {
+	router.Use(middleware.CORS())
+	router.Use(middleware.Logging(logger))
+	router.Use(middleware.APIKeyAuth(cfg.APIKeyScopes(), toolScope(s), "/health", "/exports/"))
 }
 
-func rootHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"name":        "GoThink MCP Server",
-		"version":     "1.0.0",
-		"description": "Advanced MCP server combining systematic thinking, mental models, and debugging approaches",
-		"endpoints": map[string]string{
-			"health": "/health",
-			"sse":    "/sse",
-		},
-		"transport": "HTTP with Server-Sent Events (SSE)",
-		"protocol":  "Model Context Protocol (MCP)",
-	})
+// adminTools need the admin API key scope: operator tools and tools reading
+// across every session. The /metrics endpoint is held to the same scope.
+var adminTools = map[string]bool{
+	"purge_inactive":     true,
+	"delete_sessions":    true,
+	"reload_models":      true,
+	"model_applications": true,
+	"global_stats":       true,
+	"config_diff":        true,
+	"recent_sessions":    true,
+	"sessions_by_tag":    true,
 }
 
-func addThinkingTools(s *server.MCPServer, store *storage.Storage, modelsLoader *models.Loader, cfg *config.Config) {
-	// Sequential Thinking Tool
-	s.AddTool(
-		mcp.NewTool("sequential_thinking",
-			mcp.WithDescription("Perform sequential thinking operations with structured thought progression"),
-			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
-			mcp.WithString("thought", mcp.Required(), mcp.Description("Current thought content")),
-			mcp.WithNumber("thought_number", mcp.Required(), mcp.Description("Current thought number in sequence")),
-			mcp.WithNumber("total_thoughts", mcp.Required(), mcp.Description("Total number of thoughts planned")),
-			mcp.WithBoolean("next_thought_needed", mcp.Required(), mcp.Description("Whether another thought is needed")),
-		),
-		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			sessionID, _ := req.RequireString("session_id")
-			thought, _ := req.RequireString("thought")
-			thoughtNumber, _ := req.RequireInt("thought_number")
-			totalThoughts, _ := req.RequireInt("total_thoughts")
-			nextThoughtNeeded, _ := req.RequireBool("next_thought_needed")
-
-			result, err := handleSequentialThinking(store, sessionID, thought, thoughtNumber, totalThoughts, nextThoughtNeeded)
-			if err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
-			}
-
-			return mcp.NewToolResultText(result), nil
-		},
-	)
-
-	// Mental Model Tool
-	s.AddTool(
-		mcp.NewTool("mental_model",
-			mcp.WithDescription("Apply mental models to solve problems using structured thinking frameworks"),
-			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
-			mcp.WithString("model_name", mcp.Required(), mcp.Description("Name of the mental model to apply")),
-			mcp.WithString("problem", mcp.Required(), mcp.Description("Problem statement to analyze")),
-			mcp.WithArray("steps", mcp.Description("Steps to follow for the mental model")),
-		),
-		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			sessionID, _ := req.RequireString("session_id")
-			modelName, _ := req.RequireString("model_name")
-			problem, _ := req.RequireString("problem")
-			steps := req.GetStringSlice("steps", []string{})
-
-			// Load available mental models
-			availableModels, err := modelsLoader.LoadMentalModels(cfg.MentalModelsPath)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
-			}
+// crossSessionTools read every session unless given a session_id, and then
+// need the admin scope too
+var crossSessionTools = map[string]bool{
+	"pending_thoughts": true,
+}
 
-			// Check if the requested model exists
-			model, exists := availableModels[modelName]
-			if !exists {
-				// Return available models for reference
-				available := modelsLoader.GetAvailableModels(availableModels)
-				return mcp.NewToolResultError(fmt.Sprintf("Mental model '%s' not found. Available models: %v", modelName, available)), nil
+// toolScope returns the API key scope a tool call requires: admin for admin
+// tools and unscoped cross-session calls, read for tools annotated read-only
+// and write for everything else
+func toolScope(s *server.MCPServer) func(call middleware.ToolCall) string {
+	return func(call middleware.ToolCall) string {
+		if adminTools[call.Name] {
+			return middleware.ScopeAdmin
+		}
+		if sessionID, _ := call.Arguments["session_id"].(string); crossSessionTools[call.Name] && sessionID == "" {
+			return middleware.ScopeAdmin
+		}
+		if tool := s.GetTool(call.Name); tool != nil {
+			if readOnly := tool.Tool.Annotations.ReadOnlyHint; readOnly != nil && *readOnly {
+				return middleware.ScopeRead
 			}
+		}
+		return middleware.ScopeWrite
+	}
+}
 
-			// Use model steps if no custom steps provided
-			if len(steps) == 0 {
-				steps = model.Steps
-			}
+// metricsRoute serves metrics to admin keys only: they are global stats, as
+// returned by the admin global_stats tool
+func metricsRoute(cfg *config.Config, store *storage.Storage) http.Handler {
+	return middleware.RequireScope(cfg.APIKeyScopes(), middleware.ScopeAdmin)(metricsHandler(store))
+}
 
-			// Create mental model data
-			modelData := &types.MentalModelData{
-				ID:        fmt.Sprintf("%d-%d", time.Now().UnixNano(), len(steps)),
-				ModelName: modelName,
-				Problem:   problem,
-				Steps:     steps,
-				CreatedAt: time.Now(),
-			}
+func healthCheckHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "healthy",
+			"service": "gothink-mcp-server",
+			"version": cfg.ServerVersion,
+			"time":    time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
 
-			// Store the mental model
-			store.AddMentalModel(sessionID, modelData)
-
-			// Get session stats
-			stats, _ := store.GetSessionStats(sessionID)
-
-			// Create response
-			response := map[string]interface{}{
-				"status":   "success",
-				"model_id": modelData.ID,
-				"model_info": map[string]interface{}{
-					"name":        model.Name,
-					"description": model.Description,
-					"category":    model.Category,
-					"priority":    model.Priority,
-				},
-				"steps_used":     steps,
-				"has_steps":      len(steps) > 0,
-				"has_conclusion": false,
-				"session_context": map[string]interface{}{
-					"session_id":          sessionID,
-					"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
-				},
-			}
+// rootHandler describes the running server: registered tools, loaded model categories and transports
+func rootHandler(s *server.MCPServer, modelsLoader *models.Loader, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tools := make([]string, 0)
+		for name := range s.ListTools() {
+			tools = append(tools, name)
+		}
+		sort.Strings(tools)
 
-			result, _ := json.Marshal(response)
-			return mcp.NewToolResultText(string(result)), nil
-		},
-	)
-
-	// Debugging Approach Tool
-	s.AddTool(
-		mcp.NewTool("debugging_approach",
-			mcp.WithDescription("Apply systematic debugging approaches to identify and resolve issues"),
-			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
-			mcp.WithString("approach_name", mcp.Required(), mcp.Description("Name of the debugging approach")),
-			mcp.WithString("issue", mcp.Required(), mcp.Description("Issue description to debug")),
-			mcp.WithArray("steps", mcp.Description("Debugging steps to follow")),
-		),
-		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			sessionID, _ := req.RequireString("session_id")
-			_, _ = req.RequireString("approach_name")
-			_, _ = req.RequireString("issue")
-			steps := req.GetStringSlice("steps", []string{})
-
-			// Create response
-			response := map[string]interface{}{
-				"status":         "success",
-				"approach_id":    fmt.Sprintf("%d-%d", time.Now().UnixNano(), len(steps)),
-				"has_steps":      len(steps) > 0,
-				"has_findings":   false,
-				"has_resolution": false,
-				"session_context": map[string]interface{}{
-					"session_id": sessionID,
-				},
+		categories := make([]string, 0)
+		availableModels, err := modelsLoader.Models()
+		if err == nil {
+			for _, category := range modelsLoader.GetSortedCategories(availableModels) {
+				categories = append(categories, category.Category)
 			}
+		}
 
-			result, _ := json.Marshal(response)
-			return mcp.NewToolResultText(string(result)), nil
-		},
-	)
-
-	// List Available Mental Models Tool
-	s.AddTool(
-		mcp.NewTool("list_mental_models",
-			mcp.WithDescription("List all available mental models with their details"),
-		),
-		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			// Load available mental models
-			availableModels, err := modelsLoader.LoadMentalModels(cfg.MentalModelsPath)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
-			}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":        cfg.ServerName,
+			"version":     cfg.ServerVersion,
+			"description": "Advanced MCP server combining systematic thinking, mental models, and debugging approaches",
+			"endpoints": map[string]string{
+				"health":  "/health",
+				"sse":     "/sse",
+				"metrics": "/metrics",
+				"events":  "/sessions/{id}/events",
+			},
+			"tools":            tools,
+			"model_categories": categories,
+			"transports":       []string{"sse"},
+			"transport":        "HTTP with Server-Sent Events (SSE)",
+			"protocol":         "Model Context Protocol (MCP)",
+		})
+	}
+}
 
-			// Get models sorted by priority
-			modelsByPriority := modelsLoader.GetModelsByPriority(availableModels)
-			modelsByCategory := modelsLoader.GetModelsByCategory(availableModels)
-
-			// Create response
-			response := map[string]interface{}{
-				"status":             "success",
-				"total_models":       len(availableModels),
-				"models_by_priority": modelsByPriority,
-				"models_by_category": modelsByCategory,
-				"available_models":   modelsLoader.GetAvailableModels(availableModels),
-			}
+// metricsHandler exposes thought throughput and lock contention in
+// Prometheus text format
+func metricsHandler(store *storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := store.GetGlobalStats()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintln(w, "# HELP gothink_sessions Number of sessions held in storage")
+		fmt.Fprintln(w, "# TYPE gothink_sessions gauge")
+		fmt.Fprintf(w, "gothink_sessions %d\n", stats.TotalSessions)
+		fmt.Fprintln(w, "# HELP gothink_thoughts Number of thoughts held in storage")
+		fmt.Fprintln(w, "# TYPE gothink_thoughts gauge")
+		fmt.Fprintf(w, "gothink_thoughts %d\n", stats.TotalThoughts)
+		fmt.Fprintln(w, "# HELP gothink_thoughts_per_minute Thoughts added per minute over the sliding window")
+		fmt.Fprintln(w, "# TYPE gothink_thoughts_per_minute gauge")
+		fmt.Fprintf(w, "gothink_thoughts_per_minute %g\n", stats.ThoughtsPerMinute)
+		fmt.Fprintln(w, "# HELP gothink_lock_contention_total Session lock acquisitions that waited on another holder")
+		fmt.Fprintln(w, "# TYPE gothink_lock_contention_total counter")
+		fmt.Fprintf(w, "gothink_lock_contention_total %d\n", stats.LockContention)
+
+		sessionIDs := make([]string, 0, len(stats.SessionThoughtsPerMinute))
+		for sessionID := range stats.SessionThoughtsPerMinute {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+		sort.Strings(sessionIDs)
 
-			result, _ := json.Marshal(response)
-			return mcp.NewToolResultText(string(result)), nil
-		},
-	)
+		fmt.Fprintln(w, "# HELP gothink_session_thoughts_per_minute Thoughts added per minute per session")
+		fmt.Fprintln(w, "# TYPE gothink_session_thoughts_per_minute gauge")
+		for _, sessionID := range sessionIDs {
+			fmt.Fprintf(w, "gothink_session_thoughts_per_minute{session_id=%s} %g\n", strconv.Quote(sessionID), stats.SessionThoughtsPerMinute[sessionID])
+		}
+	}
 }
 
-func addSessionTools(s *server.MCPServer, store *storage.Storage) {
-	// Session Stats Tool
-	s.AddTool(
-		mcp.NewTool("session_stats",
-			mcp.WithDescription("Get statistics for a session"),
-			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
-		),
-		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			sessionID, _ := req.RequireString("session_id")
-
-			// Get session stats
-			stats, err := store.GetSessionStats(sessionID)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session stats: %v", err)), nil
-			}
-
-			// Create response
-			response := map[string]interface{}{
-				"session_id":         sessionID,
-				"created_at":         stats.CreatedAt.Format(time.RFC3339),
-				"last_accessed_at":   stats.LastAccessedAt.Format(time.RFC3339),
-				"thought_count":      stats.ThoughtCount,
-				"tools_used":         stats.ToolsUsed,
-				"total_operations":   stats.TotalOperations,
-				"is_active":          stats.IsActive,
-				"remaining_thoughts": stats.RemainingThoughts,
-				"stores":             stats.Stores,
-			}
+// sharedExportHandler serves the export of the session named by a signed
+// share token, rejecting tampered tokens with 403 and expired ones with 410
+func sharedExportHandler(store *storage.Storage, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ExportTokenSecret == "" {
+			http.Error(w, "Export links are disabled", http.StatusNotFound)
+			return
+		}
 
-			result, _ := json.Marshal(response)
-			return mcp.NewToolResultText(string(result)), nil
-		},
-	)
-
-	// Session Export Tool
-	s.AddTool(
-		mcp.NewTool("session_export",
-			mcp.WithDescription("Export all data for a session"),
-			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
-		),
-		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			sessionID, _ := req.RequireString("session_id")
-
-			// Export session data
-			exportData, err := store.ExportSession(sessionID)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to export session: %v", err)), nil
-			}
+		sessionID, err := sharing.Verify(cfg.ExportTokenSecret, mux.Vars(r)["token"], time.Now())
+		if errors.Is(err, sharing.ErrTokenExpired) {
+			http.Error(w, "Export token expired", http.StatusGone)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Invalid export token", http.StatusForbidden)
+			return
+		}
 
-			// Create response
-			response := map[string]interface{}{
-				"version":      "1.0.0",
-				"timestamp":    time.Now().Format(time.RFC3339),
-				"session_id":   sessionID,
-				"session_type": "hybrid",
-				"data":         exportData,
-				"metadata": map[string]interface{}{
-					"exported_at": time.Now().Format(time.RFC3339),
-					"version":     "0.1.0",
-				},
-			}
+		exportData, err := store.ExportSession(sessionID)
+		if err != nil {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
 
-			result, _ := json.Marshal(response)
-			return mcp.NewToolResultText(string(result)), nil
-		},
-	)
+		result, _ := jsonstyle.Marshal(tools.SessionExportResponse(sessionID, exportData), cfg.JSONFieldStyle)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(result)
+	}
 }
 
-// handleSequentialThinking processes sequential thinking requests
-func handleSequentialThinking(store *storage.Storage, sessionID, thought string, thoughtNumber, totalThoughts int, nextThoughtNeeded bool) (string, error) {
-	// Create thought data
-	thoughtData := &types.ThoughtData{
-		ID:                fmt.Sprintf("%d-%d", time.Now().UnixNano(), thoughtNumber),
-		Thought:           thought,
-		ThoughtNumber:     thoughtNumber,
-		TotalThoughts:     totalThoughts,
-		NextThoughtNeeded: nextThoughtNeeded,
-		CreatedAt:         time.Now(),
+// sessionEventsHandler streams a session's events as Server-Sent Events until
+// the client disconnects, the session is deleted or archived, or storage is
+// closed
+func sessionEventsHandler(store *storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID := mux.Vars(r)["id"]
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		events, cancel := store.Subscribe(sessionID)
+		defer cancel()
+
+		// The stream outlives the server's write timeout
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, ": subscribed to session %s\n\n", sessionID)
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Kind, data)
+				flusher.Flush()
+			}
+		}
 	}
+}
 
-	// Store the thought
-	if err := store.AddThought(sessionID, thoughtData); err != nil {
-		return "", err
+// reloadOnSignal reloads configuration each time a signal arrives
+func reloadOnSignal(signals <-chan os.Signal, logger *logrus.Logger, modelsLoader *models.Loader) {
+	for range signals {
+		reloadConfig(logger, modelsLoader)
 	}
+}
 
-	// Get session stats
-	stats, err := store.GetSessionStats(sessionID)
+// reloadConfig re-reads the configuration, applying the log level and
+// reloading the mental models cache. Sessions are left untouched, and a failed
+// reload keeps the current settings and models.
+func reloadConfig(logger *logrus.Logger, modelsLoader *models.Loader) {
+	logger.Info("Reloading configuration")
+
+	cfg, err := config.Load()
 	if err != nil {
-		return "", err
+		logger.Errorf("Failed to reload config, keeping current settings: %v", err)
+		return
 	}
 
-	// Create response
-	response := map[string]interface{}{
-		"status":     "success",
-		"thought_id": thoughtData.ID,
-		"session_context": map[string]interface{}{
-			"session_id":         sessionID,
-			"total_thoughts":     stats.ThoughtCount,
-			"remaining_thoughts": 100 - stats.ThoughtCount,
-		},
+	result, err := modelsLoader.Reload(cfg)
+	if err != nil {
+		logger.Errorf("Failed to reload mental models, keeping current set: %v", err)
+		return
 	}
-
-	result, err := json.Marshal(response)
+	logger.WithFields(logrus.Fields{
+		"added":   result.Added,
+		"removed": result.Removed,
+		"changed": result.Changed,
+		"total":   result.Total,
+	}).Info("Reloaded mental models")
+
+	level, err := config.ParseLogLevel(cfg.LogLevel)
 	if err != nil {
-		return "", err
+		logger.Warnf("%v, using %s", err, level)
 	}
+	if level != logger.GetLevel() {
+		logger.Infof("Log level changed from %s to %s", logger.GetLevel(), level)
+		logger.SetLevel(level)
+	}
+}
 
-	return string(result), nil
+// applyLogLevel sets the logger's level from configuration, falling back to
+// info with a warning when the level is not recognized
+func applyLogLevel(logger *logrus.Logger, value string) {
+	level, err := config.ParseLogLevel(value)
+	if err != nil {
+		logger.Warnf("%v, using %s", err, level)
+	}
+	logger.SetLevel(level)
 }
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/middleware"
+	"github.com/rainmana/gothink/internal/models"
+	"github.com/rainmana/gothink/internal/sharing"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/templates"
+	"github.com/rainmana/gothink/internal/tools"
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, store.AddThought("metrics-session", &types.ThoughtData{
+			Thought:       "thought",
+			ThoughtNumber: i,
+			TotalThoughts: 3,
+		}))
+	}
+
+	rec := httptest.NewRecorder()
+	metricsHandler(store)(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "gothink_thoughts 3")
+	assert.Contains(t, body, "gothink_thoughts_per_minute 0.6")
+	assert.Contains(t, body, "gothink_lock_contention_total 0")
+	assert.Contains(t, body, `gothink_session_thoughts_per_minute{session_id="metrics-session"} 0.6`)
+}
+
+func TestMetricsRoute_RequiresAdminKey(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIKeys = []config.APIKey{
+		{Key: "reader", Scopes: []string{middleware.ScopeRead}},
+		{Key: "admin", Scopes: []string{middleware.ScopeAdmin}},
+	}
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	router := mux.NewRouter()
+	applyMiddleware(router, cfg, server.NewMCPServer("Test", "1.0.0"), logrus.New())
+	router.Handle("/metrics", metricsRoute(cfg, store))
+
+	get := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, get("reader"))
+	assert.Equal(t, http.StatusOK, get("admin"))
+}
+
+func TestRootHandler_ListsRegisteredTools(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	modelsLoader := models.NewLoader(logger)
+	s := server.NewMCPServer("Test", "1.0.0")
+	tools.AddThinkingTools(s, store, modelsLoader, cfg)
+	tools.AddSessionTools(s, store, cfg)
+	tools.AddTemplateTools(s, store, modelsLoader, templates.NewLoader(logger), cfg)
+
+	rec := httptest.NewRecorder()
+	rootHandler(s, modelsLoader, cfg)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Tools           []string `json:"tools"`
+		ModelCategories []string `json:"model_categories"`
+		Transports      []string `json:"transports"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+
+	registered := make([]string, 0)
+	for name := range s.ListTools() {
+		registered = append(registered, name)
+	}
+	sort.Strings(registered)
+
+	assert.Equal(t, registered, body.Tools)
+	assert.Contains(t, body.Tools, "sequential_thinking")
+	assert.NotEmpty(t, body.ModelCategories)
+	assert.Equal(t, []string{"sse"}, body.Transports)
+}
+
+func TestReloadOnSignal_LoadsEditedModels(t *testing.T) {
+	modelsPath := filepath.Join(t.TempDir(), "mental_models.yaml")
+	writeModel := func(key string) {
+		content := "models:\n  " + key + ":\n    name: \"Reloaded\"\n    description: \"Added while running\"\n    steps:\n      - \"Step 1\"\n    category: \"custom\"\n"
+		require.NoError(t, os.WriteFile(modelsPath, []byte(content), 0644))
+	}
+	writeModel("original_model")
+
+	t.Setenv("GOTHINK_CONFIG", "")
+	t.Setenv("GOTHINK_MENTAL_MODELS_PATH", modelsPath)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	modelsLoader := models.NewLoader(logger)
+	modelsLoader.Configure(cfg)
+	availableModels, err := modelsLoader.Models()
+	require.NoError(t, err)
+	require.Contains(t, availableModels, "original_model")
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	t.Cleanup(func() {
+		signal.Stop(reload)
+		close(reload)
+	})
+	go reloadOnSignal(reload, logger, modelsLoader)
+
+	writeModel("reloaded_model")
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	assert.Eventually(t, func() bool {
+		availableModels, err := modelsLoader.Models()
+		if err != nil {
+			return false
+		}
+		_, exists := availableModels["reloaded_model"]
+		return exists
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// newEventsRouter serves session events through the middleware main installs
+func newEventsRouter(cfg *config.Config, store *storage.Storage) *mux.Router {
+	router := mux.NewRouter()
+	applyMiddleware(router, cfg, server.NewMCPServer("Test", "1.0.0"), logrus.New())
+	router.HandleFunc("/sessions/{id}/events", sessionEventsHandler(store))
+	return router
+}
+
+func TestSessionEventsHandler_StreamsThoughts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIKeys = []config.APIKey{{Key: "reader", Scopes: []string{middleware.ScopeRead}}}
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	srv := httptest.NewServer(newEventsRouter(cfg, store))
+	defer srv.Close()
+
+	_, err = store.CreateSession("live")
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/sessions/live/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", "reader")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// Headers are sent after subscribing, so the write below is delivered
+	require.Equal(t, 1, store.SubscriberCount("live"))
+	require.NoError(t, store.AddThought("live", &types.ThoughtData{Thought: "Streamed", ThoughtNumber: 1}))
+
+	reader := bufio.NewReader(resp.Body)
+	var eventLine, dataLine string
+	for dataLine == "" {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventLine = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			dataLine = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	assert.Equal(t, string(storage.EventThoughtAdded), eventLine)
+
+	var event storage.Event
+	require.NoError(t, json.Unmarshal([]byte(dataLine), &event))
+	assert.Equal(t, "live", event.SessionID)
+	require.NotNil(t, event.Thought)
+	assert.Equal(t, "Streamed", event.Thought.Thought)
+
+	// Disconnecting removes the subscription
+	resp.Body.Close()
+	assert.Eventually(t, func() bool { return store.SubscriberCount("live") == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestSessionEventsHandler_EndsWhenSessionArchived(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	srv := httptest.NewServer(newEventsRouter(cfg, store))
+	defer srv.Close()
+
+	_, err = store.CreateSession("ending")
+	require.NoError(t, err)
+
+	resp, err := http.Get(srv.URL + "/sessions/ending/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 1, store.SubscriberCount("ending"))
+
+	require.NoError(t, store.ArchiveSession("ending"))
+
+	// The stream ends after the final event instead of idling
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "event: "+string(storage.EventSessionArchived))
+}
+
+func TestSharedExportHandler(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ExportTokenSecret = "share-secret"
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.AddThought("shared", &types.ThoughtData{Thought: "Visible", ThoughtNumber: 1}))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/exports/{token}", sharedExportHandler(store, cfg))
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	get := func(token string) (int, string) {
+		resp, err := http.Get(srv.URL + "/exports/" + token)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	valid := sharing.NewToken(cfg.ExportTokenSecret, "shared", time.Now().Add(time.Hour))
+	status, body := get(valid)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Visible")
+
+	expired := sharing.NewToken(cfg.ExportTokenSecret, "shared", time.Now().Add(-time.Minute))
+	status, _ = get(expired)
+	assert.Equal(t, http.StatusGone, status)
+
+	tampered := valid[:len(valid)-1] + "0"
+	if strings.HasSuffix(valid, "0") {
+		tampered = valid[:len(valid)-1] + "1"
+	}
+	status, body = get(tampered)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotContains(t, body, "Visible")
+}
+
+func TestToolScope(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	s := tools.NewServer(cfg, store)
+	tools.AddThinkingTools(s, store, models.NewLoader(logrus.New()), cfg)
+	tools.AddSessionTools(s, store, cfg)
+	tools.AddAdminTools(s, store, models.NewLoader(logrus.New()), cfg)
+
+	toolScope := toolScope(s)
+	scope := func(name string) string { return toolScope(middleware.ToolCall{Name: name}) }
+	assert.Equal(t, middleware.ScopeRead, scope("session_stats"))
+	assert.Equal(t, middleware.ScopeRead, scope("get_thoughts"))
+	assert.Equal(t, middleware.ScopeWrite, scope("sequential_thinking"))
+	assert.Equal(t, middleware.ScopeWrite, scope("archive_session"))
+	assert.Equal(t, middleware.ScopeAdmin, scope("global_stats"))
+	assert.Equal(t, middleware.ScopeAdmin, scope("purge_inactive"))
+
+	// Tools reading across every session need admin, like /metrics
+	assert.Equal(t, middleware.ScopeAdmin, scope("recent_sessions"))
+	assert.Equal(t, middleware.ScopeAdmin, scope("sessions_by_tag"))
+	assert.Equal(t, middleware.ScopeAdmin, scope("pending_thoughts"))
+	assert.Equal(t, middleware.ScopeRead, toolScope(middleware.ToolCall{
+		Name:      "pending_thoughts",
+		Arguments: map[string]interface{}{"session_id": "mine"},
+	}))
+}
@@ -4,50 +4,309 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
+// BuildVersion is the version baked into the binary; override it at build time with
+// -ldflags "-X github.com/rainmana/gothink/internal/config.BuildVersion=<version>"
+var BuildVersion = "1.0.0"
+
 // Config represents the server configuration
 type Config struct {
 	// Server settings
-	Port         string        `json:"port" yaml:"port"`
-	Host         string        `json:"host" yaml:"host"`
-	ReadTimeout  time.Duration `json:"read_timeout" yaml:"read_timeout"`
-	WriteTimeout time.Duration `json:"write_timeout" yaml:"write_timeout"`
+	// ServerName and ServerVersion are advertised to MCP clients during initialization
+	ServerName    string        `json:"server_name" yaml:"server_name"`
+	ServerVersion string        `json:"server_version" yaml:"server_version"`
+	Port          string        `json:"port" yaml:"port"`
+	Host          string        `json:"host" yaml:"host"`
+	ReadTimeout   time.Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout  time.Duration `json:"write_timeout" yaml:"write_timeout"`
+
+	// APIKeys lists the keys accepted by the HTTP server and the scopes each
+	// grants: read, write (implying read) or admin (everything). Empty disables
+	// authentication.
+	APIKeys []APIKey `json:"api_keys" yaml:"api_keys"`
 
 	// Session settings
 	SessionTimeout        time.Duration `json:"session_timeout" yaml:"session_timeout"`
 	MaxThoughtsPerSession int           `json:"max_thoughts_per_session" yaml:"max_thoughts_per_session"`
+	// MaxTotalThoughts bounds the thoughts stored across all sessions; writes
+	// past it evict the least recently accessed inactive sessions and are
+	// rejected once none is left (0 disables the bound)
+	MaxTotalThoughts int `json:"max_total_thoughts" yaml:"max_total_thoughts"`
+	// ThoughtNumberBase is the number of the first thought in a sequence: 1
+	// (the default) for 1-indexed clients or 0 for 0-indexed ones. Numbering
+	// validation, repair and every server-assigned thought number follow it.
+	ThoughtNumberBase int `json:"thought_number_base" yaml:"thought_number_base"`
+	// GracePeriod keeps sessions idle past SessionTimeout before evicting them
+	GracePeriod time.Duration `json:"grace_period" yaml:"grace_period"`
+	// MaxSessionLifetime evicts sessions this long after creation regardless
+	// of activity (0 disables the limit)
+	MaxSessionLifetime time.Duration `json:"max_session_lifetime" yaml:"max_session_lifetime"`
+	// ReaperInterval is how often idle sessions are checked (0 disables the
+	// reaper, keeping sessions until they are deleted)
+	ReaperInterval time.Duration `json:"reaper_interval" yaml:"reaper_interval"`
+	// MaxMentalModelsPerSession caps mental model applications stored per
+	// session (0 disables the cap)
+	MaxMentalModelsPerSession int `json:"max_mental_models_per_session" yaml:"max_mental_models_per_session"`
+	// ModelCooldown rejects applying a mental model to the same problem in a
+	// session again within this window of its last application (0 disables it)
+	ModelCooldown time.Duration `json:"model_cooldown" yaml:"model_cooldown"`
+	// MaxCheckpointsPerSession caps the named snapshots kept per session
+	MaxCheckpointsPerSession int `json:"max_checkpoints_per_session" yaml:"max_checkpoints_per_session"`
+	// RecentSessionsLimit is the default number of sessions returned by recent_sessions
+	RecentSessionsLimit int `json:"recent_sessions_limit" yaml:"recent_sessions_limit"`
+	// SimilarityThreshold is the default trigram similarity (0-1) at which
+	// find_similar_thoughts treats two thoughts as near-duplicates
+	SimilarityThreshold float64 `json:"similarity_threshold" yaml:"similarity_threshold"`
+
+	// Argument size limits in characters (0 disables a limit)
+	MaxThoughtLength int `json:"max_thought_length" yaml:"max_thought_length"`
+	MaxProblemLength int `json:"max_problem_length" yaml:"max_problem_length"`
+	MaxIssueLength   int `json:"max_issue_length" yaml:"max_issue_length"`
+	MaxStepLength    int `json:"max_step_length" yaml:"max_step_length"`
+	// ThoughtLengthMode decides what happens to a thought longer than
+	// MaxThoughtLength: "reject" (default) fails the call, "truncate" stores
+	// the first MaxThoughtLength characters and flags the thought as truncated
+	ThoughtLengthMode string `json:"thought_length_mode" yaml:"thought_length_mode"`
+	// MaxStepsPerCall caps the entries of a tool call's steps argument,
+	// independently of the steps a model definition may list (0 disables it)
+	MaxStepsPerCall int `json:"max_steps_per_call" yaml:"max_steps_per_call"`
+
+	// Session metadata limits (0 disables a limit)
+	MaxMetadataEntries     int `json:"max_metadata_entries" yaml:"max_metadata_entries"`
+	MaxMetadataKeyLength   int `json:"max_metadata_key_length" yaml:"max_metadata_key_length"`
+	MaxMetadataValueLength int `json:"max_metadata_value_length" yaml:"max_metadata_value_length"`
+
+	// Session import limits (0 disables a limit): MaxImportBytes rejects
+	// larger payloads before they are parsed, and MaxImportEntities caps the
+	// thoughts and mental models one import may create
+	MaxImportBytes    int64 `json:"max_import_bytes" yaml:"max_import_bytes"`
+	MaxImportEntities int   `json:"max_import_entities" yaml:"max_import_entities"`
+
+	// Per-session collection caps (0 disables a cap). The overflow policies
+	// decide what a full collection does with a new entry: "reject" refuses
+	// it, "ring" drops the oldest entry to make room.
+	MaxToolsUsed      int    `json:"max_tools_used" yaml:"max_tools_used"`
+	ToolsUsedOverflow string `json:"tools_used_overflow" yaml:"tools_used_overflow"`
+	MaxAuditEntries   int    `json:"max_audit_entries" yaml:"max_audit_entries"`
+	AuditOverflow     string `json:"audit_overflow" yaml:"audit_overflow"`
+	MetadataOverflow  string `json:"metadata_overflow" yaml:"metadata_overflow"`
+
+	// IDStrategy selects how storage generates IDs: "timestamp", "uuid" or "ulid"
+	IDStrategy string `json:"id_strategy" yaml:"id_strategy"`
+	// SessionIDStyle is how IDs are minted for sessions the client did not
+	// name: "uuid" (default) or "words" for readable IDs like "brisk-falcon-4821"
+	SessionIDStyle string `json:"session_id_style" yaml:"session_id_style"`
+	// StorageShards partitions sessions across independently locked shards (1 disables sharding)
+	StorageShards int `json:"storage_shards" yaml:"storage_shards"`
 
 	// Persistence settings
 	EnablePersistence bool   `json:"enable_persistence" yaml:"enable_persistence"`
 	PersistencePath   string `json:"persistence_path" yaml:"persistence_path"`
 
+	// JSONFieldStyle names the fields of tool responses: "snake" (default) or "camel"
+	JSONFieldStyle string `json:"json_field_style" yaml:"json_field_style"`
+
+	// ConfidenceScale is how clients express confidence: "fraction" (0-1,
+	// the default) or "percent" (0-100). Values are stored as fractions.
+	ConfidenceScale string `json:"confidence_scale" yaml:"confidence_scale"`
+
 	// Logging settings
 	EnableDetailedLogging bool   `json:"enable_detailed_logging" yaml:"enable_detailed_logging"`
 	LogLevel              string `json:"log_level" yaml:"log_level"`
+	// LogOutput is where logs are written: "stderr" (the default) or
+	// "stdout". The stdio server always logs to stderr, as stdout carries
+	// its protocol stream.
+	LogOutput string `json:"log_output" yaml:"log_output"`
+	// OperationLogPath, when set, is a file the stdio server appends one JSONL
+	// record per tool call to; it can never be stdout
+	OperationLogPath string `json:"operation_log_path" yaml:"operation_log_path"`
 
 	// Mental models settings
 	MentalModelsPath string `json:"mental_models_path" yaml:"mental_models_path"`
+	// AllowedCategories restricts loaded mental models to these categories (empty allows all)
+	AllowedCategories []string `json:"allowed_categories" yaml:"allowed_categories"`
+	// DefaultCategory is assigned to custom models that omit a category
+	DefaultCategory string `json:"default_category" yaml:"default_category"`
+	// StrictModelValidation rejects custom models with a missing category instead of defaulting it
+	StrictModelValidation bool `json:"strict_model_validation" yaml:"strict_model_validation"`
+	// CaseInsensitiveNames matches model and approach names regardless of
+	// case (off by default)
+	CaseInsensitiveNames bool `json:"case_insensitive_names" yaml:"case_insensitive_names"`
+	// InheritCoreSteps lets a custom model that overrides a core model omit
+	// its steps and inherit the core model's instead of being rejected
+	InheritCoreSteps bool `json:"inherit_core_steps" yaml:"inherit_core_steps"`
+
+	// Session templates settings
+	SessionTemplatesPath string `json:"session_templates_path" yaml:"session_templates_path"`
+
+	// Tracing settings
+	// EnableTracing exports a span per tool call over OTLP/HTTP
+	EnableTracing bool `json:"enable_tracing" yaml:"enable_tracing"`
+	// OTLPEndpoint is the collector URL (empty uses the OTEL_EXPORTER_OTLP_* environment defaults)
+	OTLPEndpoint string `json:"otlp_endpoint" yaml:"otlp_endpoint"`
+
+	// EnableAdminTools registers operator tools such as purge_inactive
+	EnableAdminTools bool `json:"enable_admin_tools" yaml:"enable_admin_tools"`
+
+	// EndSubscriptionsWithSession closes a session's event subscriptions, after
+	// a final event, when the session is deleted or archived
+	EndSubscriptionsWithSession bool `json:"end_subscriptions_with_session" yaml:"end_subscriptions_with_session"`
+
+	// StrictToolArguments rejects tool calls passing arguments the tool does not declare
+	StrictToolArguments bool `json:"strict_tool_arguments" yaml:"strict_tool_arguments"`
+
+	// EnforceJSONContentType rejects HTTP write requests whose Content-Type is
+	// not application/json with 415; disable it for lenient local development
+	EnforceJSONContentType bool `json:"enforce_json_content_type" yaml:"enforce_json_content_type"`
+
+	// Export link settings
+	// ExportTokenSecret signs shareable read-only export tokens (empty disables export links)
+	ExportTokenSecret string `json:"export_token_secret" yaml:"export_token_secret"`
+	// ExportTokenMaxTTL is the default and longest lifetime of an export token
+	ExportTokenMaxTTL time.Duration `json:"export_token_max_ttl" yaml:"export_token_max_ttl"`
+
+	// Webhook settings
+	// WebhookURL receives a POST for each selected session event (empty disables webhooks)
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url"`
+	// WebhookEvents selects the events to deliver (empty delivers all webhook events)
+	WebhookEvents []string `json:"webhook_events" yaml:"webhook_events"`
+	// WebhookSecret signs payloads with HMAC-SHA256 in the X-GoThink-Signature header
+	WebhookSecret string `json:"webhook_secret" yaml:"webhook_secret"`
+	// WebhookMaxAttempts bounds delivery attempts per event, including the first
+	WebhookMaxAttempts int `json:"webhook_max_attempts" yaml:"webhook_max_attempts"`
+	// WebhookRetryBackoff is the delay before the first retry, doubling after each failure
+	WebhookRetryBackoff time.Duration `json:"webhook_retry_backoff" yaml:"webhook_retry_backoff"`
 
 	// Algorithm defaults
 	AlgorithmDefaults map[string]interface{} `json:"algorithm_defaults" yaml:"algorithm_defaults"`
 }
 
+// APIKey is a client key and the scopes it grants
+type APIKey struct {
+	Key    string   `json:"key" yaml:"key"`
+	Scopes []string `json:"scopes" yaml:"scopes"`
+}
+
+// Thought length modes selectable via Config.ThoughtLengthMode
+const (
+	ThoughtLengthReject   = "reject"
+	ThoughtLengthTruncate = "truncate"
+)
+
+// ValidateThoughtLengthMode reports whether a mode is supported (empty selects reject)
+func ValidateThoughtLengthMode(mode string) error {
+	switch mode {
+	case "", ThoughtLengthReject, ThoughtLengthTruncate:
+		return nil
+	default:
+		return fmt.Errorf("unknown thought length mode %q (expected %s or %s)", mode, ThoughtLengthReject, ThoughtLengthTruncate)
+	}
+}
+
+// Log destinations selectable via Config.LogOutput
+const (
+	LogOutputStderr = "stderr"
+	LogOutputStdout = "stdout"
+)
+
+// ValidateLogOutput reports whether a log destination is supported (empty selects stderr)
+func ValidateLogOutput(output string) error {
+	switch output {
+	case "", LogOutputStderr, LogOutputStdout:
+		return nil
+	default:
+		return fmt.Errorf("unknown log output %q (expected %s or %s)", output, LogOutputStderr, LogOutputStdout)
+	}
+}
+
+// ValidateAPIKeys rejects API keys that are empty, which would otherwise
+// match requests sending no key
+func ValidateAPIKeys(apiKeys []APIKey) error {
+	for i, apiKey := range apiKeys {
+		if strings.TrimSpace(apiKey.Key) == "" {
+			return fmt.Errorf("api key %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// APIKeyScopes returns the configured scopes keyed by API key
+func (c *Config) APIKeyScopes() map[string][]string {
+	scopes := make(map[string][]string, len(c.APIKeys))
+	for _, apiKey := range c.APIKeys {
+		scopes[apiKey.Key] = apiKey.Scopes
+	}
+	return scopes
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
+		ServerName:            "GoThink MCP Server",
+		ServerVersion:         BuildVersion,
 		Port:                  "8080",
 		Host:                  "localhost",
 		ReadTimeout:           30 * time.Second,
 		WriteTimeout:          30 * time.Second,
 		SessionTimeout:        30 * time.Minute,
 		MaxThoughtsPerSession: 100,
+		MaxTotalThoughts:      100000,
+		ThoughtNumberBase:     1,
+		GracePeriod:           10 * time.Minute,
+		ReaperInterval:        time.Minute,
+
+		MaxCheckpointsPerSession: 10,
+		RecentSessionsLimit:      10,
+		SimilarityThreshold:      0.6,
+
+		IDStrategy:     "uuid",
+		SessionIDStyle: "uuid",
+		StorageShards:  16,
+
+		MaxThoughtLength: 10000,
+		MaxProblemLength: 4000,
+		MaxIssueLength:   4000,
+		MaxStepLength:    2000,
+		MaxStepsPerCall:  100,
+
+		ThoughtLengthMode: ThoughtLengthReject,
+
+		MaxMetadataEntries:     32,
+		MaxMetadataKeyLength:   64,
+		MaxMetadataValueLength: 1024,
+
+		MaxImportBytes:    10 << 20,
+		MaxImportEntities: 1000,
+
+		MaxToolsUsed:      64,
+		ToolsUsedOverflow: "ring",
+		MaxAuditEntries:   256,
+		AuditOverflow:     "ring",
+		MetadataOverflow:  "reject",
 
 		EnablePersistence:     false,
 		EnableDetailedLogging: false,
 		LogLevel:              "info",
+		LogOutput:             LogOutputStderr,
+		JSONFieldStyle:        "snake",
+		ConfidenceScale:       "fraction",
+		DefaultCategory:       "uncategorized",
+		CaseInsensitiveNames:  false,
+		WebhookMaxAttempts:    3,
+		WebhookRetryBackoff:   time.Second,
 		AlgorithmDefaults:     make(map[string]interface{}),
+
+		EndSubscriptionsWithSession: true,
+		EnforceJSONContentType:      true,
+		ExportTokenMaxTTL:           24 * time.Hour,
 	}
 }
 
@@ -68,18 +327,42 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
-// loadFromFile loads configuration from a JSON file
+// loadFromFile loads configuration from a JSON or YAML file, chosen by
+// extension. Files with any other extension are tried as JSON, then YAML.
 func loadFromFile(cfg *Config, filename string) error {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, cfg)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return json.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, cfg)
+	}
+
+	// Decode into a copy so a failed JSON attempt leaves cfg untouched
+	candidate := *cfg
+	jsonErr := json.Unmarshal(data, &candidate)
+	if jsonErr == nil {
+		*cfg = candidate
+		return nil
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("not valid JSON (%v) or YAML (%w)", jsonErr, err)
+	}
+	return nil
 }
 
 // loadFromEnv loads configuration from environment variables
 func loadFromEnv(cfg *Config) {
+	if serverName := os.Getenv("GOTHINK_SERVER_NAME"); serverName != "" {
+		cfg.ServerName = serverName
+	}
+	if serverVersion := os.Getenv("GOTHINK_SERVER_VERSION"); serverVersion != "" {
+		cfg.ServerVersion = serverVersion
+	}
 	if port := os.Getenv("GOTHINK_PORT"); port != "" {
 		cfg.Port = port
 	}
@@ -87,10 +370,162 @@ func loadFromEnv(cfg *Config) {
 		cfg.Host = host
 	}
 
+	if idStrategy := os.Getenv("GOTHINK_ID_STRATEGY"); idStrategy != "" {
+		cfg.IDStrategy = idStrategy
+	}
+	if sessionIDStyle := os.Getenv("GOTHINK_SESSION_ID_STYLE"); sessionIDStyle != "" {
+		cfg.SessionIDStyle = sessionIDStyle
+	}
+	if storageShards := os.Getenv("GOTHINK_STORAGE_SHARDS"); storageShards != "" {
+		if shards, err := strconv.Atoi(storageShards); err == nil {
+			cfg.StorageShards = shards
+		}
+	}
+	if maxTotalThoughts := os.Getenv("GOTHINK_MAX_TOTAL_THOUGHTS"); maxTotalThoughts != "" {
+		if limit, err := strconv.Atoi(maxTotalThoughts); err == nil {
+			cfg.MaxTotalThoughts = limit
+		}
+	}
+	if reaperInterval := os.Getenv("GOTHINK_REAPER_INTERVAL"); reaperInterval != "" {
+		if interval, err := time.ParseDuration(reaperInterval); err == nil {
+			cfg.ReaperInterval = interval
+		}
+	}
+	if maxImportBytes := os.Getenv("GOTHINK_MAX_IMPORT_BYTES"); maxImportBytes != "" {
+		if limit, err := strconv.ParseInt(maxImportBytes, 10, 64); err == nil {
+			cfg.MaxImportBytes = limit
+		}
+	}
+	if thoughtNumberBase := os.Getenv("GOTHINK_THOUGHT_NUMBER_BASE"); thoughtNumberBase != "" {
+		if base, err := strconv.Atoi(thoughtNumberBase); err == nil {
+			cfg.ThoughtNumberBase = base
+		}
+	}
+	if thoughtLengthMode := os.Getenv("GOTHINK_THOUGHT_LENGTH_MODE"); thoughtLengthMode != "" {
+		cfg.ThoughtLengthMode = thoughtLengthMode
+	}
+	if jsonFieldStyle := os.Getenv("GOTHINK_JSON_FIELD_STYLE"); jsonFieldStyle != "" {
+		cfg.JSONFieldStyle = jsonFieldStyle
+	}
+	if confidenceScale := os.Getenv("GOTHINK_CONFIDENCE_SCALE"); confidenceScale != "" {
+		cfg.ConfidenceScale = confidenceScale
+	}
 	if logLevel := os.Getenv("GOTHINK_LOG_LEVEL"); logLevel != "" {
 		cfg.LogLevel = logLevel
 	}
+	if logOutput := os.Getenv("GOTHINK_LOG_OUTPUT"); logOutput != "" {
+		cfg.LogOutput = logOutput
+	}
+	if operationLogPath := os.Getenv("GOTHINK_OPERATION_LOG_PATH"); operationLogPath != "" {
+		cfg.OperationLogPath = operationLogPath
+	}
 	if mentalModelsPath := os.Getenv("GOTHINK_MENTAL_MODELS_PATH"); mentalModelsPath != "" {
 		cfg.MentalModelsPath = mentalModelsPath
 	}
+	if allowedCategories := os.Getenv("GOTHINK_ALLOWED_CATEGORIES"); allowedCategories != "" {
+		cfg.AllowedCategories = splitList(allowedCategories)
+	}
+	if caseInsensitive := os.Getenv("GOTHINK_CASE_INSENSITIVE_NAMES"); caseInsensitive != "" {
+		cfg.CaseInsensitiveNames = caseInsensitive == "true" || caseInsensitive == "1"
+	}
+	if inheritCoreSteps := os.Getenv("GOTHINK_INHERIT_CORE_STEPS"); inheritCoreSteps != "" {
+		cfg.InheritCoreSteps = inheritCoreSteps == "true" || inheritCoreSteps == "1"
+	}
+	if sessionTemplatesPath := os.Getenv("GOTHINK_SESSION_TEMPLATES_PATH"); sessionTemplatesPath != "" {
+		cfg.SessionTemplatesPath = sessionTemplatesPath
+	}
+	if enableTracing := os.Getenv("GOTHINK_ENABLE_TRACING"); enableTracing != "" {
+		cfg.EnableTracing = enableTracing == "true" || enableTracing == "1"
+	}
+	if otlpEndpoint := os.Getenv("GOTHINK_OTLP_ENDPOINT"); otlpEndpoint != "" {
+		cfg.OTLPEndpoint = otlpEndpoint
+	}
+	if enableAdminTools := os.Getenv("GOTHINK_ENABLE_ADMIN_TOOLS"); enableAdminTools != "" {
+		cfg.EnableAdminTools = enableAdminTools == "true" || enableAdminTools == "1"
+	}
+	if endSubscriptions := os.Getenv("GOTHINK_END_SUBSCRIPTIONS_WITH_SESSION"); endSubscriptions != "" {
+		cfg.EndSubscriptionsWithSession = endSubscriptions == "true" || endSubscriptions == "1"
+	}
+	if strictArguments := os.Getenv("GOTHINK_STRICT_TOOL_ARGUMENTS"); strictArguments != "" {
+		cfg.StrictToolArguments = strictArguments == "true" || strictArguments == "1"
+	}
+	if enforceContentType := os.Getenv("GOTHINK_ENFORCE_JSON_CONTENT_TYPE"); enforceContentType != "" {
+		cfg.EnforceJSONContentType = enforceContentType == "true" || enforceContentType == "1"
+	}
+	if enablePersistence := os.Getenv("GOTHINK_ENABLE_PERSISTENCE"); enablePersistence != "" {
+		cfg.EnablePersistence = enablePersistence == "true" || enablePersistence == "1"
+	}
+	if persistencePath := os.Getenv("GOTHINK_PERSISTENCE_PATH"); persistencePath != "" {
+		cfg.PersistencePath = persistencePath
+	}
+	if apiKeys := os.Getenv("GOTHINK_API_KEYS"); apiKeys != "" {
+		cfg.APIKeys = parseAPIKeys(apiKeys)
+	}
+	if exportTokenSecret := os.Getenv("GOTHINK_EXPORT_TOKEN_SECRET"); exportTokenSecret != "" {
+		cfg.ExportTokenSecret = exportTokenSecret
+	}
+	if webhookURL := os.Getenv("GOTHINK_WEBHOOK_URL"); webhookURL != "" {
+		cfg.WebhookURL = webhookURL
+	}
+	if webhookEvents := os.Getenv("GOTHINK_WEBHOOK_EVENTS"); webhookEvents != "" {
+		cfg.WebhookEvents = splitList(webhookEvents)
+	}
+	if webhookSecret := os.Getenv("GOTHINK_WEBHOOK_SECRET"); webhookSecret != "" {
+		cfg.WebhookSecret = webhookSecret
+	}
+}
+
+// logLevelAliases maps common alternative level names to logrus levels
+var logLevelAliases = map[string]logrus.Level{
+	"verbose":     logrus.DebugLevel,
+	"information": logrus.InfoLevel,
+	"notice":      logrus.InfoLevel,
+	"err":         logrus.ErrorLevel,
+	"critical":    logrus.FatalLevel,
+}
+
+// ParseLogLevel parses a log level case-insensitively, accepting the logrus
+// level names and common aliases such as "verbose". An unrecognized level
+// yields the info level along with an error describing it.
+func ParseLogLevel(value string) (logrus.Level, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	if level, ok := logLevelAliases[name]; ok {
+		return level, nil
+	}
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return logrus.InfoLevel, fmt.Errorf("unrecognized log level %q", value)
+	}
+	return level, nil
+}
+
+// parseAPIKeys parses comma-separated "key:scope|scope" entries; a key
+// without scopes grants read and write
+func parseAPIKeys(value string) []APIKey {
+	var apiKeys []APIKey
+	for _, entry := range splitList(value) {
+		key, scopes, _ := strings.Cut(entry, ":")
+		apiKey := APIKey{Key: strings.TrimSpace(key)}
+		for _, scope := range strings.Split(scopes, "|") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				apiKey.Scopes = append(apiKey.Scopes, scope)
+			}
+		}
+		if len(apiKey.Scopes) == 0 {
+			apiKey.Scopes = []string{"read", "write"}
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
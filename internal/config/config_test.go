@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for value, expected := range map[string]logrus.Level{
+		"debug":    logrus.DebugLevel,
+		"DEBUG":    logrus.DebugLevel,
+		" Warn ":   logrus.WarnLevel,
+		"warning":  logrus.WarnLevel,
+		"trace":    logrus.TraceLevel,
+		"error":    logrus.ErrorLevel,
+		"verbose":  logrus.DebugLevel,
+		"Verbose":  logrus.DebugLevel,
+		"err":      logrus.ErrorLevel,
+		"critical": logrus.FatalLevel,
+	} {
+		level, err := ParseLogLevel(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, level, value)
+	}
+}
+
+func TestParseLogLevel_InvalidFallsBackToInfo(t *testing.T) {
+	for _, value := range []string{"loud", "", "debug2"} {
+		level, err := ParseLogLevel(value)
+		assert.Error(t, err, value)
+		assert.Equal(t, logrus.InfoLevel, level, value)
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	apiKeys := parseAPIKeys("viewer:read, ops:read|admin,plain")
+
+	assert.Equal(t, []APIKey{
+		{Key: "viewer", Scopes: []string{"read"}},
+		{Key: "ops", Scopes: []string{"read", "admin"}},
+		{Key: "plain", Scopes: []string{"read", "write"}},
+	}, apiKeys)
+
+	cfg := &Config{APIKeys: apiKeys}
+	assert.Equal(t, []string{"read", "admin"}, cfg.APIKeyScopes()["ops"])
+}
+
+func TestValidateAPIKeys_RejectsEmptyKeys(t *testing.T) {
+	assert.NoError(t, ValidateAPIKeys(parseAPIKeys("viewer:read,ops:admin")))
+	assert.NoError(t, ValidateAPIKeys(nil))
+
+	assert.Error(t, ValidateAPIKeys(parseAPIKeys("viewer:read, :admin")))
+	assert.Error(t, ValidateAPIKeys([]APIKey{{Key: " ", Scopes: []string{"admin"}}}))
+}
+
+const jsonConfig = `{
+  "port": "9090",
+  "host": "0.0.0.0",
+  "log_level": "debug",
+  "max_thoughts_per_session": 50,
+  "session_timeout": 1800000000000,
+  "api_keys": [{"key": "ops", "scopes": ["read", "admin"]}],
+  "mental_models_path": "/etc/gothink/models"
+}`
+
+const yamlConfig = `
+port: "9090"
+host: 0.0.0.0
+log_level: debug
+max_thoughts_per_session: 50
+session_timeout: 30m
+api_keys:
+  - key: ops
+    scopes: [read, admin]
+mental_models_path: /etc/gothink/models
+`
+
+// loadConfigFile writes contents to a file called name and loads it over the defaults
+func loadConfigFile(t *testing.T, name, contents string) *Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	cfg := DefaultConfig()
+	require.NoError(t, loadFromFile(cfg, path))
+	return cfg
+}
+
+func TestLoadFromFile_JSONAndYAMLEquivalent(t *testing.T) {
+	fromJSON := loadConfigFile(t, "config.json", jsonConfig)
+	fromYAML := loadConfigFile(t, "config.yaml", yamlConfig)
+
+	assert.Equal(t, fromJSON, fromYAML)
+	assert.Equal(t, "9090", fromYAML.Port)
+	assert.Equal(t, 30*time.Minute, fromYAML.SessionTimeout)
+	assert.Equal(t, []APIKey{{Key: "ops", Scopes: []string{"read", "admin"}}}, fromYAML.APIKeys)
+	// Fields the file leaves out keep their defaults
+	assert.Equal(t, DefaultConfig().MaxImportBytes, fromYAML.MaxImportBytes)
+
+	assert.Equal(t, fromYAML, loadConfigFile(t, "config.yml", yamlConfig))
+}
+
+func TestLoadFromFile_UnknownExtensionTriesJSONThenYAML(t *testing.T) {
+	expected := loadConfigFile(t, "config.json", jsonConfig)
+
+	assert.Equal(t, expected, loadConfigFile(t, "gothink.conf", jsonConfig))
+	assert.Equal(t, expected, loadConfigFile(t, "gothink.conf", yamlConfig))
+
+	path := filepath.Join(t.TempDir(), "gothink.conf")
+	require.NoError(t, os.WriteFile(path, []byte("port: [unterminated"), 0644))
+	assert.Error(t, loadFromFile(DefaultConfig(), path))
+
+	// An explicit extension is decoded strictly as that format
+	path = filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(yamlConfig), 0644))
+	assert.Error(t, loadFromFile(DefaultConfig(), path))
+}
+
+func TestDiff_OnlyChangedFieldsWithSecretsRedacted(t *testing.T) {
+	assert.Empty(t, Diff(DefaultConfig(), DefaultConfig()))
+
+	cfg := DefaultConfig()
+	cfg.Port = "9090"
+	cfg.GracePeriod = 90 * time.Second
+	cfg.APIKeys = []APIKey{{Key: "ops", Scopes: []string{"admin"}}}
+	cfg.ExportTokenSecret = "s3cret"
+
+	assert.Equal(t, map[string]FieldDiff{
+		"port":                {Default: "8080", Effective: "9090"},
+		"grace_period":        {Default: DefaultConfig().GracePeriod.String(), Effective: "1m30s"},
+		"api_keys":            {Default: []APIKey(nil), Effective: "[redacted]"},
+		"export_token_secret": {Default: "", Effective: "[redacted]"},
+	}, Diff(DefaultConfig(), cfg))
+}
+
+func TestValidateLogOutput(t *testing.T) {
+	for _, output := range []string{"", LogOutputStderr, LogOutputStdout} {
+		assert.NoError(t, ValidateLogOutput(output), output)
+	}
+	assert.Error(t, ValidateLogOutput("syslog"))
+	assert.Equal(t, LogOutputStderr, DefaultConfig().LogOutput)
+}
+
+func TestLoadFromEnv_ReaperInterval(t *testing.T) {
+	// Idle sessions expire unless the reaper is turned off
+	assert.Equal(t, time.Minute, DefaultConfig().ReaperInterval)
+
+	t.Setenv("GOTHINK_REAPER_INTERVAL", "0")
+	cfg := DefaultConfig()
+	loadFromEnv(cfg)
+	assert.Zero(t, cfg.ReaperInterval)
+
+	t.Setenv("GOTHINK_REAPER_INTERVAL", "15s")
+	loadFromEnv(cfg)
+	assert.Equal(t, 15*time.Second, cfg.ReaperInterval)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secret settings in a config diff
+const redactedValue = "[redacted]"
+
+// secretFields names the settings whose values are never reported
+var secretFields = map[string]bool{
+	"api_keys":            true,
+	"export_token_secret": true,
+	"webhook_secret":      true,
+}
+
+// FieldDiff is a setting whose effective value differs from its default
+type FieldDiff struct {
+	Default   interface{} `json:"default"`
+	Effective interface{} `json:"effective"`
+}
+
+// Diff returns the settings of cfg that differ from base, keyed by their
+// JSON name. Secret values are redacted and durations are reported in Go
+// duration syntax.
+func Diff(base, cfg *Config) map[string]FieldDiff {
+	diff := make(map[string]FieldDiff)
+
+	baseValue := reflect.ValueOf(base).Elem()
+	cfgValue := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < cfgValue.NumField(); i++ {
+		field := cfgValue.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+
+		defaultValue := baseValue.Field(i).Interface()
+		effectiveValue := cfgValue.Field(i).Interface()
+		if reflect.DeepEqual(defaultValue, effectiveValue) {
+			continue
+		}
+
+		diff[name] = FieldDiff{
+			Default:   reportedValue(name, defaultValue),
+			Effective: reportedValue(name, effectiveValue),
+		}
+	}
+
+	return diff
+}
+
+// reportedValue renders a setting for a diff, hiding secrets that are set
+func reportedValue(name string, value interface{}) interface{} {
+	if secretFields[name] && !reflect.ValueOf(value).IsZero() {
+		return redactedValue
+	}
+	if duration, ok := value.(time.Duration); ok {
+		return duration.String()
+	}
+	return value
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+)
+
+// jsonContentType is the only media type accepted by write endpoints
+const jsonContentType = "application/json"
+
+// hasJSONContentType reports whether the request body is labelled as JSON;
+// parameters such as charset are ignored
+func hasJSONContentType(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == jsonContentType
+}
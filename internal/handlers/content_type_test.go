@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/storage"
+)
+
+const thoughtBody = `{"session_id":"s1","thought":"first","thought_number":1,"total_thoughts":1}`
+
+func newTestThinkingHandler(t *testing.T, enforce bool) *ThinkingHandler {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.EnforceJSONContentType = enforce
+	return newConfiguredThinkingHandler(t, cfg)
+}
+
+// newConfiguredThinkingHandler builds a handler over a fresh store using cfg
+func newConfiguredThinkingHandler(t *testing.T, cfg *config.Config) *ThinkingHandler {
+	t.Helper()
+
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewThinkingHandler(store, logger, cfg)
+}
+
+func postThought(h *ThinkingHandler, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/sequential-thinking", strings.NewReader(thoughtBody))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.SequentialThinking(rec, req)
+	return rec
+}
+
+func TestContentType_JSONAccepted(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	for _, contentType := range []string{"application/json", "application/json; charset=utf-8"} {
+		rec := postThought(h, contentType)
+		assert.Equal(t, http.StatusOK, rec.Code, contentType)
+	}
+}
+
+func TestContentType_WrongTypeRejected(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThought(h, "text/plain")
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Content-Type must be application/json")
+
+	stats, err := h.storage.GetSessionStats("s1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.ThoughtCount)
+}
+
+func TestContentType_MissingRejected(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThought(h, "")
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestContentType_EnforcementDisabled(t *testing.T) {
+	h := newTestThinkingHandler(t, false)
+
+	assert.Equal(t, http.StatusOK, postThought(h, "").Code)
+	assert.Equal(t, http.StatusOK, postThought(h, "text/plain").Code)
+}
+
+func TestContentType_SessionImport(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+	h := NewSessionHandler(store, logrus.New(), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/session/import", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "text/xml")
+	rec := httptest.NewRecorder()
+	h.Import(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
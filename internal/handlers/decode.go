@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodeError describes why a request body could not be decoded, naming the
+// offending field and its expected type when they are known
+type decodeError struct {
+	Message  string `json:"error"`
+	Field    string `json:"field,omitempty"`
+	Expected string `json:"expected_type,omitempty"`
+
+	// cause is the underlying decoding or read error
+	cause error
+}
+
+func (e *decodeError) Error() string {
+	return e.Message
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.cause
+}
+
+// decodeJSONBody decodes a single JSON object from the request body into dst,
+// rejecting unknown fields and trailing data
+func decodeJSONBody(r *http.Request, dst interface{}) *decodeError {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		decodeErr := describeDecodeError(err)
+		decodeErr.cause = err
+		return decodeErr
+	}
+	if decoder.More() {
+		return &decodeError{Message: "Invalid request body: must contain a single JSON object"}
+	}
+	return nil
+}
+
+// describeDecodeError turns an encoding/json error into a client-facing one
+func describeDecodeError(err error) *decodeError {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return &decodeError{Message: fmt.Sprintf("Invalid request body: malformed JSON at offset %d", syntaxError.Offset)}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &decodeError{Message: "Invalid request body: malformed JSON, unexpected end of input"}
+	case errors.Is(err, io.EOF):
+		return &decodeError{Message: "Invalid request body: body is empty"}
+	case errors.As(err, &typeError):
+		if typeError.Field == "" {
+			return &decodeError{
+				Message:  fmt.Sprintf("Invalid request body: expected a JSON object, got %s", typeError.Value),
+				Expected: "object",
+			}
+		}
+		return &decodeError{
+			Message:  fmt.Sprintf("Invalid request body: field '%s' must be %s, got %s", typeError.Field, typeError.Type, typeError.Value),
+			Field:    typeError.Field,
+			Expected: typeError.Type.String(),
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &decodeError{
+			Message: fmt.Sprintf("Invalid request body: unknown field '%s'", field),
+			Field:   field,
+		}
+	default:
+		return &decodeError{Message: "Invalid request body: " + err.Error()}
+	}
+}
+
+// respondWithDecodeError writes a 400 response carrying the decode error's
+// message, field and expected type
+func respondWithDecodeError(w http.ResponseWriter, err *decodeError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(err)
+}
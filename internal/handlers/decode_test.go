@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postMentalModel(t *testing.T, h *ThinkingHandler, body string) (int, decodeError) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/mental-model", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.MentalModel(rec, req)
+
+	var response decodeError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+func TestDecode_UnknownField(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	status, response := postMentalModel(t, h, `{"session_id":"s1","model_name":"inversion","modle":"typo"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "modle", response.Field)
+	assert.Contains(t, response.Message, "unknown field 'modle'")
+}
+
+func TestDecode_WrongTypedField(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	status, response := postMentalModel(t, h, `{"session_id":"s1","model_name":"inversion","confidence":"high"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "confidence", response.Field)
+	assert.Equal(t, "float64", response.Expected)
+	assert.Contains(t, response.Message, "field 'confidence' must be float64, got string")
+
+	status, response = postMentalModel(t, h, `{"session_id":"s1","model_name":"inversion","steps":"one"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "steps", response.Field)
+	assert.Equal(t, "[]string", response.Expected)
+}
+
+func TestDecode_MalformedJSON(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	status, response := postMentalModel(t, h, `{"session_id": "s1",}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, response.Message, "malformed JSON at offset")
+	assert.Empty(t, response.Field)
+
+	status, response = postMentalModel(t, h, `{"session_id": "s1"`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, response.Message, "unexpected end of input")
+
+	status, response = postMentalModel(t, h, ``)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, response.Message, "body is empty")
+
+	status, response = postMentalModel(t, h, `{"session_id":"s1"} {"session_id":"s2"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, response.Message, "single JSON object")
+}
@@ -2,23 +2,34 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
-	"github.com/sirupsen/logrus"
+	"github.com/rainmana/gothink/internal/config"
 	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
 )
 
 // SessionHandler handles session management operations
 type SessionHandler struct {
 	storage *storage.Storage
 	logger  *logrus.Logger
+	// requireJSON rejects write requests not labelled application/json
+	requireJSON bool
+	// maxImportBytes bounds import request bodies (0 disables the bound)
+	maxImportBytes int64
 }
 
 // NewSessionHandler creates a new session handler
-func NewSessionHandler(storage *storage.Storage, logger *logrus.Logger) *SessionHandler {
+func NewSessionHandler(storage *storage.Storage, logger *logrus.Logger, cfg *config.Config) *SessionHandler {
 	return &SessionHandler{
-		storage: storage,
-		logger:  logger,
+		storage:        storage,
+		logger:         logger,
+		requireJSON:    cfg.EnforceJSONContentType,
+		maxImportBytes: cfg.MaxImportBytes,
 	}
 }
 
@@ -58,28 +69,135 @@ func (h *SessionHandler) Export(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, export)
 }
 
-// Import handles session import requests
+// Import handles session import requests: the body is a session export,
+// recreated under its session_id. The session must not exist unless
+// merge=true is given, which appends to it instead.
 func (h *SessionHandler) Import(w http.ResponseWriter, r *http.Request) {
-	// Placeholder implementation
+	if !h.checkContentType(w, r) {
+		return
+	}
+
+	merge := false
+	if raw := r.URL.Query().Get("merge"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			h.respondWithError(w, fmt.Sprintf("Invalid merge value %q: must be true or false", raw), http.StatusBadRequest)
+			return
+		}
+		merge = parsed
+	}
+
+	// Refuse oversized bodies before reading them into memory
+	if h.maxImportBytes > 0 {
+		if r.ContentLength > h.maxImportBytes {
+			h.respondWithError(w, fmt.Sprintf("Import exceeds the maximum of %d bytes", h.maxImportBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxImportBytes)
+	}
+
+	var export types.SessionExport
+	if err := decodeJSONBody(r, &export); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondWithError(w, fmt.Sprintf("Import exceeds the maximum of %d bytes", h.maxImportBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		respondWithDecodeError(w, err)
+		return
+	}
+	if export.Version == "" {
+		h.respondWithError(w, "Export version required", http.StatusBadRequest)
+		return
+	}
+	if export.SessionID == "" {
+		h.respondWithError(w, "Export session_id required", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(export)
+	if err != nil {
+		h.respondWithError(w, "Failed to read export", http.StatusBadRequest)
+		return
+	}
+
+	importSession := h.storage.ImportSession
+	if merge {
+		importSession = h.storage.MergeSession
+	}
+	sessionID, err := importSession(payload)
+	switch {
+	case errors.Is(err, storage.ErrSessionExists):
+		h.respondWithError(w, fmt.Sprintf("Session %s already exists; import with merge=true to append to it", export.SessionID), http.StatusConflict)
+		return
+	case errors.Is(err, storage.ErrImportTooLarge):
+		h.respondWithError(w, fmt.Sprintf("Failed to import session: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, storage.ErrSessionArchived):
+		h.respondWithError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		h.logger.WithError(err).Warn("Failed to import session")
+		h.respondWithError(w, fmt.Sprintf("Failed to import session: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.storage.GetSessionStats(sessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get session stats")
+		h.respondWithError(w, "Failed to get session stats", http.StatusInternalServerError)
+		return
+	}
+
 	response := map[string]interface{}{
-		"message": "Session import not yet implemented",
-		"status":  "coming_soon",
+		"status":        "success",
+		"session_id":    sessionID,
+		"merged":        merge,
+		"thought_count": stats.ThoughtCount,
 	}
 	h.respondWithJSON(w, response)
 }
 
-// Clear handles session clear requests
+// Clear handles session clear requests, deleting the session with all of
+// its thoughts and mental models
 func (h *SessionHandler) Clear(w http.ResponseWriter, r *http.Request) {
-	// Placeholder implementation
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		h.respondWithError(w, "Session ID required", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := h.storage.ClearSession(sessionID)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		h.respondWithError(w, fmt.Sprintf("Session %s not found", sessionID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to clear session")
+		h.respondWithError(w, "Failed to clear session", http.StatusInternalServerError)
+		return
+	}
+
 	response := map[string]interface{}{
-		"message": "Session clear not yet implemented",
-		"status":  "coming_soon",
+		"status":     "success",
+		"session_id": sessionID,
+		"removed":    removed,
 	}
 	h.respondWithJSON(w, response)
 }
 
 // Helper methods
 
+// checkContentType writes a 415 response and returns false when content type
+// enforcement is on and the request body is not labelled as JSON
+func (h *SessionHandler) checkContentType(w http.ResponseWriter, r *http.Request) bool {
+	if h.requireJSON && !hasJSONContentType(r) {
+		h.respondWithError(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
 func (h *SessionHandler) respondWithJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/types"
+)
+
+func newTestSessionHandler(t *testing.T) *SessionHandler {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewSessionHandler(store, logger, cfg)
+}
+
+// exportSession fetches a session export through the handler, renamed to sessionID
+func exportSession(t *testing.T, h *SessionHandler, from, sessionID string) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.Export(rec, httptest.NewRequest(http.MethodGet, "/session/export?session_id="+from, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var export map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &export))
+	export["session_id"] = sessionID
+	body, err := json.Marshal(export)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func postImport(h *SessionHandler, query, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/session/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.Import(rec, req)
+	return rec
+}
+
+func populateSession(t *testing.T, store *storage.Storage, sessionID string) {
+	t.Helper()
+
+	for i, text := range []string{"Frame the problem", "Gather evidence"} {
+		require.NoError(t, store.AddThought(sessionID, &types.ThoughtData{Thought: text, ThoughtNumber: i + 1, TotalThoughts: 3}))
+	}
+	revises := 1
+	require.NoError(t, store.AddThought(sessionID, &types.ThoughtData{
+		Thought: "Reframe", ThoughtNumber: 3, TotalThoughts: 3, IsRevision: true, RevisesThought: &revises,
+	}))
+	require.NoError(t, store.AddMentalModel(sessionID, &types.MentalModelData{ModelName: "first_principles", Problem: "Scope"}))
+	require.NoError(t, store.SetSessionMetadata(sessionID, "owner", "team-a"))
+}
+
+func TestSessionImport_RoundTripsExport(t *testing.T) {
+	h := newTestSessionHandler(t)
+	populateSession(t, h.storage, "original")
+
+	rec := postImport(h, "", exportSession(t, h, "original", "copy"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"session_id":"copy"`)
+
+	original, err := h.storage.GetSessionStats("original")
+	require.NoError(t, err)
+	imported, err := h.storage.GetSessionStats("copy")
+	require.NoError(t, err)
+	assert.Equal(t, original.ThoughtCount, imported.ThoughtCount)
+	assert.Equal(t, original.RevisionCount, imported.RevisionCount)
+	assert.Equal(t, original.RemainingThoughts, imported.RemainingThoughts)
+	assert.Equal(t, original.Stores, imported.Stores)
+
+	metadata, err := h.storage.GetSessionMetadata("copy")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, metadata)
+}
+
+func TestSessionImport_ConflictingSessionRejected(t *testing.T) {
+	h := newTestSessionHandler(t)
+	populateSession(t, h.storage, "original")
+
+	rec := postImport(h, "", exportSession(t, h, "original", "original"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "already exists")
+
+	stats, err := h.storage.GetSessionStats("original")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.ThoughtCount)
+}
+
+func TestSessionImport_MergeAppends(t *testing.T) {
+	h := newTestSessionHandler(t)
+	populateSession(t, h.storage, "original")
+
+	rec := postImport(h, "?merge=true", exportSession(t, h, "original", "original"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"thought_count":6`)
+
+	thoughts, err := h.storage.GetThoughts("original")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 6)
+	// Appended thoughts continue the numbering and keep their revision links
+	assert.Equal(t, 4, thoughts[3].ThoughtNumber)
+	assert.Equal(t, 6, thoughts[5].ThoughtNumber)
+	require.NotNil(t, thoughts[5].RevisesThought)
+	assert.Equal(t, 4, *thoughts[5].RevisesThought)
+
+	models, err := h.storage.GetMentalModels("original")
+	require.NoError(t, err)
+	assert.Len(t, models, 2)
+}
+
+func TestSessionImport_MalformedBodiesRejected(t *testing.T) {
+	h := newTestSessionHandler(t)
+
+	for name, body := range map[string]string{
+		"not json":        `{"version":`,
+		"unknown field":   `{"version":"1.1.0","session_id":"s","data":{},"extra":true}`,
+		"missing version": `{"session_id":"s","data":{}}`,
+		"missing session": `{"version":"1.1.0","data":{}}`,
+		"newer version":   `{"version":"9.0.0","session_id":"s","data":{}}`,
+		"bad thoughts":    `{"version":"1.1.0","session_id":"s","data":{"thoughts":"none"}}`,
+	} {
+		rec := postImport(h, "", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+
+	rec := postImport(h, "?merge=maybe", `{"version":"1.1.0","session_id":"s","data":{}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	_, err := h.storage.GetSession("s")
+	assert.Error(t, err)
+}
+
+func TestSessionImport_OversizedBodyRejected(t *testing.T) {
+	h := newTestSessionHandler(t)
+	populateSession(t, h.storage, "original")
+	body := exportSession(t, h, "original", "copy")
+	h.maxImportBytes = int64(len(body) - 1)
+
+	rec := postImport(h, "", body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// Without a declared length the body is cut off while reading
+	req := httptest.NewRequest(http.MethodPost, "/session/import", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	h.Import(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	_, err := h.storage.GetSession("copy")
+	assert.Error(t, err)
+}
+
+func TestSessionClear_RemovesPopulatedSession(t *testing.T) {
+	h := newTestSessionHandler(t)
+	populateSession(t, h.storage, "doomed")
+
+	clearSession := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Clear(rec, httptest.NewRequest(http.MethodPost, "/session/clear?session_id=doomed", nil))
+		return rec
+	}
+
+	rec := clearSession()
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"removed":4`)
+
+	_, err := h.storage.GetSession("doomed")
+	assert.Error(t, err)
+
+	// A second clear finds nothing left
+	assert.Equal(t, http.StatusNotFound, clearSession().Code)
+}
+
+func TestSessionClear_NonexistentSession(t *testing.T) {
+	h := newTestSessionHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.Clear(rec, httptest.NewRequest(http.MethodPost, "/session/clear?session_id=missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.Clear(rec, httptest.NewRequest(http.MethodPost, "/session/clear", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -2,10 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/rainmana/gothink/internal/config"
 	"github.com/rainmana/gothink/internal/storage"
 	"github.com/rainmana/gothink/internal/types"
 )
@@ -14,13 +21,19 @@ import (
 type ThinkingHandler struct {
 	storage *storage.Storage
 	logger  *logrus.Logger
+	// requireJSON rejects write requests not labelled application/json
+	requireJSON bool
+	// confidenceScale is how clients express confidence
+	confidenceScale string
 }
 
 // NewThinkingHandler creates a new thinking handler
-func NewThinkingHandler(storage *storage.Storage, logger *logrus.Logger) *ThinkingHandler {
+func NewThinkingHandler(storage *storage.Storage, logger *logrus.Logger, cfg *config.Config) *ThinkingHandler {
 	return &ThinkingHandler{
-		storage: storage,
-		logger:  logger,
+		storage:         storage,
+		logger:          logger,
+		requireJSON:     cfg.EnforceJSONContentType,
+		confidenceScale: cfg.ConfidenceScale,
 	}
 }
 
@@ -39,8 +52,11 @@ func (h *ThinkingHandler) SequentialThinking(w http.ResponseWriter, r *http.Requ
 		NeedsMoreThoughts bool   `json:"needs_more_thoughts,omitempty"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
@@ -98,8 +114,11 @@ func (h *ThinkingHandler) MentalModel(w http.ResponseWriter, r *http.Request) {
 		Confidence float64  `json:"confidence,omitempty"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
@@ -108,6 +127,11 @@ func (h *ThinkingHandler) MentalModel(w http.ResponseWriter, r *http.Request) {
 		h.respondWithError(w, "Invalid mental model", http.StatusBadRequest)
 		return
 	}
+	confidence, err := types.NormalizeConfidence(request.Confidence, h.confidenceScale)
+	if err != nil {
+		h.respondWithError(w, fmt.Sprintf("Invalid confidence: %v", err), http.StatusBadRequest)
+		return
+	}
 
 	// Create mental model data
 	model := &types.MentalModelData{
@@ -117,14 +141,13 @@ func (h *ThinkingHandler) MentalModel(w http.ResponseWriter, r *http.Request) {
 		Steps:      request.Steps,
 		Reasoning:  request.Reasoning,
 		Conclusion: request.Conclusion,
-		Confidence: request.Confidence,
+		Confidence: confidence,
 		CreatedAt:  time.Now(),
 	}
 
 	// Add to storage
 	if err := h.storage.AddMentalModel(request.SessionID, model); err != nil {
-		h.logger.WithError(err).Error("Failed to add mental model")
-		h.respondWithError(w, "Failed to add mental model", http.StatusInternalServerError)
+		h.respondWithAddModelError(w, err, "Failed to add mental model")
 		return
 	}
 
@@ -160,15 +183,18 @@ func (h *ThinkingHandler) DebuggingApproach(w http.ResponseWriter, r *http.Reque
 		Resolution   string   `json:"resolution"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
-	// For now, we'll store this as a mental model with a special type
+	// Debugging approaches are stored as specially named mental models
 	model := &types.MentalModelData{
 		ID:         "",
-		ModelName:  "debugging_" + request.ApproachName,
+		ModelName:  types.DebuggingApproachPrefix + request.ApproachName,
 		Problem:    request.Issue,
 		Steps:      request.Steps,
 		Reasoning:  request.Findings,
@@ -178,8 +204,7 @@ func (h *ThinkingHandler) DebuggingApproach(w http.ResponseWriter, r *http.Reque
 
 	// Add to storage
 	if err := h.storage.AddMentalModel(request.SessionID, model); err != nil {
-		h.logger.WithError(err).Error("Failed to add debugging approach")
-		h.respondWithError(w, "Failed to add debugging approach", http.StatusInternalServerError)
+		h.respondWithAddModelError(w, err, "Failed to add debugging approach")
 		return
 	}
 
@@ -193,63 +218,429 @@ func (h *ThinkingHandler) DebuggingApproach(w http.ResponseWriter, r *http.Reque
 	h.respondWithJSON(w, response)
 }
 
-// CollaborativeReasoning handles collaborative reasoning requests
+// CollaborativeReasoning handles collaborative reasoning requests, storing a
+// debate on a topic between at least two personas and the statements each
+// contributed
 func (h *ThinkingHandler) CollaborativeReasoning(w http.ResponseWriter, r *http.Request) {
-	// Placeholder implementation
+	var request struct {
+		SessionID     string               `json:"session_id"`
+		Topic         string               `json:"topic"`
+		Personas      []types.Persona      `json:"personas"`
+		Contributions []types.Contribution `json:"contributions"`
+	}
+
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if request.SessionID == "" || request.Topic == "" {
+		h.respondWithError(w, "session_id and topic are required", http.StatusBadRequest)
+		return
+	}
+	if len(request.Personas) < 2 {
+		h.respondWithError(w, "At least two personas are required", http.StatusBadRequest)
+		return
+	}
+
+	// Personas need distinct names, and contributions must come from one
+	personas := make(map[string]bool, len(request.Personas))
+	for i, persona := range request.Personas {
+		if persona.Name == "" || personas[persona.Name] {
+			h.respondWithError(w, fmt.Sprintf("Persona %d needs a distinct name", i), http.StatusBadRequest)
+			return
+		}
+		personas[persona.Name] = true
+	}
+	for i, contribution := range request.Contributions {
+		if !personas[contribution.Persona] {
+			h.respondWithError(w, fmt.Sprintf("Contribution %d is attributed to unknown persona '%s'", i, contribution.Persona), http.StatusBadRequest)
+			return
+		}
+		if contribution.Statement == "" {
+			h.respondWithError(w, fmt.Sprintf("Contribution %d needs a statement", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	model := &types.MentalModelData{
+		ModelName:     types.CollaborativeReasoningModelName,
+		Problem:       request.Topic,
+		Steps:         []string{},
+		Personas:      request.Personas,
+		Contributions: request.Contributions,
+		CreatedAt:     time.Now(),
+	}
+
+	// Add to storage
+	if err := h.storage.AddMentalModel(request.SessionID, model); err != nil {
+		h.respondWithAddModelError(w, err, "Failed to add collaborative reasoning")
+		return
+	}
+
+	// Get session context
+	stats, err := h.storage.GetSessionStats(request.SessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get session stats")
+	}
+
 	response := map[string]interface{}{
-		"message": "Collaborative reasoning not yet implemented",
-		"status":  "coming_soon",
+		"model_id":           model.ID,
+		"status":             "success",
+		"persona_count":      len(request.Personas),
+		"contribution_count": len(request.Contributions),
+		"session_context": map[string]interface{}{
+			"session_id":          request.SessionID,
+			"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
+		},
 	}
+
 	h.respondWithJSON(w, response)
 }
 
-// SocraticMethod handles Socratic method requests
+// SocraticMethod handles Socratic method requests, recording a chain of
+// questions about a claim and any answers so the dialogue can be replayed
 func (h *ThinkingHandler) SocraticMethod(w http.ResponseWriter, r *http.Request) {
-	// Placeholder implementation
+	var request struct {
+		SessionID string   `json:"session_id"`
+		Claim     string   `json:"claim"`
+		Questions []string `json:"questions"`
+		Answers   []string `json:"answers"`
+	}
+
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if request.SessionID == "" || request.Claim == "" {
+		h.respondWithError(w, "session_id and claim are required", http.StatusBadRequest)
+		return
+	}
+	if len(request.Questions) == 0 {
+		h.respondWithError(w, "At least one question is required", http.StatusBadRequest)
+		return
+	}
+	if len(request.Answers) > len(request.Questions) {
+		h.respondWithError(w, "More answers than questions", http.StatusBadRequest)
+		return
+	}
+
+	// Answers pair with questions by position; later questions may be open
+	exchanges := make([]string, len(request.Questions))
+	answered := 0
+	for i, question := range request.Questions {
+		answer := ""
+		if i < len(request.Answers) {
+			answer = request.Answers[i]
+		}
+		if answer != "" {
+			answered++
+		}
+		exchanges[i] = types.SocraticExchange(question, answer)
+	}
+
+	// Socratic dialogues are stored as specially named mental models
+	model := &types.MentalModelData{
+		ModelName: types.SocraticModelName,
+		Problem:   request.Claim,
+		Steps:     exchanges,
+		CreatedAt: time.Now(),
+	}
+
+	// Add to storage
+	if err := h.storage.AddMentalModel(request.SessionID, model); err != nil {
+		h.respondWithAddModelError(w, err, "Failed to add Socratic dialogue")
+		return
+	}
+
+	// Count every question asked in the session's dialogues so far
+	totalQuestions := 0
+	models, err := h.storage.GetMentalModels(request.SessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get mental models")
+	}
+	for _, stored := range models {
+		if stored.ModelName == types.SocraticModelName {
+			totalQuestions += len(stored.Steps)
+		}
+	}
+
 	response := map[string]interface{}{
-		"message": "Socratic method not yet implemented",
-		"status":  "coming_soon",
+		"dialogue_id":     model.ID,
+		"status":          "success",
+		"has_questions":   true,
+		"question_count":  len(request.Questions),
+		"answered_count":  answered,
+		"total_questions": totalQuestions,
 	}
+
 	h.respondWithJSON(w, response)
 }
 
-// CreativeThinking handles creative thinking requests
+// CreativeThinking handles creative thinking requests, storing an exercise
+// with one idea slot per prompt of the chosen technique for the caller to fill
 func (h *ThinkingHandler) CreativeThinking(w http.ResponseWriter, r *http.Request) {
-	// Placeholder implementation
+	var request struct {
+		SessionID string `json:"session_id"`
+		Prompt    string `json:"prompt"`
+		Technique string `json:"technique"`
+	}
+
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if request.SessionID == "" || request.Prompt == "" {
+		h.respondWithError(w, "session_id and prompt are required", http.StatusBadRequest)
+		return
+	}
+	slots, exists := types.CreativeTechniques[request.Technique]
+	if !exists {
+		h.respondWithError(w, fmt.Sprintf("Unknown technique '%s' (expected one of %s)", request.Technique, strings.Join(sortedKeys(types.CreativeTechniques), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	// Creative exercises are stored as specially named mental models
+	model := &types.MentalModelData{
+		ModelName: types.CreativeThinkingPrefix + request.Technique,
+		Problem:   request.Prompt,
+		Steps:     slots,
+		CreatedAt: time.Now(),
+	}
+
+	// Add to storage
+	if err := h.storage.AddMentalModel(request.SessionID, model); err != nil {
+		h.respondWithAddModelError(w, err, "Failed to add creative thinking exercise")
+		return
+	}
+
+	// Get session context
+	stats, err := h.storage.GetSessionStats(request.SessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get session stats")
+	}
+
+	ideaSlots := make([]map[string]string, len(slots))
+	for i, slot := range slots {
+		ideaSlots[i] = map[string]string{"prompt": slot, "idea": ""}
+	}
+
 	response := map[string]interface{}{
-		"message": "Creative thinking not yet implemented",
-		"status":  "coming_soon",
+		"exercise_id": model.ID,
+		"status":      "success",
+		"technique":   request.Technique,
+		"idea_slots":  ideaSlots,
+		"session_context": map[string]interface{}{
+			"session_id":          request.SessionID,
+			"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
+		},
 	}
+
 	h.respondWithJSON(w, response)
 }
 
-// SystemsThinking handles systems thinking requests
+// SystemsThinking handles systems thinking requests, storing a system's
+// components and the feedback loops between them as a systems_thinking
+// mental model application
 func (h *ThinkingHandler) SystemsThinking(w http.ResponseWriter, r *http.Request) {
-	// Placeholder implementation
+	var request struct {
+		SessionID     string               `json:"session_id"`
+		SystemName    string               `json:"system_name"`
+		Components    []string             `json:"components"`
+		FeedbackLoops []types.FeedbackLoop `json:"feedback_loops"`
+	}
+
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if request.SessionID == "" || request.SystemName == "" {
+		h.respondWithError(w, "session_id and system_name are required", http.StatusBadRequest)
+		return
+	}
+	if len(request.Components) == 0 {
+		h.respondWithError(w, "At least one component is required", http.StatusBadRequest)
+		return
+	}
+
+	// Loops must connect known components with a known polarity
+	components := make(map[string]bool, len(request.Components))
+	for _, component := range request.Components {
+		components[component] = true
+	}
+	for i, loop := range request.FeedbackLoops {
+		if !components[loop.Source] || !components[loop.Target] {
+			h.respondWithError(w, fmt.Sprintf("Feedback loop %d must connect listed components", i), http.StatusBadRequest)
+			return
+		}
+		if loop.Polarity != types.PolarityPositive && loop.Polarity != types.PolarityNegative {
+			h.respondWithError(w, fmt.Sprintf("Feedback loop %d has polarity '%s' (expected %s or %s)", i, loop.Polarity, types.PolarityPositive, types.PolarityNegative), http.StatusBadRequest)
+			return
+		}
+	}
+
+	model := &types.MentalModelData{
+		ModelName:     "systems_thinking",
+		Problem:       request.SystemName,
+		Steps:         request.Components,
+		FeedbackLoops: request.FeedbackLoops,
+		CreatedAt:     time.Now(),
+	}
+
+	// Add to storage
+	if err := h.storage.AddMentalModel(request.SessionID, model); err != nil {
+		h.respondWithAddModelError(w, err, "Failed to add system")
+		return
+	}
+
+	// Get session context
+	stats, err := h.storage.GetSessionStats(request.SessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get session stats")
+	}
+
 	response := map[string]interface{}{
-		"message": "Systems thinking not yet implemented",
-		"status":  "coming_soon",
+		"model_id":        model.ID,
+		"status":          "success",
+		"component_count": len(request.Components),
+		"loop_count":      len(request.FeedbackLoops),
+		"session_context": map[string]interface{}{
+			"session_id":          request.SessionID,
+			"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
+		},
 	}
+
 	h.respondWithJSON(w, response)
 }
 
-// ScientificMethod handles scientific method requests
+// ScientificMethod handles scientific method requests, storing a hypothesis
+// with the experiments testing it and what was observed and concluded
 func (h *ThinkingHandler) ScientificMethod(w http.ResponseWriter, r *http.Request) {
-	// Placeholder implementation
+	var request struct {
+		SessionID string `json:"session_id"`
+		types.ScientificMethodData
+	}
+
+	if !h.checkContentType(w, r) {
+		return
+	}
+	if err := decodeJSONBody(r, &request); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if request.SessionID == "" || strings.TrimSpace(request.Hypothesis) == "" {
+		h.respondWithError(w, "session_id and hypothesis are required", http.StatusBadRequest)
+		return
+	}
+	for i, experiment := range request.Experiments {
+		if strings.TrimSpace(experiment.Description) == "" {
+			h.respondWithError(w, fmt.Sprintf("Experiment %d needs a description", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	cycle := request.ScientificMethodData
+	model := &types.MentalModelData{
+		ModelName:        types.ScientificMethodModelName,
+		Problem:          cycle.Hypothesis,
+		Steps:            []string{},
+		Conclusion:       cycle.Conclusion,
+		ScientificMethod: &cycle,
+		CreatedAt:        time.Now(),
+	}
+
+	// Add to storage
+	if err := h.storage.AddMentalModel(request.SessionID, model); err != nil {
+		h.respondWithAddModelError(w, err, "Failed to add scientific method cycle")
+		return
+	}
+
+	// Get session context
+	stats, err := h.storage.GetSessionStats(request.SessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get session stats")
+	}
+
 	response := map[string]interface{}{
-		"message": "Scientific method not yet implemented",
-		"status":  "coming_soon",
+		"model_id":         model.ID,
+		"status":           "success",
+		"experiment_count": len(cycle.Experiments),
+		"has_conclusion":   strings.TrimSpace(cycle.Conclusion) != "",
+		"session_context": map[string]interface{}{
+			"session_id":          request.SessionID,
+			"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
+		},
 	}
+
 	h.respondWithJSON(w, response)
 }
 
 // Helper methods
 
+// sortedKeys returns a map's keys in order, for listing accepted values
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkContentType writes a 415 response and returns false when content type
+// enforcement is on and the request body is not labelled as JSON
+func (h *ThinkingHandler) checkContentType(w http.ResponseWriter, r *http.Request) bool {
+	if h.requireJSON && !hasJSONContentType(r) {
+		h.respondWithError(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
 func (h *ThinkingHandler) respondWithJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
 
+// respondWithAddModelError reports a failure to store a mental model: 429
+// with the prior application for a cooldown, 409 for an archived or full
+// session and 500 for anything else
+func (h *ThinkingHandler) respondWithAddModelError(w http.ResponseWriter, err error, message string) {
+	var cooldown *storage.CooldownError
+	switch {
+	case errors.As(err, &cooldown):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(cooldown.RetryAfter.Seconds()))))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":               cooldown.Error(),
+			"prior_id":            cooldown.PriorID,
+			"retry_after_seconds": cooldown.RetryAfter.Seconds(),
+		})
+	case errors.Is(err, storage.ErrSessionArchived), errors.Is(err, storage.ErrMentalModelLimitReached):
+		h.respondWithError(w, fmt.Sprintf("%s: %v", message, err), http.StatusConflict)
+	default:
+		h.logger.WithError(err).Error(message)
+		h.respondWithError(w, message, http.StatusInternalServerError)
+	}
+}
+
 func (h *ThinkingHandler) respondWithError(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// postThinking sends a JSON body to one of the handler's endpoints
+func postThinking(handle http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/thinking", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handle(rec, req)
+	return rec
+}
+
+func TestCreativeThinking_StoresIdeaSlots(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThinking(h.CreativeThinking, `{"session_id":"ideas","prompt":"Cut onboarding time","technique":"scamper"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response struct {
+		ExerciseID     string                 `json:"exercise_id"`
+		Technique      string                 `json:"technique"`
+		IdeaSlots      []map[string]string    `json:"idea_slots"`
+		SessionContext map[string]interface{} `json:"session_context"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "scamper", response.Technique)
+	require.Len(t, response.IdeaSlots, len(types.CreativeTechniques["scamper"]))
+	assert.Equal(t, map[string]string{"prompt": types.CreativeTechniques["scamper"][0], "idea": ""}, response.IdeaSlots[0])
+	assert.Equal(t, float64(1), response.SessionContext["total_mental_models"])
+
+	models, err := h.storage.GetMentalModels("ideas")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, response.ExerciseID, models[0].ID)
+	assert.Equal(t, types.CreativeThinkingPrefix+"scamper", models[0].ModelName)
+	assert.Equal(t, "Cut onboarding time", models[0].Problem)
+	assert.Equal(t, types.CreativeTechniques["scamper"], models[0].Steps)
+}
+
+func TestCreativeThinking_UnknownTechniqueRejected(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThinking(h.CreativeThinking, `{"session_id":"ideas","prompt":"Cut onboarding time","technique":"daydream"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "brainstorm, lateral, scamper")
+
+	rec = postThinking(h.CreativeThinking, `{"session_id":"ideas","technique":"lateral"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	_, err := h.storage.GetSession("ideas")
+	assert.Error(t, err)
+}
+
+func TestSocraticMethod_RecordsQuestionChain(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThinking(h.SocraticMethod, `{
+		"session_id": "dialogue",
+		"claim": "Microservices will speed up delivery",
+		"questions": ["What slows delivery today?", "Would splitting services remove that?", "What new costs appear?"],
+		"answers": ["Long test runs", "Only if tests split too"]
+	}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"has_questions":true`)
+	assert.Contains(t, rec.Body.String(), `"question_count":3`)
+	assert.Contains(t, rec.Body.String(), `"answered_count":2`)
+	assert.Contains(t, rec.Body.String(), `"total_questions":3`)
+
+	models, err := h.storage.GetMentalModels("dialogue")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, types.SocraticModelName, models[0].ModelName)
+	assert.Equal(t, "Microservices will speed up delivery", models[0].Problem)
+	assert.Equal(t, []string{
+		"Q: What slows delivery today?\nA: Long test runs",
+		"Q: Would splitting services remove that?\nA: Only if tests split too",
+		"Q: What new costs appear?",
+	}, models[0].Steps)
+
+	// The running count spans every dialogue in the session
+	rec = postThinking(h.SocraticMethod, `{"session_id":"dialogue","claim":"Tests are the bottleneck","questions":["How long do they take?"]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"total_questions":4`)
+}
+
+func TestSocraticMethod_RequiresQuestions(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	for name, body := range map[string]string{
+		"empty questions": `{"session_id":"dialogue","claim":"Claim","questions":[]}`,
+		"no questions":    `{"session_id":"dialogue","claim":"Claim"}`,
+		"extra answers":   `{"session_id":"dialogue","claim":"Claim","questions":["Why?"],"answers":["Because","Also"]}`,
+		"no claim":        `{"session_id":"dialogue","questions":["Why?"]}`,
+	} {
+		rec := postThinking(h.SocraticMethod, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+
+	_, err := h.storage.GetSession("dialogue")
+	assert.Error(t, err)
+}
+
+func TestSystemsThinking_RoundTripsThroughExport(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThinking(h.SystemsThinking, `{
+		"session_id": "system",
+		"system_name": "Support queue",
+		"components": ["backlog", "response time", "staff morale"],
+		"feedback_loops": [
+			{"source": "backlog", "target": "response time", "polarity": "positive"},
+			{"source": "response time", "target": "staff morale", "polarity": "negative"}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"loop_count":2`)
+
+	export, err := h.storage.ExportSession("system")
+	require.NoError(t, err)
+	payload, err := json.Marshal(export)
+	require.NoError(t, err)
+
+	var exported struct {
+		Data struct {
+			MentalModels []types.MentalModelData `json:"mental_models"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &exported))
+	require.Len(t, exported.Data.MentalModels, 1)
+	system := exported.Data.MentalModels[0]
+	assert.Equal(t, "systems_thinking", system.ModelName)
+	assert.Equal(t, "Support queue", system.Problem)
+	assert.Equal(t, []string{"backlog", "response time", "staff morale"}, system.Steps)
+	assert.Equal(t, []types.FeedbackLoop{
+		{Source: "backlog", Target: "response time", Polarity: types.PolarityPositive},
+		{Source: "response time", Target: "staff morale", Polarity: types.PolarityNegative},
+	}, system.FeedbackLoops)
+}
+
+func TestSystemsThinking_InvalidSystemsRejected(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	for name, body := range map[string]string{
+		"no components":     `{"session_id":"system","system_name":"Empty","components":[]}`,
+		"unknown component": `{"session_id":"system","system_name":"Queue","components":["a"],"feedback_loops":[{"source":"a","target":"b","polarity":"positive"}]}`,
+		"unknown polarity":  `{"session_id":"system","system_name":"Queue","components":["a","b"],"feedback_loops":[{"source":"a","target":"b","polarity":"sideways"}]}`,
+	} {
+		rec := postThinking(h.SystemsThinking, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+
+	_, err := h.storage.GetSession("system")
+	assert.Error(t, err)
+}
+
+func TestScientificMethod_RecordsFullCycle(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThinking(h.ScientificMethod, `{
+		"session_id": "lab",
+		"hypothesis": "The cache causes stale reads",
+		"experiments": [
+			{"description": "Disable the cache", "expected_result": "Reads are always fresh"},
+			{"description": "Shorten the TTL to 1s", "expected_result": "Stale reads become rare"}
+		],
+		"observation": "Stale reads stopped with the cache disabled",
+		"conclusion": "The cache TTL is too long"
+	}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"experiment_count":2`)
+	assert.Contains(t, rec.Body.String(), `"has_conclusion":true`)
+
+	models, err := h.storage.GetMentalModels("lab")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	model := models[0]
+	assert.Equal(t, types.ScientificMethodModelName, model.ModelName)
+	assert.Equal(t, "The cache causes stale reads", model.Problem)
+	assert.Equal(t, "The cache TTL is too long", model.Conclusion)
+	assert.Empty(t, model.Steps)
+	assert.Equal(t, &types.ScientificMethodData{
+		Hypothesis: "The cache causes stale reads",
+		Experiments: []types.Experiment{
+			{Description: "Disable the cache", ExpectedResult: "Reads are always fresh"},
+			{Description: "Shorten the TTL to 1s", ExpectedResult: "Stale reads become rare"},
+		},
+		Observation: "Stale reads stopped with the cache disabled",
+		Conclusion:  "The cache TTL is too long",
+	}, model.ScientificMethod)
+
+	// A cycle still being run has no conclusion yet
+	rec = postThinking(h.ScientificMethod, `{"session_id":"lab","hypothesis":"The network drops writes"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"experiment_count":0`)
+	assert.Contains(t, rec.Body.String(), `"has_conclusion":false`)
+}
+
+func TestScientificMethod_RequiresHypothesis(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	for name, body := range map[string]string{
+		"no hypothesis":      `{"session_id":"lab","experiments":[{"description":"Disable the cache"}]}`,
+		"blank hypothesis":   `{"session_id":"lab","hypothesis":"   "}`,
+		"unnamed experiment": `{"session_id":"lab","hypothesis":"Cache","experiments":[{"expected_result":"Fresh reads"}]}`,
+	} {
+		rec := postThinking(h.ScientificMethod, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+
+	_, err := h.storage.GetSession("lab")
+	assert.Error(t, err)
+}
+
+func TestCollaborativeReasoning_RecordsDebate(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	rec := postThinking(h.CollaborativeReasoning, `{
+		"session_id": "debate",
+		"topic": "Adopt a monorepo",
+		"personas": [
+			{"name": "platform", "stance": "for"},
+			{"name": "mobile", "stance": "against"}
+		],
+		"contributions": [
+			{"persona": "platform", "statement": "Shared tooling gets simpler"},
+			{"persona": "mobile", "statement": "Our release cadence differs"},
+			{"persona": "platform", "statement": "Release trains can stay separate"}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"persona_count":2`)
+	assert.Contains(t, rec.Body.String(), `"contribution_count":3`)
+
+	models, err := h.storage.GetMentalModels("debate")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	debate := models[0]
+	assert.Equal(t, types.CollaborativeReasoningModelName, debate.ModelName)
+	assert.Equal(t, "Adopt a monorepo", debate.Problem)
+	assert.Equal(t, []types.Persona{{Name: "platform", Stance: "for"}, {Name: "mobile", Stance: "against"}}, debate.Personas)
+	require.Len(t, debate.Contributions, 3)
+	assert.Equal(t, types.Contribution{Persona: "mobile", Statement: "Our release cadence differs"}, debate.Contributions[1])
+}
+
+func TestCollaborativeReasoning_RequiresTwoPersonas(t *testing.T) {
+	h := newTestThinkingHandler(t, true)
+
+	for name, body := range map[string]string{
+		"one persona":       `{"session_id":"debate","topic":"Monorepo","personas":[{"name":"platform","stance":"for"}]}`,
+		"no personas":       `{"session_id":"debate","topic":"Monorepo"}`,
+		"duplicate persona": `{"session_id":"debate","topic":"Monorepo","personas":[{"name":"a"},{"name":"a"}]}`,
+		"unknown speaker":   `{"session_id":"debate","topic":"Monorepo","personas":[{"name":"a"},{"name":"b"}],"contributions":[{"persona":"c","statement":"Hi"}]}`,
+	} {
+		rec := postThinking(h.CollaborativeReasoning, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+
+	_, err := h.storage.GetSession("debate")
+	assert.Error(t, err)
+}
+
+func TestMentalModel_NormalizesConfidence(t *testing.T) {
+	for scale, tc := range map[string]struct {
+		given, outOfRange string
+	}{
+		types.ConfidenceScaleFraction: {given: "0.8", outOfRange: "80"},
+		types.ConfidenceScalePercent:  {given: "80", outOfRange: "150"},
+	} {
+		h := newTestThinkingHandler(t, true)
+		h.confidenceScale = scale
+
+		rec := postThinking(h.MentalModel, `{"session_id":"scaled","model_name":"first_principles","problem":"Build or buy","confidence":`+tc.given+`}`)
+		require.Equal(t, http.StatusOK, rec.Code, scale, rec.Body.String())
+		models, err := h.storage.GetMentalModels("scaled")
+		require.NoError(t, err)
+		require.Len(t, models, 1)
+		assert.Equal(t, 0.8, models[0].Confidence, scale)
+
+		rec = postThinking(h.MentalModel, `{"session_id":"scaled","model_name":"first_principles","problem":"Hire or train","confidence":`+tc.outOfRange+`}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, scale)
+		assert.Contains(t, rec.Body.String(), "Invalid confidence", scale)
+		models, err = h.storage.GetMentalModels("scaled")
+		require.NoError(t, err)
+		assert.Len(t, models, 1, scale)
+	}
+}
+
+func TestMentalModel_CooldownRejectedWithPriorApplication(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ModelCooldown = time.Minute
+	h := newConfiguredThinkingHandler(t, cfg)
+
+	body := `{"session_id":"cooling","model_name":"first_principles","problem":"Build or buy"}`
+	rec := postThinking(h.MentalModel, body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var first struct {
+		ModelID string `json:"model_id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &first))
+
+	rec = postThinking(h.MentalModel, body)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	var response struct {
+		PriorID           string  `json:"prior_id"`
+		RetryAfterSeconds float64 `json:"retry_after_seconds"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, first.ModelID, response.PriorID)
+	assert.InDelta(t, 60, response.RetryAfterSeconds, 1)
+
+	rec = postThinking(h.DebuggingApproach, `{"session_id":"cooling","approach_name":"binary_search","issue":"Flaky test"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = postThinking(h.DebuggingApproach, `{"session_id":"cooling","approach_name":"binary_search","issue":"Flaky test"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestDebuggingApproach_ClientErrorsAreConflicts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxMentalModelsPerSession = 1
+	h := newConfiguredThinkingHandler(t, cfg)
+
+	body := `{"session_id":"full","approach_name":"binary_search","issue":"Flaky test"}`
+	require.Equal(t, http.StatusOK, postThinking(h.DebuggingApproach, body).Code)
+	rec := postThinking(h.DebuggingApproach, `{"session_id":"full","approach_name":"rubber_duck","issue":"Slow start"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "mental model limit reached")
+
+	_, err := h.storage.CreateSession("archived")
+	require.NoError(t, err)
+	require.NoError(t, h.storage.ArchiveSession("archived"))
+	rec = postThinking(h.DebuggingApproach, `{"session_id":"archived","approach_name":"binary_search","issue":"Flaky test"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = postThinking(h.MentalModel, `{"session_id":"archived","model_name":"first_principles","problem":"Build or buy"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
@@ -0,0 +1,169 @@
+package jsonstyle
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Field name styles selectable via config.JSONFieldStyle
+const (
+	Snake = "snake"
+	Camel = "camel"
+)
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Validate reports whether a style is supported (empty selects snake_case)
+func Validate(style string) error {
+	switch style {
+	case "", Snake, Camel:
+		return nil
+	default:
+		return fmt.Errorf("unknown json field style %q (expected %s or %s)", style, Snake, Camel)
+	}
+}
+
+// Marshal encodes v as JSON with field names in the given style. Struct
+// fields and the keys of map[string]interface{} values, which responses use
+// as ad-hoc objects, are renamed; keys of typed maps such as session
+// metadata are data and are left untouched.
+func Marshal(v interface{}, style string) ([]byte, error) {
+	if err := Validate(style); err != nil {
+		return nil, err
+	}
+	if style != Camel {
+		return json.Marshal(v)
+	}
+	return json.Marshal(convert(reflect.ValueOf(v)))
+}
+
+// CamelCase converts a snake_case name to camelCase
+func CamelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// convert rebuilds a value as plain maps and slices with camelCase field names
+func convert(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	// Types with their own encoding, such as time.Time, are kept as is
+	if v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil
+		}
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return convert(v.Elem())
+	case reflect.Struct:
+		object := make(map[string]interface{})
+		convertFields(v, object)
+		return object
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		renameKeys := v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.Interface
+		object := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if renameKeys {
+				key = CamelCase(key)
+			}
+			object[key] = convert(iter.Value())
+		}
+		return object
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = convert(v.Index(i))
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+// convertFields adds a struct's exported fields to object, honoring json
+// tags and flattening embedded structs the way encoding/json does
+func convertFields(v reflect.Value, object map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		value := v.Field(i)
+		if field.Anonymous && name == "" {
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				convertFields(value, object)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if strings.Contains(options, "omitempty") && isEmpty(value) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		object[CamelCase(name)] = convert(value)
+	}
+}
+
+// isEmpty matches the values encoding/json drops for omitempty fields
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
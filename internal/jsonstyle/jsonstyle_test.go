@@ -0,0 +1,75 @@
+package jsonstyle
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleResponse mirrors a tool response: an ad-hoc object holding a struct
+// with nested, omitted and data-keyed fields
+func sampleResponse() map[string]interface{} {
+	revises := 1
+	return map[string]interface{}{
+		"status":     "success",
+		"session_id": "s1",
+		"thought": &types.ThoughtData{
+			ID:             "t1",
+			Thought:        "Rethink",
+			ThoughtNumber:  2,
+			IsRevision:     true,
+			RevisesThought: &revises,
+			CreatedAt:      time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		"session_context": map[string]interface{}{"remaining_thoughts": 98},
+		"metadata":        map[string]string{"ticket_id": "T-1"},
+	}
+}
+
+func TestMarshal_Snake(t *testing.T) {
+	data, err := Marshal(sampleResponse(), Snake)
+	require.NoError(t, err)
+
+	expected, err := json.Marshal(sampleResponse())
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(data))
+	assert.Contains(t, string(data), `"session_id":"s1"`)
+}
+
+func TestMarshal_Camel(t *testing.T) {
+	data, err := Marshal(sampleResponse(), Camel)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"status": "success",
+		"sessionId": "s1",
+		"thought": {
+			"id": "t1",
+			"thought": "Rethink",
+			"thoughtNumber": 2,
+			"totalThoughts": 0,
+			"nextThoughtNeeded": false,
+			"isRevision": true,
+			"revisesThought": 1,
+			"createdAt": "2025-01-02T03:04:05Z"
+		},
+		"sessionContext": {"remainingThoughts": 98},
+		"metadata": {"ticket_id": "T-1"}
+	}`, string(data))
+}
+
+func TestMarshal_UnknownStyle(t *testing.T) {
+	_, err := Marshal(sampleResponse(), "kebab")
+	assert.Error(t, err)
+	assert.NoError(t, Validate(""))
+}
+
+func TestCamelCase(t *testing.T) {
+	assert.Equal(t, "sessionId", CamelCase("session_id"))
+	assert.Equal(t, "status", CamelCase("status"))
+	assert.Equal(t, "thoughtsPerMinute", CamelCase("thoughts_per_minute"))
+}
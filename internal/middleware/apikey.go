@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// API key scopes, from least to most privileged
+const (
+	// ScopeRead allows read-only tools and endpoints
+	ScopeRead = "read"
+	// ScopeWrite allows tools that change sessions, and implies read
+	ScopeWrite = "write"
+	// ScopeAdmin allows everything, including operator tools
+	ScopeAdmin = "admin"
+)
+
+// maxInspectedBody bounds how much of a request body is read to find the
+// tools it calls
+const maxInspectedBody = 1 << 20
+
+// rpcCall is the part of a JSON-RPC request needed to find the tool called
+type rpcCall struct {
+	Method string   `json:"method"`
+	Params ToolCall `json:"params"`
+}
+
+// ToolCall is an MCP tool call found in a request body
+type ToolCall struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// APIKeyAuth requires a configured key in the X-API-Key header or as an
+// Authorization bearer token. MCP tool calls need the scope scopeFor reports
+// for the call, which may depend on its arguments; every other request needs
+// read. Unknown keys get 401 and
+// missing scopes 403. Paths starting with an exempt prefix are served without
+// a key, and no keys disables authentication.
+func APIKeyAuth(keys map[string][]string, scopeFor func(call ToolCall) string, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(keys) == 0 || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, prefix := range exempt {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			key := requestKey(r)
+			scopes, ok := keys[key]
+			if key == "" || !ok {
+				http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			calls, err := calledTools(r)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if len(calls) == 0 && !Allows(scopes, ScopeRead) {
+				http.Error(w, "API key lacks the read scope", http.StatusForbidden)
+				return
+			}
+			for _, call := range calls {
+				if required := scopeFor(call); !Allows(scopes, required) {
+					http.Error(w, "API key lacks the "+required+" scope required by tool "+call.Name, http.StatusForbidden)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope limits a handler to API keys granted scope. It runs behind
+// APIKeyAuth, which has already rejected unknown keys, and no keys disables it.
+func RequireScope(keys map[string][]string, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(keys) > 0 && !Allows(keys[requestKey(r)], scope) {
+				http.Error(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Allows reports whether granted scopes satisfy a required one: admin grants
+// everything and write also grants read
+func Allows(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == ScopeAdmin || scope == required || (scope == ScopeWrite && required == ScopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestKey returns the API key presented by a request, if any
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// calledTools returns the tools/call requests in a JSON-RPC body, single or
+// batched, restoring the body for the next handler
+func calledTools(r *http.Request) ([]ToolCall, error) {
+	if r.Body == nil || r.Method != http.MethodPost {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInspectedBody+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxInspectedBody {
+		return nil, io.ErrShortBuffer
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	var calls []rpcCall
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &calls); err != nil {
+			return nil, err
+		}
+	} else {
+		var call rpcCall
+		if err := json.Unmarshal(trimmed, &call); err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+
+	var tools []ToolCall
+	for _, call := range calls {
+		if call.Method == "tools/call" {
+			tools = append(tools, call.Params)
+		}
+	}
+	return tools, nil
+}
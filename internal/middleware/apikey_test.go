@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeys = map[string][]string{
+	"reader": {ScopeRead},
+	"writer": {ScopeWrite},
+	"admin":  {ScopeAdmin},
+}
+
+var testScopes = map[string]string{
+	"session_stats":       ScopeRead,
+	"sequential_thinking": ScopeWrite,
+	"purge_inactive":      ScopeAdmin,
+}
+
+func newAuthHandler() http.Handler {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The inspected body is still readable downstream
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	scopeFor := func(call ToolCall) string { return testScopes[call.Name] }
+	return APIKeyAuth(testKeys, scopeFor, "/health")(next)
+}
+
+func callTool(h http.Handler, key, tool string) *httptest.ResponseRecorder {
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tool + `","arguments":{}}}`
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyAuth_ReadOnlyKeyBlockedFromWriteTool(t *testing.T) {
+	h := newAuthHandler()
+
+	assert.Equal(t, http.StatusOK, callTool(h, "reader", "session_stats").Code)
+
+	rec := callTool(h, "reader", "sequential_thinking")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "write scope required by tool sequential_thinking")
+
+	assert.Equal(t, http.StatusForbidden, callTool(h, "reader", "purge_inactive").Code)
+
+	// Batches need the scope of every tool they call
+	batch := `[{"method":"tools/call","params":{"name":"session_stats"}},{"method":"tools/call","params":{"name":"sequential_thinking"}}]`
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(batch))
+	req.Header.Set("X-API-Key", "reader")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestAPIKeyAuth_AdminKeyAllowedEverywhere(t *testing.T) {
+	h := newAuthHandler()
+
+	for tool := range testScopes {
+		rec := callTool(h, "admin", tool)
+		assert.Equal(t, http.StatusOK, rec.Code, tool)
+		assert.Contains(t, rec.Body.String(), tool)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sse", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAPIKeyAuth_WriteKeyAndUnknownKeys(t *testing.T) {
+	h := newAuthHandler()
+
+	assert.Equal(t, http.StatusOK, callTool(h, "writer", "sequential_thinking").Code)
+	assert.Equal(t, http.StatusOK, callTool(h, "writer", "session_stats").Code)
+	assert.Equal(t, http.StatusForbidden, callTool(h, "writer", "purge_inactive").Code)
+
+	assert.Equal(t, http.StatusUnauthorized, callTool(h, "", "session_stats").Code)
+	assert.Equal(t, http.StatusUnauthorized, callTool(h, "stolen", "session_stats").Code)
+
+	// Bearer tokens are checked like X-API-Key; other schemes carry no key
+	for _, authorization := range []string{"Bearer stolen", "Bearer ", "Basic admin", "admin"} {
+		req := httptest.NewRequest(http.MethodGet, "/sse", nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+	}
+
+	// Exempt paths and deployments without keys need no key
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	open := APIKeyAuth(nil, func(ToolCall) string { return ScopeAdmin })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	assert.Equal(t, http.StatusOK, callTool(open, "", "purge_inactive").Code)
+}
+
+func TestAPIKeyAuth_EmptyKeyNeverMatches(t *testing.T) {
+	keys := map[string][]string{"": {ScopeAdmin}, "writer": {ScopeWrite}}
+	scopeFor := func(call ToolCall) string { return testScopes[call.Name] }
+	h := APIKeyAuth(keys, scopeFor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert.Equal(t, http.StatusUnauthorized, callTool(h, "", "purge_inactive").Code)
+	assert.Equal(t, http.StatusOK, callTool(h, "writer", "sequential_thinking").Code)
+}
+
+func TestAPIKeyAuth_ScopeSeesToolArguments(t *testing.T) {
+	scopeFor := func(call ToolCall) string {
+		if call.Arguments["session_id"] == nil {
+			return ScopeAdmin
+		}
+		return ScopeRead
+	}
+	h := APIKeyAuth(testKeys, scopeFor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	call := func(arguments string) int {
+		body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"pending_thoughts","arguments":` + arguments + `}}`
+		req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "reader")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, call(`{"session_id":"mine"}`))
+	assert.Equal(t, http.StatusForbidden, call(`{}`))
+}
+
+func TestRequireScope(t *testing.T) {
+	h := APIKeyAuth(testKeys, func(ToolCall) string { return ScopeRead })(
+		RequireScope(testKeys, ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	get := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, get("reader"))
+	assert.Equal(t, http.StatusForbidden, get("writer"))
+	assert.Equal(t, http.StatusOK, get("admin"))
+
+	open := RequireScope(nil, ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	open.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
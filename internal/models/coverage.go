@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// Where CheckCoverage found a step addressed
+const (
+	EvidenceSteps      = "steps"
+	EvidenceReasoning  = "reasoning"
+	EvidenceConclusion = "conclusion"
+)
+
+// minKeywordLength skips short words such as "the" or "it" when comparing
+// step texts
+const minKeywordLength = 4
+
+// wordPattern splits text into lowercase words
+var wordPattern = regexp.MustCompile(`[a-z0-9]+`)
+
+// CheckCoverage reports which of a model's defined steps an application
+// addressed. A step counts as addressed when one of the application's own
+// steps, its reasoning or its conclusion contains the step text or at least
+// half of its keywords; the reasoning and conclusion may also cite a step by
+// number, e.g. "step 2".
+func CheckCoverage(model MentalModel, application *types.MentalModelData) types.ModelCoverage {
+	coverage := types.ModelCoverage{
+		ModelID:   application.ID,
+		ModelName: application.ModelName,
+		Steps:     make([]types.StepCoverage, 0, len(model.Steps)),
+	}
+
+	for i, step := range model.Steps {
+		stepCoverage := types.StepCoverage{Number: i + 1, Step: step}
+		stepCoverage.Evidence = findEvidence(i+1, step, application)
+		stepCoverage.Addressed = stepCoverage.Evidence != ""
+		if stepCoverage.Addressed {
+			coverage.Addressed++
+		} else {
+			coverage.Unaddressed++
+		}
+		coverage.Steps = append(coverage.Steps, stepCoverage)
+	}
+
+	if len(model.Steps) > 0 {
+		percent := float64(coverage.Addressed) / float64(len(model.Steps)) * 100
+		coverage.CoveragePercent = math.Round(percent*10) / 10
+	}
+	return coverage
+}
+
+// findEvidence returns where an application addresses a defined step, or
+// an empty string when it does not
+func findEvidence(number int, step string, application *types.MentalModelData) string {
+	for _, applied := range application.Steps {
+		if mentionsStep(applied, step) {
+			return EvidenceSteps
+		}
+	}
+
+	reference := regexp.MustCompile(fmt.Sprintf(`(?i)\bstep\s*#?%d\b`, number))
+	if mentionsStep(application.Reasoning, step) || reference.MatchString(application.Reasoning) {
+		return EvidenceReasoning
+	}
+	if mentionsStep(application.Conclusion, step) || reference.MatchString(application.Conclusion) {
+		return EvidenceConclusion
+	}
+	return ""
+}
+
+// mentionsStep reports whether text contains a step verbatim or at least
+// half of its keywords
+func mentionsStep(text, step string) bool {
+	text = strings.ToLower(text)
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	if strings.Contains(text, strings.ToLower(step)) {
+		return true
+	}
+
+	stepKeywords := keywords(step)
+	if len(stepKeywords) == 0 {
+		return false
+	}
+	textKeywords := keywords(text)
+	shared := 0
+	for keyword := range stepKeywords {
+		if _, exists := textKeywords[keyword]; exists {
+			shared++
+		}
+	}
+	return shared*2 >= len(stepKeywords)
+}
+
+// keywords returns the distinct words of text long enough to be meaningful
+func keywords(text string) map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if len(word) >= minKeywordLength {
+			words[word] = struct{}{}
+		}
+	}
+	return words
+}
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
 
+	"github.com/rainmana/gothink/internal/config"
 	"github.com/rainmana/gothink/internal/types"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -24,8 +27,18 @@ type MentalModel struct {
 	Steps       []string `yaml:"steps" json:"steps"`
 	Category    string   `yaml:"category" json:"category"`
 	Priority    int      `yaml:"priority,omitempty" json:"priority,omitempty"`
+	// ComposedOf lists model keys whose steps are applied, in order, before this model's own steps
+	ComposedOf []string `yaml:"composed_of,omitempty" json:"composed_of,omitempty"`
+	// Source is SourceCore for built-in models or the file a custom model was loaded from
+	Source string `yaml:"-" json:"source"`
 }
 
+// SourceCore is the source reported for built-in mental models
+const SourceCore = "core"
+
+// SourceRegistered is the source reported for models added with RegisterModels
+const SourceRegistered = "registered"
+
 // MentalModelWithKey represents a mental model with its key for sorting
 type MentalModelWithKey struct {
 	Key   string
@@ -35,6 +48,38 @@ type MentalModelWithKey struct {
 // Loader handles loading and managing mental models
 type Loader struct {
 	logger *logrus.Logger
+
+	// allowedCategories restricts loaded models to these categories (nil allows all)
+	allowedCategories map[string]bool
+
+	// defaultCategory is applied to models missing a category (empty rejects them)
+	defaultCategory string
+
+	// path is the custom mental models file or directory
+	path string
+
+	// caseInsensitive makes Lookup ignore case when matching model names
+	caseInsensitive bool
+
+	// inheritCoreSteps gives overrides of core models without steps the core steps
+	inheritCoreSteps bool
+
+	// cacheMutex guards the cached model set and the settings it was built from
+	cacheMutex sync.RWMutex
+	cache      map[string]MentalModel
+
+	// registeredMutex guards models added with RegisterModels; it is taken
+	// after cacheMutex when both are held
+	registeredMutex sync.RWMutex
+	registered      map[string]MentalModel
+}
+
+// ReloadResult describes how the cached model set changed during a reload
+type ReloadResult struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+	Total   int      `json:"total"`
 }
 
 // NewLoader creates a new mental models loader
@@ -44,8 +89,263 @@ func NewLoader(logger *logrus.Logger) *Loader {
 	}
 }
 
-// LoadMentalModels loads mental models from core types and optional custom YAML file
+// Configure applies loader-related settings from the server configuration
+// and clears the cached model set
+func (l *Loader) Configure(cfg *config.Config) {
+	l.cacheMutex.Lock()
+	defer l.cacheMutex.Unlock()
+
+	l.applySettings(cfg)
+	l.cache = nil
+}
+
+// applySettings copies loader settings from the server configuration
+func (l *Loader) applySettings(cfg *config.Config) {
+	l.path = cfg.MentalModelsPath
+	l.caseInsensitive = cfg.CaseInsensitiveNames
+	l.inheritCoreSteps = cfg.InheritCoreSteps
+
+	l.allowedCategories = nil
+	if len(cfg.AllowedCategories) > 0 {
+		l.allowedCategories = make(map[string]bool, len(cfg.AllowedCategories))
+		for _, category := range cfg.AllowedCategories {
+			l.allowedCategories[strings.TrimSpace(category)] = true
+		}
+	}
+
+	l.defaultCategory = ""
+	if !cfg.StrictModelValidation {
+		l.defaultCategory = strings.TrimSpace(cfg.DefaultCategory)
+	}
+}
+
+// Models returns the cached model set, loading it from the configured path
+// on first use. The returned map is shared and must not be modified.
+func (l *Loader) Models() (map[string]MentalModel, error) {
+	l.cacheMutex.RLock()
+	models := l.cache
+	l.cacheMutex.RUnlock()
+	if models != nil {
+		return models, nil
+	}
+
+	l.cacheMutex.Lock()
+	defer l.cacheMutex.Unlock()
+
+	if l.cache == nil {
+		models, err := l.LoadMentalModels(l.path)
+		if err != nil {
+			return nil, err
+		}
+		l.cache = models
+	}
+
+	return l.cache, nil
+}
+
+// Path returns the configured custom mental models path (empty when only core
+// models are used)
+func (l *Loader) Path() string {
+	l.cacheMutex.RLock()
+	defer l.cacheMutex.RUnlock()
+	return l.path
+}
+
+// CountBySource splits a model set into built-in core models and custom ones
+func (l *Loader) CountBySource(models map[string]MentalModel) (core, custom int) {
+	for _, model := range models {
+		if model.Source == SourceCore {
+			core++
+		} else {
+			custom++
+		}
+	}
+	return core, custom
+}
+
+// Reload rebuilds the cached model set from a new configuration. Unlike
+// LoadMentalModels, a custom models file that fails to load is an error, in
+// which case the previous settings and model set are kept.
+func (l *Loader) Reload(cfg *config.Config) (*ReloadResult, error) {
+	candidate := NewLoader(l.logger)
+	candidate.applySettings(cfg)
+	candidate.registered = l.registeredModels()
+
+	models, err := candidate.loadMentalModels(candidate.path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	l.cacheMutex.Lock()
+	defer l.cacheMutex.Unlock()
+
+	previous := l.cache
+	l.path = candidate.path
+	l.caseInsensitive = candidate.caseInsensitive
+	l.inheritCoreSteps = candidate.inheritCoreSteps
+	l.allowedCategories = candidate.allowedCategories
+	l.defaultCategory = candidate.defaultCategory
+	l.cache = models
+
+	return diffModels(previous, models), nil
+}
+
+// RegisterModels validates models supplied by an embedding program and merges
+// them into the model set, so every later load includes them. Registered
+// models take precedence over custom models from the models path, which in
+// turn override core models; registering a key again replaces it. Keys are
+// normalized as for model files, and ComposedOf references must resolve.
+func (l *Loader) RegisterModels(models map[string]MentalModel) error {
+	incoming := make(map[string]MentalModel, len(models))
+	for key, model := range models {
+		key = NormalizeName(key)
+		if key == "" {
+			return fmt.Errorf("model key must not be empty")
+		}
+		model.Steps = append([]string(nil), model.Steps...)
+		model.ComposedOf = append([]string(nil), model.ComposedOf...)
+		incoming[key] = model
+	}
+	if err := l.validateModels(incoming); err != nil {
+		return fmt.Errorf("invalid mental models: %w", err)
+	}
+
+	// Check compositions against the current set with these models applied
+	current, err := l.Models()
+	if err != nil {
+		return err
+	}
+	candidate := make(map[string]MentalModel, len(current)+len(incoming))
+	for key, model := range current {
+		candidate[key] = model
+	}
+	for key, model := range incoming {
+		model.Source = SourceRegistered
+		incoming[key] = model
+		candidate[key] = model
+	}
+	if err := resolveCompositions(candidate); err != nil {
+		return fmt.Errorf("invalid mental model composition: %w", err)
+	}
+
+	l.registeredMutex.Lock()
+	if l.registered == nil {
+		l.registered = make(map[string]MentalModel, len(incoming))
+	}
+	for key, model := range incoming {
+		l.registered[key] = model
+	}
+	l.registeredMutex.Unlock()
+
+	// Rebuild the cached set on next use
+	l.cacheMutex.Lock()
+	l.cache = nil
+	l.cacheMutex.Unlock()
+
+	l.logger.Infof("Registered %d mental models", len(incoming))
+	return nil
+}
+
+// registeredModels returns a copy of the models added with RegisterModels
+func (l *Loader) registeredModels() map[string]MentalModel {
+	l.registeredMutex.RLock()
+	defer l.registeredMutex.RUnlock()
+
+	registered := make(map[string]MentalModel, len(l.registered))
+	for key, model := range l.registered {
+		registered[key] = model
+	}
+	return registered
+}
+
+// NormalizeName trims a model or approach name and collapses inner whitespace
+func NormalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Lookup resolves a client-supplied model name, returning the model and its
+// canonical key. Names are normalized first and, when configured, matched
+// regardless of case.
+func (l *Loader) Lookup(models map[string]MentalModel, name string) (string, MentalModel, bool) {
+	name = NormalizeName(name)
+	if model, exists := models[name]; exists {
+		return name, model, true
+	}
+
+	l.cacheMutex.RLock()
+	caseInsensitive := l.caseInsensitive
+	l.cacheMutex.RUnlock()
+	if !caseInsensitive {
+		return name, MentalModel{}, false
+	}
+
+	// Prefer the lexically smallest key if several differ only by case
+	match := ""
+	for key := range models {
+		if strings.EqualFold(key, name) && (match == "" || key < match) {
+			match = key
+		}
+	}
+	if match == "" {
+		return name, MentalModel{}, false
+	}
+	return match, models[match], true
+}
+
+// NormalizeApproachName normalizes a debugging approach name, lowercasing it
+// when names are case-insensitive
+func (l *Loader) NormalizeApproachName(name string) string {
+	name = NormalizeName(name)
+
+	l.cacheMutex.RLock()
+	defer l.cacheMutex.RUnlock()
+	if l.caseInsensitive {
+		name = strings.ToLower(name)
+	}
+	return name
+}
+
+// diffModels reports the keys added, removed and changed between two model sets
+func diffModels(previous, current map[string]MentalModel) *ReloadResult {
+	result := &ReloadResult{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+		Total:   len(current),
+	}
+
+	for key, model := range current {
+		old, exists := previous[key]
+		switch {
+		case !exists:
+			result.Added = append(result.Added, key)
+		case !reflect.DeepEqual(old, model):
+			result.Changed = append(result.Changed, key)
+		}
+	}
+	for key := range previous {
+		if _, exists := current[key]; !exists {
+			result.Removed = append(result.Removed, key)
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Changed)
+
+	return result
+}
+
+// LoadMentalModels loads mental models from core types, the optional custom
+// YAML file and any models added with RegisterModels
 func (l *Loader) LoadMentalModels(configPath string) (map[string]MentalModel, error) {
+	return l.loadMentalModels(configPath, false)
+}
+
+// loadMentalModels merges custom and then registered models over the core
+// set. When strict is false a custom models failure is logged and the core
+// and registered models are returned.
+func (l *Loader) loadMentalModels(configPath string, strict bool) (map[string]MentalModel, error) {
 	// Start with core models (always available as fallback)
 	models := make(map[string]MentalModel)
 
@@ -57,29 +357,128 @@ func (l *Loader) LoadMentalModels(configPath string) (map[string]MentalModel, er
 			Steps:       coreModel.Steps,
 			Category:    coreModel.Category,
 			Priority:    0, // Core models have default priority
+			Source:      SourceCore,
 		}
 	}
 
 	l.logger.Infof("Loaded %d core mental models", len(models))
 
 	// Load custom models if file exists
+	var customModels map[string]MentalModel
 	if configPath != "" {
-		customModels, err := l.loadCustomModels(configPath)
+		loaded, err := l.loadCustomModels(configPath)
+		if err != nil && strict {
+			return nil, fmt.Errorf("failed to load custom mental models from %s: %w", configPath, err)
+		}
 		if err != nil {
 			l.logger.Warnf("Failed to load custom mental models from %s: %v", configPath, err)
 			// Continue with core models only
 		} else {
-			// Merge custom models (they can override core models)
+			customModels = loaded
+		}
+	}
+	registered := l.registeredModels()
+
+	// Merge custom models, then registered ones (they can override core models)
+	if len(customModels) > 0 || len(registered) > 0 {
+		merged := make(map[string]MentalModel, len(models)+len(customModels)+len(registered))
+		for key, model := range models {
+			merged[key] = model
+		}
+		for key, model := range customModels {
+			merged[key] = model
+		}
+		for key, model := range registered {
+			merged[key] = model
+		}
+
+		if err := resolveCompositions(merged); err != nil {
+			if strict {
+				return nil, fmt.Errorf("invalid mental model composition in %s: %w", configPath, err)
+			}
+			l.logger.Warnf("Invalid mental model composition in %s: %v", configPath, err)
+		} else {
 			for key, model := range customModels {
-				models[key] = model
-				l.logger.Infof("Loaded custom mental model: %s (priority: %d)", key, model.Priority)
+				l.logger.Infof("Loaded custom mental model: %s (priority: %d, source: %s)", key, model.Priority, model.Source)
 			}
+			models = merged
 		}
 	}
 
+	l.filterCategories(models)
+
 	return models, nil
 }
 
+// resolveCompositions expands every composed model's steps into the steps of
+// the models it references, in order, followed by its own. References must
+// name existing models and must not form a cycle.
+func resolveCompositions(models map[string]MentalModel) error {
+	resolved := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var resolve func(key string, path []string) error
+	resolve = func(key string, path []string) error {
+		if resolved[key] {
+			return nil
+		}
+		path = append(path, key)
+		if visiting[key] {
+			return fmt.Errorf("composition cycle: %s", strings.Join(path, " -> "))
+		}
+		visiting[key] = true
+
+		model := models[key]
+		if len(model.ComposedOf) > 0 {
+			var steps []string
+			for _, component := range model.ComposedOf {
+				component = NormalizeName(component)
+				if _, exists := models[component]; !exists {
+					return fmt.Errorf("model '%s' is composed of unknown model '%s'", key, component)
+				}
+				if err := resolve(component, path); err != nil {
+					return err
+				}
+				steps = append(steps, models[component].Steps...)
+			}
+			model.Steps = append(steps, model.Steps...)
+			models[key] = model
+		}
+
+		visiting[key] = false
+		resolved[key] = true
+		return nil
+	}
+
+	// Resolve in key order so errors are reported deterministically
+	keys := make([]string, 0, len(models))
+	for key := range models {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := resolve(key, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// filterCategories drops models whose category is not in the allowlist
+func (l *Loader) filterCategories(models map[string]MentalModel) {
+	if l.allowedCategories == nil {
+		return
+	}
+
+	for key, model := range models {
+		if !l.allowedCategories[model.Category] {
+			l.logger.Warnf("Excluding mental model %s: category '%s' is not allowed", key, model.Category)
+			delete(models, key)
+		}
+	}
+}
+
 // loadCustomModels loads mental models from a YAML file or directory
 func (l *Loader) loadCustomModels(path string) (map[string]MentalModel, error) {
 	// Check if path exists
@@ -141,7 +540,14 @@ func (l *Loader) loadModelsFromFile(filePath string) (map[string]MentalModel, er
 		return nil, fmt.Errorf("invalid mental models configuration: %w", err)
 	}
 
-	return config.Models, nil
+	// Record where each model was defined, keyed by its normalized name
+	models := make(map[string]MentalModel, len(config.Models))
+	for key, model := range config.Models {
+		model.Source = filePath
+		models[NormalizeName(key)] = model
+	}
+
+	return models, nil
 }
 
 // validateModels validates the mental models configuration
@@ -154,11 +560,20 @@ func (l *Loader) validateModels(models map[string]MentalModel) error {
 		if strings.TrimSpace(model.Description) == "" {
 			return fmt.Errorf("model '%s' has empty description", key)
 		}
-		if len(model.Steps) == 0 {
-			return fmt.Errorf("model '%s' has no steps", key)
+		if len(model.Steps) == 0 && len(model.ComposedOf) == 0 {
+			coreModel, overridesCore := types.MentalModels[NormalizeName(key)]
+			if !l.inheritCoreSteps || !overridesCore {
+				return fmt.Errorf("model '%s' has no steps", key)
+			}
+			l.logger.Infof("Model '%s' has no steps, inheriting the core model's", key)
+			model.Steps = append([]string(nil), coreModel.Steps...)
 		}
 		if strings.TrimSpace(model.Category) == "" {
-			return fmt.Errorf("model '%s' has empty category", key)
+			if l.defaultCategory == "" {
+				return fmt.Errorf("model '%s' has empty category", key)
+			}
+			l.logger.Warnf("Model '%s' has no category, defaulting to '%s'", key, l.defaultCategory)
+			model.Category = l.defaultCategory
 		}
 
 		// Validate steps
@@ -170,14 +585,10 @@ func (l *Loader) validateModels(models map[string]MentalModel) error {
 
 		// Set default priority if not specified
 		if model.Priority == 0 {
-			models[key] = MentalModel{
-				Name:        model.Name,
-				Description: model.Description,
-				Steps:       model.Steps,
-				Category:    model.Category,
-				Priority:    1, // Custom models get priority 1 by default
-			}
+			model.Priority = 1 // Custom models get priority 1 by default
 		}
+
+		models[key] = model
 	}
 
 	return nil
@@ -230,6 +641,39 @@ func (l *Loader) GetModelsByCategory(models map[string]MentalModel) map[string][
 	return categories
 }
 
+// CategoryModels is one category of models in GetSortedCategories
+type CategoryModels struct {
+	Category string               `json:"category"`
+	Models   []MentalModelWithKey `json:"models"`
+}
+
+// GetSortedCategories returns models grouped by category, with categories in
+// alphabetical order and each category's models by priority (highest first),
+// then by name and key, so the order is the same on every call
+func (l *Loader) GetSortedCategories(models map[string]MentalModel) []CategoryModels {
+	byCategory := l.GetModelsByCategory(models)
+
+	categories := make([]CategoryModels, 0, len(byCategory))
+	for category, categoryModels := range byCategory {
+		sort.Slice(categoryModels, func(i, j int) bool {
+			a, b := categoryModels[i], categoryModels[j]
+			if a.Model.Priority != b.Model.Priority {
+				return a.Model.Priority > b.Model.Priority
+			}
+			if a.Model.Name != b.Model.Name {
+				return a.Model.Name < b.Model.Name
+			}
+			return a.Key < b.Key
+		})
+		categories = append(categories, CategoryModels{Category: category, Models: categoryModels})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Category < categories[j].Category
+	})
+
+	return categories
+}
+
 // GetAvailableModels returns a list of available model keys and names
 func (l *Loader) GetAvailableModels(models map[string]MentalModel) []string {
 	var available []string
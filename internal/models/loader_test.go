@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/types"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -332,6 +334,38 @@ func TestGetModelsByCategory(t *testing.T) {
 	assert.Equal(t, "custom_1", custom[0].Key)
 }
 
+func TestGetSortedCategories(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	models := map[string]MentalModel{
+		"zeta":       {Name: "Zeta", Category: "systems", Priority: 1},
+		"analysis_b": {Name: "Shared Name", Category: "analytical", Priority: 2},
+		"analysis_a": {Name: "Shared Name", Category: "analytical", Priority: 2},
+		"analysis_c": {Name: "Another", Category: "analytical", Priority: 2},
+		"top":        {Name: "Top", Category: "analytical", Priority: 9},
+		"choose":     {Name: "Choose", Category: "decision-making", Priority: 3},
+		"custom":     {Name: "Custom", Category: "custom", Priority: 1},
+	}
+
+	first := loader.GetSortedCategories(models)
+	var names []string
+	for _, category := range first {
+		names = append(names, category.Category)
+	}
+	assert.Equal(t, []string{"analytical", "custom", "decision-making", "systems"}, names)
+
+	var keys []string
+	for _, model := range first[0].Models {
+		keys = append(keys, model.Key)
+	}
+	assert.Equal(t, []string{"top", "analysis_c", "analysis_a", "analysis_b"}, keys)
+
+	// Map iteration order never leaks into the result
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, first, loader.GetSortedCategories(models))
+	}
+}
+
 func TestGetAvailableModels(t *testing.T) {
 	logger := logrus.New()
 	loader := NewLoader(logger)
@@ -494,3 +528,620 @@ models:
 	assert.Contains(t, models, "model_2")
 	assert.Contains(t, models, "first_principles") // Core models should still be there
 }
+
+func TestLoadMentalModels_AllowedCategories(t *testing.T) {
+	logger := logrus.New()
+	loader := NewLoader(logger)
+
+	cfg := config.DefaultConfig()
+	cfg.AllowedCategories = []string{"analytical", "custom"}
+	loader.Configure(cfg)
+
+	yamlContent := `
+models:
+  custom_model:
+    name: "Custom Model"
+    description: "An allowed custom model"
+    steps: ["Step 1"]
+    category: "custom"
+  blocked_model:
+    name: "Blocked Model"
+    description: "A model in a disallowed category"
+    steps: ["Step 1"]
+    category: "problem-solving"
+`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "models.yaml")
+	err := os.WriteFile(configPath, []byte(yamlContent), 0644)
+	require.NoError(t, err)
+
+	models, err := loader.LoadMentalModels(configPath)
+	require.NoError(t, err)
+
+	// Allowed categories are kept
+	assert.Contains(t, models, "first_principles")
+	assert.Contains(t, models, "custom_model")
+
+	// Everything else is dropped, core and custom alike
+	assert.NotContains(t, models, "opportunity_cost")
+	assert.NotContains(t, models, "bayesian_thinking")
+	assert.NotContains(t, models, "systems_thinking")
+	assert.NotContains(t, models, "blocked_model")
+
+	for key, model := range models {
+		assert.Contains(t, cfg.AllowedCategories, model.Category, "model %s", key)
+	}
+}
+
+func TestLoadMentalModels_EmptyAllowedCategories(t *testing.T) {
+	logger := logrus.New()
+	loader := NewLoader(logger)
+	loader.Configure(config.DefaultConfig())
+
+	models, err := loader.LoadMentalModels("")
+	require.NoError(t, err)
+
+	assert.Len(t, models, len(types.MentalModels))
+}
+
+func TestValidateModels_DefaultCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		strict   bool
+		wantErr  bool
+		category string
+	}{
+		{name: "defaults missing category", strict: false, category: "uncategorized"},
+		{name: "strict rejects missing category", strict: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader(logrus.New())
+			cfg := config.DefaultConfig()
+			cfg.StrictModelValidation = tt.strict
+			loader.Configure(cfg)
+
+			models := map[string]MentalModel{
+				"no_category": {
+					Name:        "No Category",
+					Description: "A model that forgot its category",
+					Steps:       []string{"Step 1"},
+				},
+			}
+
+			err := loader.validateModels(models)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "empty category")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.category, models["no_category"].Category)
+			assert.Equal(t, 1, models["no_category"].Priority)
+		})
+	}
+}
+
+func TestLoadMentalModels_DefaultCategoryFromFile(t *testing.T) {
+	loader := NewLoader(logrus.New())
+	cfg := config.DefaultConfig()
+	cfg.DefaultCategory = "misc"
+	loader.Configure(cfg)
+
+	yamlContent := `
+models:
+  forgetful_model:
+    name: "Forgetful Model"
+    description: "Category omitted"
+    steps: ["Step 1"]
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "models.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(yamlContent), 0644))
+
+	models, err := loader.LoadMentalModels(configPath)
+	require.NoError(t, err)
+	require.Contains(t, models, "forgetful_model")
+	assert.Equal(t, "misc", models["forgetful_model"].Category)
+}
+
+const stepslessOverrideYAML = `
+models:
+  first_principles:
+    name: "Team First Principles"
+    description: "Our wording, the core steps"
+    category: "analytical"
+  brand_new_model:
+    name: "Brand New"
+    description: "Not a core model"
+    category: "custom"
+`
+
+func TestLoadMentalModels_StepslessOverrideRejectedByDefault(t *testing.T) {
+	loader := NewLoader(logrus.New())
+	cfg := config.DefaultConfig()
+	assert.False(t, cfg.InheritCoreSteps)
+	cfg.MentalModelsPath = filepath.Join(t.TempDir(), "models.yaml")
+	require.NoError(t, os.WriteFile(cfg.MentalModelsPath, []byte(stepslessOverrideYAML), 0644))
+	loader.Configure(cfg)
+
+	_, err := loader.Reload(cfg)
+	assert.ErrorContains(t, err, "has no steps")
+
+	// The non-strict load falls back to the core definition
+	models, err := loader.Models()
+	require.NoError(t, err)
+	assert.Equal(t, "First Principles Thinking", models["first_principles"].Name)
+}
+
+func TestLoadMentalModels_StepslessOverrideInheritsCoreSteps(t *testing.T) {
+	loader := NewLoader(logrus.New())
+	cfg := config.DefaultConfig()
+	cfg.InheritCoreSteps = true
+	cfg.MentalModelsPath = filepath.Join(t.TempDir(), "models.yaml")
+
+	// Only overrides of core models may inherit; other models still need steps
+	require.NoError(t, os.WriteFile(cfg.MentalModelsPath, []byte(stepslessOverrideYAML), 0644))
+	loader.Configure(cfg)
+	_, err := loader.Reload(cfg)
+	assert.ErrorContains(t, err, "'brand_new_model' has no steps")
+
+	override := "models:\n  first_principles:\n    name: \"Team First Principles\"\n    description: \"Our wording, the core steps\"\n    category: \"analytical\"\n"
+	require.NoError(t, os.WriteFile(cfg.MentalModelsPath, []byte(override), 0644))
+	_, err = loader.Reload(cfg)
+	require.NoError(t, err)
+
+	models, err := loader.Models()
+	require.NoError(t, err)
+	model := models["first_principles"]
+	assert.Equal(t, "Team First Principles", model.Name)
+	assert.Equal(t, types.MentalModels["first_principles"].Steps, model.Steps)
+	assert.Equal(t, cfg.MentalModelsPath, model.Source)
+}
+
+func TestModels_CachedUntilReload(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "mental_models.yaml")
+	writeModel := func(key, name string) {
+		content := "models:\n  " + key + ":\n    name: \"" + name + "\"\n    description: \"Reloadable\"\n    steps:\n      - \"Step 1\"\n    category: \"custom\"\n"
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+	}
+	writeModel("first_custom", "First")
+
+	cfg := config.DefaultConfig()
+	cfg.MentalModelsPath = configPath
+	loader.Configure(cfg)
+
+	models, err := loader.Models()
+	require.NoError(t, err)
+	assert.Contains(t, models, "first_custom")
+
+	// Edits are not visible until the cache is reloaded
+	writeModel("second_custom", "Second")
+	models, err = loader.Models()
+	require.NoError(t, err)
+	assert.NotContains(t, models, "second_custom")
+
+	result, err := loader.Reload(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second_custom"}, result.Added)
+	assert.Equal(t, []string{"first_custom"}, result.Removed)
+	assert.Empty(t, result.Changed)
+
+	models, err = loader.Models()
+	require.NoError(t, err)
+	assert.Contains(t, models, "second_custom")
+	assert.NotContains(t, models, "first_custom")
+	assert.Equal(t, len(models), result.Total)
+}
+
+func TestReload_FailureKeepsPreviousModels(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "mental_models.yaml")
+	content := "models:\n  kept_model:\n    name: \"Kept\"\n    description: \"Survives a bad reload\"\n    steps:\n      - \"Step 1\"\n    category: \"custom\"\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+
+	cfg := config.DefaultConfig()
+	cfg.MentalModelsPath = configPath
+	loader.Configure(cfg)
+	_, err := loader.Models()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("models: [not: valid"), 0644))
+	_, err = loader.Reload(cfg)
+	assert.Error(t, err)
+
+	models, err := loader.Models()
+	require.NoError(t, err)
+	assert.Contains(t, models, "kept_model")
+}
+
+func TestRegisterModels_ResolvedViaLookup(t *testing.T) {
+	loader := NewLoader(logrus.New())
+	loader.Configure(config.DefaultConfig())
+
+	// Load first so registration must invalidate the cached set
+	_, err := loader.Models()
+	require.NoError(t, err)
+
+	require.NoError(t, loader.RegisterModels(map[string]MentalModel{
+		" embedded_model ": {
+			Name:        "Embedded Model",
+			Description: "Injected by an embedding program",
+			Steps:       []string{"Step 1"},
+			Category:    "custom",
+		},
+		"layered_model": {
+			Name:        "Layered Model",
+			Description: "Builds on another registered model",
+			Steps:       []string{"Finish"},
+			Category:    "custom",
+			ComposedOf:  []string{"embedded_model"},
+		},
+	}))
+
+	models, err := loader.Models()
+	require.NoError(t, err)
+	key, model, ok := loader.Lookup(models, "embedded_model")
+	require.True(t, ok)
+	assert.Equal(t, "embedded_model", key)
+	assert.Equal(t, "Embedded Model", model.Name)
+	assert.Equal(t, SourceRegistered, model.Source)
+	assert.Equal(t, 1, model.Priority)
+	assert.Equal(t, []string{"Step 1", "Finish"}, models["layered_model"].Steps)
+
+	loaded, err := loader.LoadMentalModels("")
+	require.NoError(t, err)
+	assert.Contains(t, loaded, "embedded_model")
+}
+
+func TestRegisterModels_OverridePrecedence(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "mental_models.yaml")
+	content := "models:\n  shared_model:\n    name: \"From File\"\n    description: \"File definition\"\n    steps:\n      - \"Step 1\"\n    category: \"custom\"\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+
+	cfg := config.DefaultConfig()
+	cfg.MentalModelsPath = configPath
+	loader.Configure(cfg)
+
+	register := func(key, name string) {
+		require.NoError(t, loader.RegisterModels(map[string]MentalModel{
+			key: {Name: name, Description: "Registered definition", Steps: []string{"Step 1"}, Category: "custom"},
+		}))
+	}
+	register("shared_model", "Registered")
+	register("first_principles", "Registered First Principles")
+
+	models, err := loader.Models()
+	require.NoError(t, err)
+	assert.Equal(t, "Registered", models["shared_model"].Name)
+	assert.Equal(t, "Registered First Principles", models["first_principles"].Name)
+
+	// Registered models survive a reload of the models path
+	_, err = loader.Reload(cfg)
+	require.NoError(t, err)
+	models, err = loader.Models()
+	require.NoError(t, err)
+	assert.Equal(t, "Registered", models["shared_model"].Name)
+}
+
+func TestRegisterModels_RejectsInvalidModels(t *testing.T) {
+	loader := NewLoader(logrus.New())
+	loader.Configure(config.DefaultConfig())
+
+	err := loader.RegisterModels(map[string]MentalModel{
+		"nameless": {Description: "No name", Steps: []string{"Step 1"}, Category: "custom"},
+	})
+	assert.ErrorContains(t, err, "empty name")
+
+	err = loader.RegisterModels(map[string]MentalModel{
+		"dangling": {Name: "Dangling", Description: "Bad reference", Steps: []string{"Step 1"}, Category: "custom", ComposedOf: []string{"missing_model"}},
+	})
+	assert.ErrorContains(t, err, "unknown model 'missing_model'")
+
+	err = loader.RegisterModels(map[string]MentalModel{
+		"  ": {Name: "Blank", Description: "Blank key", Steps: []string{"Step 1"}, Category: "custom"},
+	})
+	assert.Error(t, err)
+
+	// Nothing from a rejected registration is kept
+	models, err := loader.Models()
+	require.NoError(t, err)
+	assert.NotContains(t, models, "nameless")
+	assert.NotContains(t, models, "dangling")
+}
+
+func TestLoadMentalModels_Source(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "mental_models.yaml")
+	content := `
+models:
+  first_principles:
+    name: "Overridden First Principles"
+    description: "Replaces the core definition"
+    steps:
+      - "Step 1"
+    category: "analytical"
+  file_model:
+    name: "File Model"
+    description: "Defined in a file"
+    steps:
+      - "Step 1"
+    category: "custom"
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+
+	models, err := loader.LoadMentalModels(configPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, SourceCore, models["opportunity_cost"].Source)
+	assert.Equal(t, configPath, models["file_model"].Source)
+	assert.Equal(t, configPath, models["first_principles"].Source)
+}
+
+func TestLookup_NormalizesNames(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.CaseInsensitiveNames = true
+
+	loader := NewLoader(logrus.New())
+	loader.Configure(cfg)
+
+	models, err := loader.Models()
+	require.NoError(t, err)
+
+	for _, name := range []string{"first_principles", "  first_principles ", "\tfirst_principles\n", "First_Principles", " FIRST_PRINCIPLES "} {
+		key, model, ok := loader.Lookup(models, name)
+		require.True(t, ok, "name %q should resolve", name)
+		assert.Equal(t, "first_principles", key)
+		assert.Equal(t, "First Principles Thinking", model.Name)
+	}
+
+	_, _, ok := loader.Lookup(models, "second_principles")
+	assert.False(t, ok)
+}
+
+func TestLookup_CaseSensitiveByDefault(t *testing.T) {
+	cfg := config.DefaultConfig()
+
+	loader := NewLoader(logrus.New())
+	loader.Configure(cfg)
+	models, err := loader.Models()
+	require.NoError(t, err)
+
+	key, _, ok := loader.Lookup(models, " first_principles ")
+	assert.True(t, ok)
+	assert.Equal(t, "first_principles", key)
+
+	_, _, ok = loader.Lookup(models, "First_Principles")
+	assert.False(t, ok)
+
+	assert.Equal(t, "Binary Search", loader.NormalizeApproachName("  Binary   Search "))
+	cfg.CaseInsensitiveNames = true
+	loader.Configure(cfg)
+	assert.Equal(t, "binary search", loader.NormalizeApproachName("  Binary   Search "))
+}
+
+func TestLoadModelsFromFile_NormalizesKeys(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	configPath := filepath.Join(t.TempDir(), "mental_models.yaml")
+	content := "models:\n  \" padded_model \":\n    name: \"Padded\"\n    description: \"Key with whitespace\"\n    steps:\n      - \"Step 1\"\n    category: \"custom\"\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+
+	models, err := loader.LoadMentalModels(configPath)
+	require.NoError(t, err)
+	assert.Contains(t, models, "padded_model")
+}
+
+func TestLoadMentalModels_Composition(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "mental_models.yaml")
+	content := `
+models:
+  root_cause:
+    name: "Root Cause"
+    description: "Find the underlying cause"
+    steps:
+      - "Ask why five times"
+    category: "analytical"
+  deep_dive:
+    name: "Deep Dive"
+    description: "Systems view, then first principles, then root cause"
+    composed_of: ["systems_thinking", "first_principles", "root_cause"]
+    steps:
+      - "Summarize the findings"
+    category: "analytical"
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+
+	models, err := loader.LoadMentalModels(configPath)
+	require.NoError(t, err)
+
+	var expected []string
+	expected = append(expected, types.MentalModels["systems_thinking"].Steps...)
+	expected = append(expected, types.MentalModels["first_principles"].Steps...)
+	expected = append(expected, "Ask why five times", "Summarize the findings")
+
+	deepDive := models["deep_dive"]
+	assert.Equal(t, expected, deepDive.Steps)
+	assert.Equal(t, []string{"systems_thinking", "first_principles", "root_cause"}, deepDive.ComposedOf)
+
+	// Referenced models are unchanged
+	assert.Equal(t, []string{"Ask why five times"}, models["root_cause"].Steps)
+	assert.Equal(t, types.MentalModels["first_principles"].Steps, models["first_principles"].Steps)
+}
+
+func TestLoadMentalModels_CompositionRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{
+			name: "cycle",
+			content: `
+models:
+  alpha:
+    name: "Alpha"
+    description: "Uses beta"
+    composed_of: ["beta"]
+    category: "custom"
+  beta:
+    name: "Beta"
+    description: "Uses alpha"
+    composed_of: ["alpha"]
+    category: "custom"
+`,
+			message: "composition cycle: alpha -> beta -> alpha",
+		},
+		{
+			name: "unknown reference",
+			content: `
+models:
+  alpha:
+    name: "Alpha"
+    description: "Uses a missing model"
+    composed_of: ["missing_model"]
+    category: "custom"
+`,
+			message: "model 'alpha' is composed of unknown model 'missing_model'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "mental_models.yaml")
+			require.NoError(t, os.WriteFile(configPath, []byte(tt.content), 0644))
+
+			cfg := config.DefaultConfig()
+			cfg.MentalModelsPath = configPath
+
+			// A strict reload rejects the file
+			_, err := NewLoader(logrus.New()).Reload(cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+
+			// A lenient load falls back to the core models
+			models, err := NewLoader(logrus.New()).LoadMentalModels(configPath)
+			require.NoError(t, err)
+			assert.NotContains(t, models, "alpha")
+			assert.Contains(t, models, "first_principles")
+		})
+	}
+}
+
+func TestCheckCoverage(t *testing.T) {
+	model := MentalModel{
+		Name: "First Principles Thinking",
+		Steps: []string{
+			"Identify the problem clearly",
+			"Break it down into basic components",
+			"Question assumptions",
+			"Build up from the basics",
+		},
+	}
+
+	t.Run("fully covered", func(t *testing.T) {
+		application := &types.MentalModelData{
+			ID:        "full",
+			ModelName: "first_principles",
+			Steps: []string{
+				"Identify the problem: checkout latency is too high",
+				"Break latency down into network, database and rendering components",
+			},
+			Reasoning:  "Questioned the assumptions that caching is impossible. Step 4: rebuilt the flow from the basics.",
+			Conclusion: "Add a read cache",
+		}
+
+		coverage := CheckCoverage(model, application)
+		assert.Equal(t, "full", coverage.ModelID)
+		assert.Equal(t, 4, coverage.Addressed)
+		assert.Equal(t, 0, coverage.Unaddressed)
+		assert.Equal(t, 100.0, coverage.CoveragePercent)
+		assert.Equal(t, EvidenceSteps, coverage.Steps[0].Evidence)
+		assert.Equal(t, EvidenceSteps, coverage.Steps[1].Evidence)
+		assert.Equal(t, EvidenceReasoning, coverage.Steps[2].Evidence)
+		assert.Equal(t, EvidenceReasoning, coverage.Steps[3].Evidence)
+	})
+
+	t.Run("partially covered", func(t *testing.T) {
+		application := &types.MentalModelData{
+			ID:         "partial",
+			ModelName:  "first_principles",
+			Steps:      []string{"Identify the problem clearly"},
+			Conclusion: "As noted in step 3, nothing else matters",
+		}
+
+		coverage := CheckCoverage(model, application)
+		assert.Equal(t, 2, coverage.Addressed)
+		assert.Equal(t, 2, coverage.Unaddressed)
+		assert.Equal(t, 50.0, coverage.CoveragePercent)
+		assert.True(t, coverage.Steps[0].Addressed)
+		assert.False(t, coverage.Steps[1].Addressed)
+		assert.Empty(t, coverage.Steps[1].Evidence)
+		assert.Equal(t, EvidenceConclusion, coverage.Steps[2].Evidence)
+		assert.False(t, coverage.Steps[3].Addressed)
+	})
+}
+
+func TestCategoryBreakdown(t *testing.T) {
+	loader := NewLoader(logrus.New())
+	models, err := loader.Models()
+	require.NoError(t, err)
+
+	applications := []*types.MentalModelData{
+		{ModelName: "first_principles"},
+		{ModelName: "opportunity_cost"},
+		{ModelName: "first_principles"},
+		{ModelName: "retired_model"},
+	}
+
+	breakdown := loader.CategoryBreakdown(models, applications)
+	assert.Equal(t, []CategoryUsage{
+		{Category: "analytical", Applications: 2},
+		{Category: "decision-making", Applications: 1},
+		{Category: UnknownCategory, Applications: 1},
+	}, breakdown)
+
+	assert.Empty(t, loader.CategoryBreakdown(models, nil))
+}
+
+func TestCategoryBreakdown_ToolRecordsHaveOwnCategories(t *testing.T) {
+	loader := NewLoader(logrus.New())
+	models, err := loader.Models()
+	require.NoError(t, err)
+
+	applications := []*types.MentalModelData{
+		{ModelName: types.DebuggingApproachPrefix + "binary search"},
+		{ModelName: types.DebuggingApproachPrefix + "rubber duck"},
+		{ModelName: types.CreativeThinkingPrefix + "scamper"},
+		{ModelName: types.SocraticModelName},
+		{ModelName: types.ScientificMethodModelName},
+		{ModelName: types.CollaborativeReasoningModelName},
+		{ModelName: "first_principles"},
+	}
+
+	breakdown := loader.CategoryBreakdown(models, applications)
+	assert.Equal(t, []CategoryUsage{
+		{Category: DebuggingCategory, Applications: 2},
+		{Category: "analytical", Applications: 1},
+		{Category: CollaborativeCategory, Applications: 1},
+		{Category: CreativeCategory, Applications: 1},
+		{Category: ScientificCategory, Applications: 1},
+		{Category: SocraticCategory, Applications: 1},
+	}, breakdown)
+}
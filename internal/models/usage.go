@@ -0,0 +1,79 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// UnknownCategory groups applications of models that are no longer defined
+const UnknownCategory = "unknown"
+
+// Categories of applications recorded by the reasoning tools rather than
+// applied from the mental model library
+const (
+	DebuggingCategory     = "debugging"
+	CreativeCategory      = "creative"
+	SocraticCategory      = "socratic"
+	ScientificCategory    = "scientific"
+	CollaborativeCategory = "collaborative"
+)
+
+// toolCategories maps the model names the reasoning tools record under to
+// their categories
+var toolCategories = map[string]string{
+	types.SocraticModelName:               SocraticCategory,
+	types.ScientificMethodModelName:       ScientificCategory,
+	types.CollaborativeReasoningModelName: CollaborativeCategory,
+}
+
+// toolCategory returns the category of an application recorded by one of the
+// reasoning tools
+func toolCategory(modelName string) (string, bool) {
+	switch {
+	case strings.HasPrefix(modelName, types.DebuggingApproachPrefix):
+		return DebuggingCategory, true
+	case strings.HasPrefix(modelName, types.CreativeThinkingPrefix):
+		return CreativeCategory, true
+	}
+	category, exists := toolCategories[modelName]
+	return category, exists
+}
+
+// CategoryUsage is the number of mental model applications in one category
+type CategoryUsage struct {
+	Category     string `json:"category"`
+	Applications int    `json:"applications"`
+}
+
+// CategoryBreakdown counts applications per category of the applied model,
+// most used first and then by category name. Debugging approaches and the
+// other records kept by the reasoning tools count under their own categories,
+// and applications of models that are no longer defined under
+// UnknownCategory.
+func (l *Loader) CategoryBreakdown(models map[string]MentalModel, applications []*types.MentalModelData) []CategoryUsage {
+	counts := make(map[string]int)
+	for _, application := range applications {
+		category := UnknownCategory
+		if _, model, exists := l.Lookup(models, application.ModelName); exists && model.Category != "" {
+			category = model.Category
+		} else if recorded, exists := toolCategory(application.ModelName); exists {
+			category = recorded
+		}
+		counts[category]++
+	}
+
+	breakdown := make([]CategoryUsage, 0, len(counts))
+	for category, count := range counts {
+		breakdown = append(breakdown, CategoryUsage{Category: category, Applications: count})
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Applications != breakdown[j].Applications {
+			return breakdown[i].Applications > breakdown[j].Applications
+		}
+		return breakdown[i].Category < breakdown[j].Category
+	})
+
+	return breakdown
+}
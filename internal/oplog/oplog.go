@@ -0,0 +1,116 @@
+package oplog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// stdoutPaths name standard output, which the stdio transport reserves for
+// MCP messages
+var stdoutPaths = map[string]bool{
+	"-":               true,
+	"/dev/stdout":     true,
+	"/dev/fd/1":       true,
+	"/proc/self/fd/1": true,
+}
+
+// Record is one tool operation in the log
+type Record struct {
+	Time       time.Time `json:"time"`
+	Tool       string    `json:"tool"`
+	SessionID  string    `json:"session_id,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	IsError    bool      `json:"is_error,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Log appends one JSON record per tool operation to a file, separately from
+// the diagnostic logs
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	now     func() time.Time
+}
+
+// Open appends to the operation log at path, creating it if needed. Paths
+// that refer to standard output are rejected.
+func Open(path string) (*Log, error) {
+	if stdoutPaths[path] || stdoutPaths[filepath.Clean(path)] {
+		return nil, fmt.Errorf("operation log must not be written to stdout")
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open operation log: %w", err)
+	}
+	if isStdout(file) {
+		file.Close()
+		return nil, fmt.Errorf("operation log must not be written to stdout")
+	}
+
+	return &Log{file: file, encoder: json.NewEncoder(file), now: time.Now}, nil
+}
+
+// isStdout reports whether file is the process's standard output, e.g.
+// through a symlink
+func isStdout(file *os.File) bool {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	stdoutInfo, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fileInfo, stdoutInfo)
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// write appends a record to the log
+func (l *Log) write(record Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.encoder.Encode(record)
+}
+
+// Middleware records every tool call once it returns; failures to write the
+// log never fail the call
+func (l *Log) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := l.now()
+			result, err := next(ctx, req)
+
+			record := Record{
+				Time:       start,
+				Tool:       req.Params.Name,
+				SessionID:  req.GetString("session_id", ""),
+				DurationMS: l.now().Sub(start).Milliseconds(),
+			}
+			if err != nil {
+				record.IsError = true
+				record.Error = err.Error()
+			} else if result != nil && result.IsError {
+				record.IsError = true
+			}
+			_ = l.write(record)
+
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package oplog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callTool(t *testing.T, s *server.MCPServer, l *Log, name string, args map[string]interface{}) {
+	t.Helper()
+
+	tool := s.GetTool(name)
+	require.NotNil(t, tool)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+
+	// Registered handlers are not wrapped by GetTool, so apply the middleware directly
+	_, _ = l.Middleware()(tool.Handler)(context.Background(), req)
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "line %q", scanner.Text())
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestMiddleware_RecordsOperationsToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "operations.jsonl")
+	l, err := Open(path)
+	require.NoError(t, err)
+
+	s := server.NewMCPServer("Test", "1.0.0")
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	s.AddTool(mcp.NewTool("fail"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("boom"), nil
+	})
+	s.AddTool(mcp.NewTool("broken"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, errors.New("handler failed")
+	})
+
+	callTool(t, s, l, "echo", map[string]interface{}{"session_id": "logged"})
+	callTool(t, s, l, "fail", map[string]interface{}{})
+	callTool(t, s, l, "broken", map[string]interface{}{"session_id": "logged"})
+	require.NoError(t, l.Close())
+
+	records := readRecords(t, path)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "echo", records[0].Tool)
+	assert.Equal(t, "logged", records[0].SessionID)
+	assert.False(t, records[0].IsError)
+	assert.False(t, records[0].Time.IsZero())
+
+	assert.Equal(t, "fail", records[1].Tool)
+	assert.Empty(t, records[1].SessionID)
+	assert.True(t, records[1].IsError)
+
+	assert.Equal(t, "broken", records[2].Tool)
+	assert.True(t, records[2].IsError)
+	assert.Equal(t, "handler failed", records[2].Error)
+
+	// Reopening appends to the existing log
+	l, err = Open(path)
+	require.NoError(t, err)
+	callTool(t, s, l, "echo", map[string]interface{}{})
+	require.NoError(t, l.Close())
+	assert.Len(t, readRecords(t, path), 4)
+}
+
+func TestOpen_RejectsStdout(t *testing.T) {
+	for _, path := range []string{"-", "/dev/stdout", "/dev/fd/1"} {
+		_, err := Open(path)
+		assert.Error(t, err, path)
+	}
+}
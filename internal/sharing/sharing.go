@@ -0,0 +1,64 @@
+package sharing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for malformed tokens and bad signatures
+	ErrInvalidToken = errors.New("invalid export token")
+	// ErrTokenExpired is returned for well-signed tokens past their expiry
+	ErrTokenExpired = errors.New("export token expired")
+)
+
+// NewToken returns a token granting read-only export of a session until
+// expires. The token is "<session>.<expiry>.<signature>": the base64url
+// session ID, the Unix expiry and the hex HMAC-SHA256 of both keyed with the
+// secret.
+func NewToken(secret, sessionID string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(sessionID)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + sign(secret, payload)
+}
+
+// Verify checks a token's signature and expiry at now and returns the
+// session it grants access to
+func Verify(secret, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(sign(secret, payload))) {
+		return "", ErrInvalidToken
+	}
+
+	sessionID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if !now.Before(time.Unix(expiry, 0)) {
+		return "", fmt.Errorf("%w at %s", ErrTokenExpired, time.Unix(expiry, 0).UTC().Format(time.RFC3339))
+	}
+
+	return string(sessionID), nil
+}
+
+// sign is the hex HMAC-SHA256 of a token payload
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,53 @@
+package sharing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "share-secret"
+
+func TestVerify_ValidToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	token := NewToken(testSecret, "session.with.dots", now.Add(time.Hour))
+
+	sessionID, err := Verify(testSecret, token, now)
+	require.NoError(t, err)
+	assert.Equal(t, "session.with.dots", sessionID)
+}
+
+func TestVerify_ExpiredToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	token := NewToken(testSecret, "shared", now.Add(time.Minute))
+
+	_, err := Verify(testSecret, token, now.Add(time.Minute))
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
+
+func TestVerify_TamperedToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	token := NewToken(testSecret, "shared", now.Add(time.Hour))
+	parts := strings.Split(token, ".")
+
+	// Pointing the token at another session invalidates the signature
+	other := NewToken(testSecret, "private", now.Add(time.Hour))
+	forged := strings.Split(other, ".")[0] + "." + parts[1] + "." + parts[2]
+	_, err := Verify(testSecret, forged, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// So does extending the expiry
+	extended := parts[0] + ".9999999999." + parts[2]
+	_, err = Verify(testSecret, extended, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// And a token signed with another secret is rejected
+	_, err = Verify("other-secret", token, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = Verify(testSecret, "not-a-token", now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Overflow policies for capped per-session collections
+const (
+	// OverflowReject refuses new entries once a collection is full
+	OverflowReject = "reject"
+	// OverflowRing drops the oldest entry to make room for a new one
+	OverflowRing = "ring"
+)
+
+// validateOverflow checks an overflow policy name
+func validateOverflow(policy string) error {
+	switch policy {
+	case OverflowReject, OverflowRing:
+		return nil
+	default:
+		return fmt.Errorf("unknown overflow policy %q (expected %q or %q)", policy, OverflowReject, OverflowRing)
+	}
+}
+
+// RecordToolUse notes a tool call against an existing session: the tool joins
+// the session's distinct tools used and the call is appended to its audit
+// trail. Both collections are capped by configuration, and a full collection
+// either rejects the call or drops its oldest entry per its overflow policy.
+// Archived sessions are left unchanged.
+func (s *Storage) RecordToolUse(sessionID, tool string) error {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Archived {
+		return nil
+	}
+
+	// Check both caps before changing either collection
+	newTool := !containsString(session.ToolsUsed, tool)
+	toolsFull := newTool && s.config.MaxToolsUsed > 0 && len(session.ToolsUsed) >= s.config.MaxToolsUsed
+	if toolsFull && s.config.ToolsUsedOverflow != OverflowRing {
+		return fmt.Errorf("tools used limit reached for session %s: limit %d", sessionID, s.config.MaxToolsUsed)
+	}
+	auditFull := s.config.MaxAuditEntries > 0 && len(session.audit) >= s.config.MaxAuditEntries
+	if auditFull && s.config.AuditOverflow != OverflowRing {
+		return fmt.Errorf("audit limit reached for session %s: limit %d", sessionID, s.config.MaxAuditEntries)
+	}
+
+	if toolsFull {
+		session.ToolsUsed = session.ToolsUsed[1:]
+	}
+	if newTool {
+		session.ToolsUsed = append(session.ToolsUsed, tool)
+	}
+	if auditFull {
+		session.audit = session.audit[1:]
+	}
+	session.audit = append(session.audit, types.AuditEntry{Tool: tool, At: s.now()})
+	s.persistSession(session)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"tool":       tool,
+	}).Debug("Recorded tool use")
+
+	return nil
+}
+
+// GetSessionAudit returns a copy of a session's audit trail, oldest call first
+func (s *Storage) GetSessionAudit(sessionID string) ([]types.AuditEntry, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]types.AuditEntry{}, session.audit...), nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
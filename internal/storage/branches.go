@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PromoteBranch appends a branch's thoughts onto the end of the trunk,
+// renumbering them after the last trunk thought (or from the thought number
+// base when the trunk is empty). Revision links between
+// thoughts of the branch follow the renumbering. With deleteBranch set the
+// branch thoughts are moved into the trunk; otherwise the trunk receives
+// copies and the branch is left intact. It returns the new trunk length.
+func (s *Storage) PromoteBranch(sessionID, branchID string, deleteBranch bool) (int, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if session.Archived {
+		return 0, fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+	if branchID == "" {
+		return 0, fmt.Errorf("branch ID must not be empty")
+	}
+
+	// Runs after the thoughts lock below is released
+	defer s.persistSessionContents(sessionID)
+
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.Lock()
+	defer sh.thoughtsMutex.Unlock()
+
+	nextNumber := s.ThoughtNumberBase()
+	var branch []*types.ThoughtData
+	for _, id := range sh.sessionThoughts[sessionID] {
+		thought, exists := sh.thoughts[id]
+		if !exists {
+			continue
+		}
+		switch thought.BranchID {
+		case "":
+			if thought.ThoughtNumber >= nextNumber {
+				nextNumber = thought.ThoughtNumber + 1
+			}
+		case branchID:
+			branch = append(branch, thought)
+		}
+	}
+	if len(branch) == 0 {
+		return 0, fmt.Errorf("branch %s not found in session %s", branchID, sessionID)
+	}
+	if !deleteBranch && session.ThoughtCount+len(branch) > s.config.MaxThoughtsPerSession {
+		return 0, fmt.Errorf("%w for session %s", ErrThoughtLimitReached, sessionID)
+	}
+
+	branch = sortedByThoughtNumber(branch)
+	newLength := nextNumber - s.ThoughtNumberBase() + len(branch)
+
+	renumbered := make(map[int]int, len(branch))
+	for i, thought := range branch {
+		if _, seen := renumbered[thought.ThoughtNumber]; !seen {
+			renumbered[thought.ThoughtNumber] = nextNumber + i
+		}
+	}
+
+	now := s.now()
+	for i, thought := range branch {
+		promoted := thought
+		if !deleteBranch {
+			thoughtCopy := *thought
+			promoted = &thoughtCopy
+			promoted.ID = s.generateID()
+			promoted.CreatedAt = now
+		}
+
+		// Revisions of other branch thoughts follow them; revisions of
+		// thoughts before the branch point already refer to the trunk
+		if promoted.RevisesThought != nil {
+			if target, exists := renumbered[*promoted.RevisesThought]; exists {
+				promoted.RevisesThought = &target
+			}
+		}
+		promoted.ThoughtNumber = nextNumber + i
+		promoted.TotalThoughts = newLength
+		promoted.BranchID = ""
+		promoted.BranchFromThought = nil
+
+		if !deleteBranch {
+			sh.thoughts[promoted.ID] = promoted
+			sh.sessionThoughts[sessionID] = append(sh.sessionThoughts[sessionID], promoted.ID)
+			s.countEntities(session, 1, 0)
+		}
+	}
+	s.touchSession(session)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id":   sessionID,
+		"branch_id":    branchID,
+		"promoted":     len(branch),
+		"deleted":      deleteBranch,
+		"trunk_length": newLength,
+	}).Debug("Promoted branch into trunk")
+
+	return newLength, nil
+}
+
+// BranchLineage returns the thoughts leading to and along a branch: trunk
+// thoughts up to the earliest point the branch forks from, then the branch's
+// own thoughts, each in insertion order. Other branches and trunk thoughts
+// after the branch point are left out.
+func (s *Storage) BranchLineage(sessionID, branchID string) ([]*types.ThoughtData, error) {
+	if branchID == "" {
+		return nil, fmt.Errorf("branch ID must not be empty")
+	}
+
+	thoughts, err := s.GetThoughts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	branchPoint := -1
+	found := false
+	for _, thought := range thoughts {
+		if thought.BranchID != branchID {
+			continue
+		}
+		// A branch without a recorded fork point keeps the trunk before its
+		// first thought
+		point := thought.ThoughtNumber - 1
+		if thought.BranchFromThought != nil {
+			point = *thought.BranchFromThought
+		}
+		if !found || point < branchPoint {
+			branchPoint = point
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("branch %s not found in session %s", branchID, sessionID)
+	}
+
+	var trunk, branch []*types.ThoughtData
+	for _, thought := range thoughts {
+		switch thought.BranchID {
+		case "":
+			if thought.ThoughtNumber <= branchPoint {
+				trunk = append(trunk, thought)
+			}
+		case branchID:
+			branch = append(branch, thought)
+		}
+	}
+
+	return append(trunk, branch...), nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrTotalThoughtLimitReached is returned when a write would exceed
+// MaxTotalThoughts and no inactive session can be evicted to make room
+var ErrTotalThoughtLimitReached = errors.New("total thought limit reached")
+
+// totalThoughts counts the thoughts stored across every shard
+func (s *Storage) totalThoughts() int {
+	total := 0
+	for _, sh := range s.shards {
+		sh.thoughtsMutex.RLock()
+		total += len(sh.thoughts)
+		sh.thoughtsMutex.RUnlock()
+	}
+	return total
+}
+
+// reserveThoughts makes room for n more thoughts under MaxTotalThoughts and
+// holds it until the returned release function runs, which callers do once
+// the thoughts are stored (under the shard's thoughts lock) or abandoned.
+// When the thoughts would not fit, the least recently accessed inactive
+// sessions are evicted until they do; active sessions, and the session being
+// written to, are never evicted. Callers hold the session lock of sessionID.
+func (s *Storage) reserveThoughts(sessionID string, n int) (func(), error) {
+	limit := s.config.MaxTotalThoughts
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	if n > limit {
+		return nil, fmt.Errorf("%w: %d thoughts requested, limit %d", ErrTotalThoughtLimitReached, n, limit)
+	}
+
+	s.capacityMutex.Lock()
+	defer s.capacityMutex.Unlock()
+
+	// Read the reservations before counting, so thoughts stored in between
+	// are counted twice rather than not at all
+	reserved := int(atomic.LoadInt64(&s.reservedThoughts))
+	overflow := reserved + s.totalThoughts() + n - limit
+	for _, candidate := range s.recentSessions.oldest() {
+		if overflow <= 0 {
+			break
+		}
+		if candidate != sessionID {
+			overflow -= s.evictForCapacity(candidate)
+		}
+	}
+	if overflow > 0 {
+		return nil, fmt.Errorf("%w: limit %d", ErrTotalThoughtLimitReached, limit)
+	}
+
+	atomic.AddInt64(&s.reservedThoughts, int64(n))
+	return func() {
+		atomic.AddInt64(&s.reservedThoughts, -int64(n))
+	}, nil
+}
+
+// isInactive reports whether a session is idle: marked so by the reaper, or
+// not accessed for SessionTimeout whether or not the reaper has run
+func (s *Storage) isInactive(session *SessionData) bool {
+	if session.State == types.SessionStateIdle {
+		return true
+	}
+	timeout := s.config.SessionTimeout
+	return timeout > 0 && s.now().Sub(session.LastAccessedAt) >= timeout
+}
+
+// evictForCapacity removes an inactive session holding thoughts and returns
+// how many thoughts it held. A session whose lock is taken is in use and left
+// alone, which also keeps the eviction from waiting on a writer that is
+// itself waiting to reserve room.
+func (s *Storage) evictForCapacity(sessionID string) int {
+	unlock, ok := s.shardFor(sessionID).sessionLocks.tryLock(sessionID)
+	if !ok {
+		return 0
+	}
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil || !s.isInactive(session) {
+		return 0
+	}
+
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.RLock()
+	thoughts := len(sh.sessionThoughts[sessionID])
+	sh.thoughtsMutex.RUnlock()
+	if thoughts == 0 {
+		return 0
+	}
+
+	s.removeSession(sessionID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"thoughts":   thoughts,
+		"limit":      s.config.MaxTotalThoughts,
+	}).Info("Evicted session to stay under the total thought limit")
+
+	return thoughts
+}
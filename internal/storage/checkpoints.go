@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// checkpoint is an immutable snapshot of a session's thoughts and mental
+// models; restoring hands out copies so the snapshot never changes
+type checkpoint struct {
+	name         string
+	createdAt    time.Time
+	thoughts     []*types.ThoughtData
+	mentalModels []*types.MentalModelData
+}
+
+// summary describes the checkpoint without its contents
+func (c *checkpoint) summary() types.Checkpoint {
+	return types.Checkpoint{
+		Name:             c.name,
+		CreatedAt:        c.createdAt,
+		ThoughtCount:     len(c.thoughts),
+		MentalModelCount: len(c.mentalModels),
+	}
+}
+
+// copyThought returns a deep copy of a thought
+func copyThought(thought *types.ThoughtData) *types.ThoughtData {
+	thoughtCopy := *thought
+	if thought.RevisesThought != nil {
+		revises := *thought.RevisesThought
+		thoughtCopy.RevisesThought = &revises
+	}
+	if thought.BranchFromThought != nil {
+		branchFrom := *thought.BranchFromThought
+		thoughtCopy.BranchFromThought = &branchFrom
+	}
+	thoughtCopy.References = append([]types.Reference(nil), thought.References...)
+	thoughtCopy.Tags = append([]string(nil), thought.Tags...)
+	return &thoughtCopy
+}
+
+// copyMentalModel returns a deep copy of a mental model application
+func copyMentalModel(model *types.MentalModelData) *types.MentalModelData {
+	modelCopy := *model
+	modelCopy.Steps = append([]string(nil), model.Steps...)
+	return &modelCopy
+}
+
+// findCheckpoint returns a session's checkpoint by name; callers hold the
+// session lock
+func findCheckpoint(session *SessionData, name string) *checkpoint {
+	for _, c := range session.checkpoints {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// CheckpointSession saves a named snapshot of a session's thoughts and
+// mental models. Names are unique within a session and the number of
+// checkpoints is capped by MaxCheckpointsPerSession.
+func (s *Storage) CheckpointSession(sessionID, name string) (types.Checkpoint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return types.Checkpoint{}, fmt.Errorf("checkpoint name must not be empty")
+	}
+
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return types.Checkpoint{}, err
+	}
+	if findCheckpoint(session, name) != nil {
+		return types.Checkpoint{}, fmt.Errorf("checkpoint %q already exists in session %s", name, sessionID)
+	}
+	if limit := s.config.MaxCheckpointsPerSession; limit > 0 && len(session.checkpoints) >= limit {
+		return types.Checkpoint{}, fmt.Errorf("checkpoint limit reached for session %s: limit %d", sessionID, limit)
+	}
+
+	thoughts, _ := s.GetThoughts(sessionID)
+	mentalModels, _ := s.GetMentalModels(sessionID)
+	snapshot := &checkpoint{
+		name:         name,
+		createdAt:    s.now(),
+		thoughts:     make([]*types.ThoughtData, 0, len(thoughts)),
+		mentalModels: make([]*types.MentalModelData, 0, len(mentalModels)),
+	}
+	for _, thought := range thoughts {
+		snapshot.thoughts = append(snapshot.thoughts, copyThought(thought))
+	}
+	for _, model := range mentalModels {
+		snapshot.mentalModels = append(snapshot.mentalModels, copyMentalModel(model))
+	}
+	session.checkpoints = append(session.checkpoints, snapshot)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"checkpoint": name,
+		"thoughts":   len(snapshot.thoughts),
+		"models":     len(snapshot.mentalModels),
+	}).Debug("Checkpointed session")
+
+	return snapshot.summary(), nil
+}
+
+// ListCheckpoints describes a session's checkpoints, oldest first
+func (s *Storage) ListCheckpoints(sessionID string) ([]types.Checkpoint, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := make([]types.Checkpoint, 0, len(session.checkpoints))
+	for _, c := range session.checkpoints {
+		checkpoints = append(checkpoints, c.summary())
+	}
+	return checkpoints, nil
+}
+
+// RestoreCheckpoint replaces a session's thoughts and mental models with
+// copies of a checkpoint's. The checkpoint itself is kept, so a session can
+// be rolled back to it again.
+func (s *Storage) RestoreCheckpoint(sessionID, name string) (types.Checkpoint, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return types.Checkpoint{}, err
+	}
+	if session.Archived {
+		return types.Checkpoint{}, fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+	snapshot := findCheckpoint(session, name)
+	if snapshot == nil {
+		return types.Checkpoint{}, fmt.Errorf("checkpoint %q not found in session %s", name, sessionID)
+	}
+
+	sh := s.shardFor(sessionID)
+
+	sh.thoughtsMutex.Lock()
+	for _, id := range sh.sessionThoughts[sessionID] {
+		delete(sh.thoughts, id)
+	}
+	sh.sessionThoughts[sessionID] = nil
+	for _, thought := range snapshot.thoughts {
+		restored := copyThought(thought)
+		sh.thoughts[restored.ID] = restored
+		sh.sessionThoughts[sessionID] = append(sh.sessionThoughts[sessionID], restored.ID)
+	}
+	sh.thoughtsMutex.Unlock()
+
+	sh.mentalModelsMutex.Lock()
+	for _, id := range sh.sessionModels[sessionID] {
+		if model, exists := sh.mentalModels[id]; exists {
+			sh.removeModelRef(s.modelKey(model.ModelName), sessionID)
+			delete(sh.mentalModels, id)
+		}
+	}
+	sh.sessionModels[sessionID] = nil
+	for _, model := range snapshot.mentalModels {
+		restored := copyMentalModel(model)
+		sh.mentalModels[restored.ID] = restored
+		sh.sessionModels[sessionID] = append(sh.sessionModels[sessionID], restored.ID)
+		key := s.modelKey(restored.ModelName)
+		sh.modelsByName[key] = append(sh.modelsByName[key], modelRef{sessionID: sessionID, modelID: restored.ID})
+	}
+	sh.mentalModelsMutex.Unlock()
+
+	s.setCounters(session, len(snapshot.thoughts), len(snapshot.mentalModels))
+	s.touchSession(session)
+	s.persistSessionContents(sessionID)
+
+	s.publish(Event{Kind: EventCheckpointRestored, SessionID: sessionID, At: s.now()})
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"checkpoint": name,
+	}).Info("Restored session checkpoint")
+
+	return snapshot.summary(), nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// ErrModelCooldown is returned when a mental model is applied to a problem
+// again within ModelCooldown of its last application in the session
+var ErrModelCooldown = errors.New("mental model cooldown active")
+
+// CooldownError reports an application rejected by the model cooldown,
+// identifying the earlier application it duplicates
+type CooldownError struct {
+	ModelName  string
+	PriorID    string
+	RetryAfter time.Duration
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("%v: %s was already applied to this problem as %s; retry in %s", ErrModelCooldown, e.ModelName, e.PriorID, e.RetryAfter)
+}
+
+func (e *CooldownError) Unwrap() error {
+	return ErrModelCooldown
+}
+
+// checkCooldown rejects models applied to a problem the session applied
+// them to within the cooldown window; callers hold the shard's mental model
+// lock
+func (s *Storage) checkCooldown(sh *shard, sessionID string, models []*types.MentalModelData) error {
+	cooldown := s.config.ModelCooldown
+	if cooldown <= 0 {
+		return nil
+	}
+
+	now := s.now()
+	ids := sh.sessionModels[sessionID]
+	for _, model := range models {
+		name := s.modelKey(model.ModelName)
+		problem := strings.TrimSpace(model.Problem)
+
+		// Newest first; imported applications keep their original times, so
+		// an old one does not end the search
+		for i := len(ids) - 1; i >= 0; i-- {
+			prior, exists := sh.mentalModels[ids[i]]
+			if !exists {
+				continue
+			}
+			elapsed := now.Sub(prior.CreatedAt)
+			if elapsed >= cooldown {
+				continue
+			}
+			if s.modelKey(prior.ModelName) == name && strings.TrimSpace(prior.Problem) == problem {
+				return &CooldownError{ModelName: model.ModelName, PriorID: prior.ID, RetryAfter: cooldown - elapsed}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// EventKind identifies the mutation an Event describes
+type EventKind string
+
+// Event kinds delivered to session subscribers
+const (
+	EventThoughtAdded        EventKind = "thought_added"
+	EventModelAdded          EventKind = "model_added"
+	EventSessionCleared      EventKind = "session_cleared"
+	EventSessionCreated      EventKind = "session_created"
+	EventSessionArchived     EventKind = "session_archived"
+	EventThoughtLimitReached EventKind = "thought_limit_reached"
+	EventCheckpointRestored  EventKind = "checkpoint_restored"
+	EventSessionExpired      EventKind = "session_expired"
+)
+
+// terminalEvents end the subscriptions of their session when
+// config.EndSubscriptionsWithSession is set
+var terminalEvents = map[EventKind]bool{
+	EventSessionCleared:  true,
+	EventSessionArchived: true,
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// further events to it are dropped
+const subscriberBuffer = 64
+
+// Event describes a change to a session
+type Event struct {
+	Kind      EventKind              `json:"kind"`
+	SessionID string                 `json:"session_id"`
+	Thought   *types.ThoughtData     `json:"thought,omitempty"`
+	Model     *types.MentalModelData `json:"model,omitempty"`
+	At        time.Time              `json:"at"`
+}
+
+// subscription is one subscriber's event channel
+type subscription struct {
+	events chan Event
+	once   sync.Once
+}
+
+// close closes the event channel; it is safe to call more than once
+func (sub *subscription) close() {
+	sub.once.Do(func() { close(sub.events) })
+}
+
+// subscribers tracks the subscriptions of every session
+type subscribers struct {
+	mu        sync.Mutex
+	bySession map[string]map[*subscription]struct{}
+}
+
+// newSubscribers creates an empty subscription registry
+func newSubscribers() *subscribers {
+	return &subscribers{bySession: make(map[string]map[*subscription]struct{})}
+}
+
+// Subscribe returns a channel receiving the events of a session, or of every
+// session when sessionID is empty, and a cancel function that unsubscribes
+// and closes the channel. Delivery never blocks writers: events to a
+// subscriber whose buffer is full are dropped.
+func (s *Storage) Subscribe(sessionID string) (<-chan Event, func()) {
+	sub := &subscription{events: make(chan Event, subscriberBuffer)}
+
+	s.subscribers.mu.Lock()
+	if s.subscribers.bySession[sessionID] == nil {
+		s.subscribers.bySession[sessionID] = make(map[*subscription]struct{})
+	}
+	s.subscribers.bySession[sessionID][sub] = struct{}{}
+	s.subscribers.mu.Unlock()
+
+	cancel := func() {
+		s.subscribers.mu.Lock()
+		if subs := s.subscribers.bySession[sessionID]; subs != nil {
+			delete(subs, sub)
+			if len(subs) == 0 {
+				delete(s.subscribers.bySession, sessionID)
+			}
+		}
+		s.subscribers.mu.Unlock()
+		sub.close()
+	}
+
+	return sub.events, cancel
+}
+
+// SubscriberCount returns the number of active subscriptions to a session
+func (s *Storage) SubscriberCount(sessionID string) int {
+	s.subscribers.mu.Lock()
+	defer s.subscribers.mu.Unlock()
+
+	return len(s.subscribers.bySession[sessionID])
+}
+
+// publish delivers an event to the subscribers of its session and of all
+// sessions without blocking. A terminal event is the last one a session's
+// own subscribers receive before their channels are closed; subscribers to
+// all sessions stay open.
+func (s *Storage) publish(event Event) {
+	s.subscribers.mu.Lock()
+	defer s.subscribers.mu.Unlock()
+
+	if event.SessionID != "" {
+		s.deliver(s.subscribers.bySession[""], event)
+	}
+	if event.SessionID == "" || !terminalEvents[event.Kind] || !s.config.EndSubscriptionsWithSession {
+		s.deliver(s.subscribers.bySession[event.SessionID], event)
+		return
+	}
+
+	for sub := range s.subscribers.bySession[event.SessionID] {
+		// Make room so the final event is never dropped
+		select {
+		case sub.events <- event:
+		default:
+			select {
+			case <-sub.events:
+			default:
+			}
+			select {
+			case sub.events <- event:
+			default:
+			}
+		}
+		sub.close()
+	}
+	delete(s.subscribers.bySession, event.SessionID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": event.SessionID,
+		"kind":       event.Kind,
+	}).Debug("Closed session subscriptions")
+}
+
+// deliver offers an event to each subscription, dropping it for full buffers;
+// callers hold the subscribers lock
+func (s *Storage) deliver(subs map[*subscription]struct{}, event Event) {
+	for sub := range subs {
+		select {
+		case sub.events <- event:
+		default:
+			s.logger.WithFields(logrus.Fields{
+				"session_id": event.SessionID,
+				"kind":       event.Kind,
+			}).Debug("Dropped event for slow subscriber")
+		}
+	}
+}
+
+// closeSubscriptions ends every subscription, closing their channels
+func (s *Storage) closeSubscriptions() {
+	s.subscribers.mu.Lock()
+	defer s.subscribers.mu.Unlock()
+
+	for sessionID, subs := range s.subscribers.bySession {
+		for sub := range subs {
+			sub.close()
+		}
+		delete(s.subscribers.bySession, sessionID)
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ID generation strategies selectable via config.IDStrategy
+const (
+	IDStrategyTimestamp = "timestamp"
+	IDStrategyUUID      = "uuid"
+	IDStrategyULID      = "ulid"
+)
+
+// idGenerator produces a unique ID for an entity created at the given time
+type idGenerator func(now time.Time) string
+
+// newIDGenerator returns the generator for a strategy (empty selects UUIDs)
+func newIDGenerator(strategy string) (idGenerator, error) {
+	switch strategy {
+	case "", IDStrategyUUID:
+		return func(time.Time) string { return uuid.NewString() }, nil
+	case IDStrategyTimestamp:
+		return newTimestampGenerator(), nil
+	case IDStrategyULID:
+		return newULIDGenerator(), nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy %q (expected %s, %s or %s)", strategy, IDStrategyTimestamp, IDStrategyUUID, IDStrategyULID)
+	}
+}
+
+// newTimestampGenerator returns time-based IDs made unique by a sequence number
+func newTimestampGenerator() idGenerator {
+	var sequence uint64
+	return func(now time.Time) string {
+		return fmt.Sprintf("%d-%d", now.UnixNano(), atomic.AddUint64(&sequence, 1))
+	}
+}
+
+// crockfordAlphabet is the Base32 alphabet used by ULIDs
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator produces monotonic ULIDs: IDs minted within the same
+// millisecond increment the random component so they still sort in order
+type ulidGenerator struct {
+	mu         sync.Mutex
+	lastMillis uint64
+	entropy    [10]byte
+}
+
+// newULIDGenerator returns lexically sortable ULIDs
+func newULIDGenerator() idGenerator {
+	g := &ulidGenerator{}
+	return g.next
+}
+
+func (g *ulidGenerator) next(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	millis := uint64(now.UnixMilli())
+	if millis > g.lastMillis {
+		g.lastMillis = millis
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			panic(fmt.Sprintf("failed to read random bytes: %v", err))
+		}
+	} else {
+		// Same (or earlier) millisecond: keep the last timestamp and increment entropy
+		for i := len(g.entropy) - 1; i >= 0; i-- {
+			g.entropy[i]++
+			if g.entropy[i] != 0 {
+				break
+			}
+		}
+	}
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(g.lastMillis >> (40 - 8*i))
+	}
+	copy(id[6:], g.entropy[:])
+
+	return encodeULID(id)
+}
+
+// encodeULID encodes 128 bits as 26 Crockford Base32 characters
+func encodeULID(id [16]byte) string {
+	var out [26]byte
+
+	// The first character holds the top 3 bits (the value is treated as 130
+	// bits with two leading zeros); the remaining 125 bits fill 25 characters
+	out[0] = crockfordAlphabet[id[0]>>5]
+	buffer := uint64(id[0] & 0x1f)
+	bits := uint(5)
+	pos := 1
+
+	for _, b := range id[1:] {
+		buffer = buffer<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockfordAlphabet[(buffer>>bits)&0x1f]
+			pos++
+		}
+	}
+
+	return string(out[:])
+}
@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// CurrentExportVersion is the export format written by ExportSession
+const CurrentExportVersion = "1.1.0"
+
+// ErrImportTooLarge is returned when an import payload exceeds MaxImportBytes
+// or would create more than MaxImportEntities thoughts and mental models
+var ErrImportTooLarge = errors.New("import too large")
+
+// exportMigration upgrades the data section of an export from one format
+// version to the next
+type exportMigration struct {
+	from    string
+	to      string
+	migrate func(data map[string]interface{}) error
+}
+
+// exportMigrations are applied in turn until an export reaches
+// CurrentExportVersion
+var exportMigrations = []exportMigration{
+	// 1.0.0 predates session metadata
+	{from: "1.0.0", to: "1.1.0", migrate: func(data map[string]interface{}) error {
+		if _, exists := data["metadata"]; !exists {
+			data["metadata"] = map[string]interface{}{}
+		}
+		return nil
+	}},
+}
+
+// importedData is the data section of an export at CurrentExportVersion
+type importedData struct {
+	Thoughts     []*types.ThoughtData     `json:"thoughts"`
+	MentalModels []*types.MentalModelData `json:"mental_models"`
+	Metadata     map[string]string        `json:"metadata"`
+	Verdict      *types.SessionVerdict    `json:"verdict"`
+}
+
+// ImportSession recreates a session from an ExportSession payload and
+// returns its ID. Exports in older formats are migrated first; newer formats
+// are rejected. The session must not already exist. Thoughts and mental
+// models keep their creation times but receive new IDs, and the whole export
+// is validated before anything is stored. Payloads larger than
+// MaxImportBytes are rejected before they are parsed.
+func (s *Storage) ImportSession(payload []byte) (string, error) {
+	return s.importSession(payload, false)
+}
+
+// MergeSession is ImportSession for a session that may already exist: the
+// export's thoughts and mental models are appended to it, with thought
+// numbers (and the revision and branch links between them) shifted to follow
+// the session's last thought, and its metadata is merged in, overwriting
+// existing keys, as does its verdict. A missing session is created as by
+// ImportSession.
+func (s *Storage) MergeSession(payload []byte) (string, error) {
+	return s.importSession(payload, true)
+}
+
+// importSession implements ImportSession and MergeSession
+func (s *Storage) importSession(payload []byte, merge bool) (string, error) {
+	if limit := s.config.MaxImportBytes; limit > 0 && int64(len(payload)) > limit {
+		return "", fmt.Errorf("%w: payload is %d bytes, limit %d", ErrImportTooLarge, len(payload), limit)
+	}
+
+	var export types.SessionExport
+	if err := json.Unmarshal(payload, &export); err != nil {
+		return "", fmt.Errorf("invalid export: %w", err)
+	}
+	if strings.TrimSpace(export.SessionID) == "" {
+		return "", fmt.Errorf("invalid export: session_id must not be empty")
+	}
+
+	data, err := migrateExport(export.Version, export.Data)
+	if err != nil {
+		return "", err
+	}
+	if err := s.validateImport(export.SessionID, data); err != nil {
+		return "", err
+	}
+
+	sessionID := export.SessionID
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	offset := 0
+	if existing, err := s.GetSession(sessionID); err == nil {
+		if !merge {
+			return "", fmt.Errorf("session %s %w", sessionID, ErrSessionExists)
+		}
+		if offset, err = s.validateMerge(existing, data); err != nil {
+			return "", err
+		}
+	}
+	release, err := s.reserveThoughts(sessionID, len(data.Thoughts))
+	if err != nil {
+		return "", err
+	}
+	session := s.getSession(sessionID)
+	sh := s.shardFor(sessionID)
+
+	sh.thoughtsMutex.Lock()
+	for _, thought := range data.Thoughts {
+		thought.ID = s.generateID()
+		thought.SessionID = sessionID
+		if thought.CreatedAt.IsZero() {
+			thought.CreatedAt = s.now()
+		}
+		if offset > 0 {
+			shiftThoughtNumbers(thought, offset)
+		}
+		sh.thoughts[thought.ID] = thought
+		sh.sessionThoughts[sessionID] = append(sh.sessionThoughts[sessionID], thought.ID)
+	}
+	release()
+	sh.thoughtsMutex.Unlock()
+	s.countEntities(session, len(data.Thoughts), len(data.MentalModels))
+
+	sh.mentalModelsMutex.Lock()
+	for _, model := range data.MentalModels {
+		model.ID = s.generateID()
+		model.SessionID = sessionID
+		if model.CreatedAt.IsZero() {
+			model.CreatedAt = s.now()
+		}
+		sh.mentalModels[model.ID] = model
+		sh.sessionModels[sessionID] = append(sh.sessionModels[sessionID], model.ID)
+		name := s.modelKey(model.ModelName)
+		sh.modelsByName[name] = append(sh.modelsByName[name], modelRef{sessionID: sessionID, modelID: model.ID})
+	}
+	sh.mentalModelsMutex.Unlock()
+
+	if len(data.Metadata) > 0 && session.Metadata == nil {
+		session.Metadata = make(map[string]string, len(data.Metadata))
+	}
+	for key, value := range data.Metadata {
+		if _, exists := session.Metadata[key]; !exists {
+			session.metadataOrder = append(session.metadataOrder, key)
+		}
+		session.Metadata[key] = value
+	}
+	if data.Verdict != nil {
+		session.Verdict = data.Verdict
+	}
+	s.touchSession(session)
+	s.persistSessionContents(sessionID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id":     sessionID,
+		"version":        export.Version,
+		"merged":         merge,
+		"thought_count":  len(data.Thoughts),
+		"mental_models":  len(data.MentalModels),
+		"metadata_count": len(data.Metadata),
+	}).Info("Imported session")
+
+	return sessionID, nil
+}
+
+// validateMerge checks that merged data fits alongside what the session
+// already holds and returns how far to shift the imported thought numbers;
+// callers hold the session lock
+func (s *Storage) validateMerge(session *SessionData, data *importedData) (int, error) {
+	if session.Archived {
+		return 0, fmt.Errorf("session %s is %w", session.ID, ErrSessionArchived)
+	}
+	if session.ThoughtCount+len(data.Thoughts) > s.config.MaxThoughtsPerSession {
+		return 0, fmt.Errorf("%w for session %s: merging %d thoughts into %d exceeds %d", ErrThoughtLimitReached, session.ID, len(data.Thoughts), session.ThoughtCount, s.config.MaxThoughtsPerSession)
+	}
+
+	sh := s.shardFor(session.ID)
+	sh.mentalModelsMutex.RLock()
+	modelCount := len(sh.sessionModels[session.ID])
+	sh.mentalModelsMutex.RUnlock()
+	if limit := s.config.MaxMentalModelsPerSession; limit > 0 && modelCount+len(data.MentalModels) > limit {
+		return 0, fmt.Errorf("merging %d mental models into session %s exceeds the limit of %d", len(data.MentalModels), session.ID, limit)
+	}
+
+	newKeys := 0
+	for key := range data.Metadata {
+		if _, exists := session.Metadata[key]; !exists {
+			newKeys++
+		}
+	}
+	if limit := s.config.MaxMetadataEntries; limit > 0 && len(session.Metadata)+newKeys > limit {
+		return 0, fmt.Errorf("merging metadata into session %s exceeds the limit of %d entries", session.ID, limit)
+	}
+
+	last := s.ThoughtNumberBase() - 1
+	sh.thoughtsMutex.RLock()
+	for _, id := range sh.sessionThoughts[session.ID] {
+		if thought, exists := sh.thoughts[id]; exists && thought.ThoughtNumber > last {
+			last = thought.ThoughtNumber
+		}
+	}
+	sh.thoughtsMutex.RUnlock()
+
+	return last - s.ThoughtNumberBase() + 1, nil
+}
+
+// shiftThoughtNumbers moves a thought and the thoughts it links to offset
+// places later in the sequence
+func shiftThoughtNumbers(thought *types.ThoughtData, offset int) {
+	thought.ThoughtNumber += offset
+	thought.TotalThoughts += offset
+	if thought.RevisesThought != nil {
+		revises := *thought.RevisesThought + offset
+		thought.RevisesThought = &revises
+	}
+	if thought.BranchFromThought != nil {
+		branchFrom := *thought.BranchFromThought + offset
+		thought.BranchFromThought = &branchFrom
+	}
+}
+
+// migrateExport upgrades an export's data section from version to
+// CurrentExportVersion and decodes it
+func migrateExport(version string, raw interface{}) (*importedData, error) {
+	if version == "" {
+		return nil, fmt.Errorf("invalid export: version must not be empty")
+	}
+	newer, err := versionNewer(version, CurrentExportVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export: %w", err)
+	}
+	if newer {
+		return nil, fmt.Errorf("export version %s is newer than the newest supported version %s; upgrade the server to import it", version, CurrentExportVersion)
+	}
+
+	data, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid export: data must be an object")
+	}
+
+	for version != CurrentExportVersion {
+		migration, found := findMigration(version)
+		if !found {
+			return nil, fmt.Errorf("export version %s is not supported", version)
+		}
+		if err := migration.migrate(data); err != nil {
+			return nil, fmt.Errorf("failed to migrate export from version %s to %s: %w", migration.from, migration.to, err)
+		}
+		version = migration.to
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export: %w", err)
+	}
+	var imported importedData
+	if err := json.Unmarshal(encoded, &imported); err != nil {
+		return nil, fmt.Errorf("invalid export data: %w", err)
+	}
+	return &imported, nil
+}
+
+// findMigration returns the migration upgrading from version
+func findMigration(version string) (exportMigration, bool) {
+	for _, migration := range exportMigrations {
+		if migration.from == version {
+			return migration, true
+		}
+	}
+	return exportMigration{}, false
+}
+
+// versionNewer reports whether dotted numeric version a is newer than b
+func versionNewer(a, b string) (bool, error) {
+	partsA, err := parseVersion(a)
+	if err != nil {
+		return false, err
+	}
+	partsB, err := parseVersion(b)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if x != y {
+			return x > y, nil
+		}
+	}
+	return false, nil
+}
+
+// parseVersion splits a dotted numeric version such as "1.0.0"
+func parseVersion(version string) ([]int, error) {
+	fields := strings.Split(version, ".")
+	parts := make([]int, len(fields))
+	for i, field := range fields {
+		part, err := strconv.Atoi(field)
+		if err != nil || part < 0 {
+			return nil, fmt.Errorf("malformed version %q", version)
+		}
+		parts[i] = part
+	}
+	return parts, nil
+}
+
+// validateImport applies the storage limits to imported data so an import
+// either fits entirely or stores nothing
+func (s *Storage) validateImport(sessionID string, data *importedData) error {
+	if limit := s.config.MaxImportEntities; limit > 0 && len(data.Thoughts)+len(data.MentalModels) > limit {
+		return fmt.Errorf("%w: export of session %s has %d thoughts and mental models, limit %d", ErrImportTooLarge, sessionID, len(data.Thoughts)+len(data.MentalModels), limit)
+	}
+	if len(data.Thoughts) > s.config.MaxThoughtsPerSession {
+		return fmt.Errorf("export of session %s has %d thoughts, limit %d", sessionID, len(data.Thoughts), s.config.MaxThoughtsPerSession)
+	}
+	if limit := s.config.MaxMentalModelsPerSession; limit > 0 && len(data.MentalModels) > limit {
+		return fmt.Errorf("export of session %s has %d mental models, limit %d", sessionID, len(data.MentalModels), limit)
+	}
+	if limit := s.config.MaxMetadataEntries; limit > 0 && len(data.Metadata) > limit {
+		return fmt.Errorf("export of session %s has %d metadata entries, limit %d", sessionID, len(data.Metadata), limit)
+	}
+	if data.Verdict != nil && (data.Verdict.Confidence < 0 || data.Verdict.Confidence > 1) {
+		return fmt.Errorf("export of session %s has verdict confidence %g outside 0-1", sessionID, data.Verdict.Confidence)
+	}
+
+	base := s.ThoughtNumberBase()
+	for i, thought := range data.Thoughts {
+		if thought == nil {
+			return fmt.Errorf("export of session %s has an empty thought at index %d", sessionID, i)
+		}
+		if thought.ThoughtNumber < base {
+			return fmt.Errorf("thought number %d is below the first thought number %d", thought.ThoughtNumber, base)
+		}
+		for _, reference := range thought.References {
+			if err := reference.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	for i, model := range data.MentalModels {
+		if model == nil {
+			return fmt.Errorf("export of session %s has an empty mental model at index %d", sessionID, i)
+		}
+	}
+	for key, value := range data.Metadata {
+		if key == "" {
+			return fmt.Errorf("metadata key must not be empty")
+		}
+		if limit := s.config.MaxMetadataKeyLength; limit > 0 && len(key) > limit {
+			return fmt.Errorf("metadata key exceeds maximum length of %d characters", limit)
+		}
+		if limit := s.config.MaxMetadataValueLength; limit > 0 && len(value) > limit {
+			return fmt.Errorf("metadata value for key '%s' exceeds maximum length of %d characters", key, limit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// VerifySession checks a session's internal invariants: sequential trunk
+// numbering, existing revision and branch targets, resolvable index entries
+// and stored counts matching the actual entities
+func (s *Storage) VerifySession(sessionID string) (*types.SessionVerification, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &types.SessionVerification{
+		SessionID:  sessionID,
+		Violations: []types.IntegrityViolation{},
+	}
+	report := func(violation types.IntegrityViolation) {
+		verification.Violations = append(verification.Violations, violation)
+	}
+
+	// Resolve the thought index, reporting entries with no stored thought
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.RLock()
+	var thoughts []*types.ThoughtData
+	for _, id := range sh.sessionThoughts[sessionID] {
+		thought, exists := sh.thoughts[id]
+		if !exists {
+			report(types.IntegrityViolation{
+				Kind:      types.ViolationMissingEntity,
+				Message:   fmt.Sprintf("thought %s is indexed but not stored", id),
+				ThoughtID: id,
+			})
+			continue
+		}
+		thoughts = append(thoughts, thought)
+	}
+	sh.thoughtsMutex.RUnlock()
+
+	sh.mentalModelsMutex.RLock()
+	for _, id := range sh.sessionModels[sessionID] {
+		if _, exists := sh.mentalModels[id]; !exists {
+			report(types.IntegrityViolation{
+				Kind:    types.ViolationMissingEntity,
+				Message: fmt.Sprintf("mental model %s is indexed but not stored", id),
+			})
+		}
+	}
+	sh.mentalModelsMutex.RUnlock()
+
+	// Trunk thoughts must be numbered base..n without gaps or duplicates
+	numbers := make(map[int]int)
+	trunkNumbers := make(map[int]int)
+	for _, thought := range thoughts {
+		numbers[thought.ThoughtNumber]++
+		if thought.BranchID == "" {
+			trunkNumbers[thought.ThoughtNumber]++
+		}
+	}
+
+	sortedNumbers := make([]int, 0, len(trunkNumbers))
+	for number := range trunkNumbers {
+		sortedNumbers = append(sortedNumbers, number)
+	}
+	sort.Ints(sortedNumbers)
+
+	expected := s.ThoughtNumberBase()
+	for _, number := range sortedNumbers {
+		if number < s.ThoughtNumberBase() {
+			report(types.IntegrityViolation{
+				Kind:          types.ViolationNumberBelowBase,
+				Message:       fmt.Sprintf("thought number %d is below the first thought number %d", number, s.ThoughtNumberBase()),
+				ThoughtNumber: number,
+			})
+			continue
+		}
+		for ; expected < number; expected++ {
+			report(types.IntegrityViolation{
+				Kind:          types.ViolationNumberingGap,
+				Message:       fmt.Sprintf("thought number %d is missing from the sequence", expected),
+				ThoughtNumber: expected,
+			})
+		}
+		if count := trunkNumbers[number]; count > 1 {
+			report(types.IntegrityViolation{
+				Kind:          types.ViolationDuplicateNumber,
+				Message:       fmt.Sprintf("thought number %d is used by %d thoughts", number, count),
+				ThoughtNumber: number,
+			})
+		}
+		expected = number + 1
+	}
+
+	// Revision and branch pointers must reference existing thoughts
+	for _, thought := range thoughts {
+		if thought.RevisesThought != nil && numbers[*thought.RevisesThought] == 0 {
+			report(types.IntegrityViolation{
+				Kind:          types.ViolationDanglingRevision,
+				Message:       fmt.Sprintf("thought %d revises nonexistent thought %d", thought.ThoughtNumber, *thought.RevisesThought),
+				ThoughtID:     thought.ID,
+				ThoughtNumber: thought.ThoughtNumber,
+			})
+		}
+		if thought.BranchFromThought != nil && numbers[*thought.BranchFromThought] == 0 {
+			report(types.IntegrityViolation{
+				Kind:          types.ViolationDanglingBranch,
+				Message:       fmt.Sprintf("thought %d branches from nonexistent thought %d", thought.ThoughtNumber, *thought.BranchFromThought),
+				ThoughtID:     thought.ID,
+				ThoughtNumber: thought.ThoughtNumber,
+			})
+		}
+	}
+
+	// Stored counters must agree with the entities actually present
+	if session.ThoughtCount != len(thoughts) {
+		report(types.IntegrityViolation{
+			Kind:    types.ViolationCountMismatch,
+			Message: fmt.Sprintf("session records %d thoughts but %d are stored", session.ThoughtCount, len(thoughts)),
+		})
+	}
+
+	verification.Valid = len(verification.Violations) == 0
+
+	return verification, nil
+}
+
+// RepairSession fixes common inconsistencies found by VerifySession: it drops
+// dangling index entries, renumbers the trunk sequentially (remapping revision
+// and branch links), drops pointers to nonexistent thoughts and reconciles the
+// stored thought count. With dryRun set the changes are reported but not applied.
+func (s *Storage) RepairSession(sessionID string, dryRun bool) (*types.SessionRepair, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Archived && !dryRun {
+		return nil, fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	repair := &types.SessionRepair{
+		SessionID: sessionID,
+		DryRun:    dryRun,
+		Changes:   []types.RepairChange{},
+	}
+	record := func(change types.RepairChange) {
+		repair.Changes = append(repair.Changes, change)
+	}
+
+	// Work on copies so a dry run leaves stored data untouched
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.RLock()
+	var index []string
+	var thoughts []*types.ThoughtData
+	for _, id := range sh.sessionThoughts[sessionID] {
+		thought, exists := sh.thoughts[id]
+		if !exists {
+			record(types.RepairChange{
+				Kind:      types.RepairDroppedIndexEntry,
+				Message:   fmt.Sprintf("dropped index entry for missing thought %s", id),
+				ThoughtID: id,
+			})
+			continue
+		}
+		thoughtCopy := *thought
+		index = append(index, id)
+		thoughts = append(thoughts, &thoughtCopy)
+	}
+	sh.thoughtsMutex.RUnlock()
+
+	// Renumber the trunk base..n in thought order, remembering the old numbers
+	var trunk []*types.ThoughtData
+	for _, thought := range thoughts {
+		if thought.BranchID == "" {
+			trunk = append(trunk, thought)
+		}
+	}
+	trunk = sortedByThoughtNumber(trunk)
+
+	renumbered := make(map[int]int)
+	for i, thought := range trunk {
+		newNumber := s.ThoughtNumberBase() + i
+		if _, seen := renumbered[thought.ThoughtNumber]; !seen {
+			renumbered[thought.ThoughtNumber] = newNumber
+		}
+		if thought.ThoughtNumber != newNumber {
+			record(types.RepairChange{
+				Kind:      types.RepairRenumbered,
+				Message:   fmt.Sprintf("renumbered thought %d to %d", thought.ThoughtNumber, newNumber),
+				ThoughtID: thought.ID,
+			})
+			thought.ThoughtNumber = newNumber
+		}
+	}
+
+	// Remap links to renumbered thoughts, then drop any that still dangle
+	numbers := make(map[int]bool)
+	for _, thought := range thoughts {
+		numbers[thought.ThoughtNumber] = true
+	}
+	for _, thought := range thoughts {
+		if thought.RevisesThought != nil {
+			target := remapThoughtNumber(*thought.RevisesThought, renumbered)
+			if numbers[target] {
+				thought.RevisesThought = &target
+			} else {
+				record(types.RepairChange{
+					Kind:      types.RepairDroppedRevision,
+					Message:   fmt.Sprintf("dropped revision of nonexistent thought %d from thought %d", *thought.RevisesThought, thought.ThoughtNumber),
+					ThoughtID: thought.ID,
+				})
+				thought.RevisesThought = nil
+				thought.IsRevision = false
+			}
+		}
+		if thought.BranchFromThought != nil {
+			target := remapThoughtNumber(*thought.BranchFromThought, renumbered)
+			if numbers[target] {
+				thought.BranchFromThought = &target
+			} else {
+				record(types.RepairChange{
+					Kind:      types.RepairDroppedBranch,
+					Message:   fmt.Sprintf("dropped branch point at nonexistent thought %d from thought %d", *thought.BranchFromThought, thought.ThoughtNumber),
+					ThoughtID: thought.ID,
+				})
+				thought.BranchFromThought = nil
+			}
+		}
+	}
+
+	if session.ThoughtCount != len(thoughts) {
+		record(types.RepairChange{
+			Kind:    types.RepairReconciledCount,
+			Message: fmt.Sprintf("reconciled thought count from %d to %d", session.ThoughtCount, len(thoughts)),
+		})
+	}
+
+	if dryRun {
+		return repair, nil
+	}
+
+	sh.thoughtsMutex.Lock()
+	for _, thought := range thoughts {
+		sh.thoughts[thought.ID] = thought
+	}
+	sh.sessionThoughts[sessionID] = index
+	sh.thoughtsMutex.Unlock()
+
+	s.countEntities(session, len(thoughts)-session.ThoughtCount, 0)
+	s.persistSessionContents(sessionID)
+
+	return repair, nil
+}
+
+// RecomputeStats recounts a session's thoughts and mental models from the
+// stored entities, skipping dangling index entries, and overwrites the
+// session's thought count, remaining thoughts and total operations with the
+// recounted values, reporting every counter that had drifted
+func (s *Storage) RecomputeStats(sessionID string) (*types.StatsRecomputation, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.RLock()
+	thoughtCount := 0
+	for _, id := range sh.sessionThoughts[sessionID] {
+		if _, exists := sh.thoughts[id]; exists {
+			thoughtCount++
+		}
+	}
+	sh.thoughtsMutex.RUnlock()
+
+	sh.mentalModelsMutex.RLock()
+	modelCount := 0
+	for _, id := range sh.sessionModels[sessionID] {
+		if _, exists := sh.mentalModels[id]; exists {
+			modelCount++
+		}
+	}
+	sh.mentalModelsMutex.RUnlock()
+
+	recomputation := &types.StatsRecomputation{
+		SessionID:         sessionID,
+		ThoughtCount:      thoughtCount,
+		RemainingThoughts: s.config.MaxThoughtsPerSession - thoughtCount,
+		TotalOperations:   thoughtCount + modelCount,
+		Stores: map[string]int{
+			"thoughts":      thoughtCount,
+			"mental_models": modelCount,
+		},
+		Corrections: []types.StatCorrection{},
+	}
+
+	correct := func(field string, stored *int, actual int) {
+		if *stored != actual {
+			recomputation.Corrections = append(recomputation.Corrections, types.StatCorrection{
+				Field:  field,
+				Before: *stored,
+				After:  actual,
+			})
+			*stored = actual
+		}
+	}
+	correct("thought_count", &session.ThoughtCount, recomputation.ThoughtCount)
+	correct("remaining_thoughts", &session.RemainingThoughts, recomputation.RemainingThoughts)
+	correct("total_operations", &session.TotalOperations, recomputation.TotalOperations)
+	if len(recomputation.Corrections) > 0 {
+		s.persistSession(session)
+	}
+
+	return recomputation, nil
+}
+
+// remapThoughtNumber translates a thought number through a renumbering
+func remapThoughtNumber(number int, renumbered map[int]int) int {
+	if newNumber, exists := renumbered[number]; exists {
+		return newNumber
+	}
+	return number
+}
@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// runReaper periodically reaps idle sessions until Close is called
+func (s *Storage) runReaper(interval time.Duration) {
+	defer s.reaperDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.ReapSessions()
+		case <-s.stopReaper:
+			return
+		}
+	}
+}
+
+// Close stops the background reaper and ends all event subscriptions; it is
+// safe to call more than once
+func (s *Storage) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stopReaper)
+	})
+	s.reaperDone.Wait()
+	s.closeSubscriptions()
+	s.closeDB.Do(func() {
+		if s.db != nil {
+			if err := s.db.close(); err != nil {
+				s.logger.WithError(err).Error("Failed to close database")
+			}
+		}
+	})
+}
+
+// ReapSessions applies the session lifecycle: sessions not written to for
+// SessionTimeout become idle, and idle sessions are evicted once GracePeriod
+// has also passed. A write during the grace period reactivates a session.
+// Sessions older than MaxSessionLifetime are evicted however active they are.
+func (s *Storage) ReapSessions() (idled, evicted []string) {
+	if s.config.SessionTimeout <= 0 && s.config.MaxSessionLifetime <= 0 {
+		return nil, nil
+	}
+
+	for _, sessionID := range s.sessionIDs() {
+		switch s.reapSession(sessionID) {
+		case reapIdled:
+			idled = append(idled, sessionID)
+		case reapEvicted:
+			evicted = append(evicted, sessionID)
+		}
+	}
+
+	if len(idled) > 0 || len(evicted) > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"idled":   len(idled),
+			"evicted": len(evicted),
+		}).Info("Reaped sessions")
+	}
+
+	return idled, evicted
+}
+
+// PurgeInactive immediately evicts every session not written to for at least
+// idleFor, without waiting for the reaper. ReclaimedBytes is an estimate
+// based on the size of the stored text.
+func (s *Storage) PurgeInactive(idleFor time.Duration) (*types.PurgeResult, error) {
+	if idleFor <= 0 {
+		return nil, fmt.Errorf("idle threshold must be positive, got %s", idleFor)
+	}
+
+	result := &types.PurgeResult{Purged: []string{}}
+	for _, sessionID := range s.sessionIDs() {
+		unlock := s.lockSession(sessionID)
+		session, err := s.GetSession(sessionID)
+		if err == nil && s.now().Sub(session.LastAccessedAt) >= idleFor {
+			result.ReclaimedBytes += s.sessionFootprint(sessionID)
+			result.ItemsRemoved += s.removeSession(sessionID)
+			result.Purged = append(result.Purged, sessionID)
+		}
+		unlock()
+	}
+
+	if len(result.Purged) > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"purged":          len(result.Purged),
+			"reclaimed_bytes": result.ReclaimedBytes,
+		}).Info("Purged inactive sessions")
+	}
+
+	return result, nil
+}
+
+// SessionFilter selects sessions for DeleteSessions; every set field must
+// match, and at least one must be set
+type SessionFilter struct {
+	// Inactive matches sessions the reaper has marked idle
+	Inactive bool
+	// OlderThan matches sessions created at least this long ago
+	OlderThan time.Duration
+	// Tag matches sessions carrying the tag
+	Tag string
+	// IDPrefix matches sessions whose ID starts with the prefix
+	IDPrefix string
+}
+
+// empty reports whether no field of the filter is set
+func (f SessionFilter) empty() bool {
+	return !f.Inactive && f.OlderThan <= 0 && f.Tag == "" && f.IDPrefix == ""
+}
+
+// matches reports whether a session satisfies every set field; callers hold
+// the session lock
+func (f SessionFilter) matches(session *SessionData, now time.Time) bool {
+	if f.Inactive && session.State != types.SessionStateIdle {
+		return false
+	}
+	if f.OlderThan > 0 && now.Sub(session.CreatedAt) < f.OlderThan {
+		return false
+	}
+	if f.Tag != "" && !containsString(session.Tags, f.Tag) {
+		return false
+	}
+	if f.IDPrefix != "" && !strings.HasPrefix(session.ID, f.IDPrefix) {
+		return false
+	}
+	return true
+}
+
+// DeleteSessions removes every session matching the filter. ReclaimedBytes
+// is an estimate based on the size of the stored text.
+func (s *Storage) DeleteSessions(filter SessionFilter) (*types.PurgeResult, error) {
+	if filter.empty() {
+		return nil, fmt.Errorf("at least one filter is required")
+	}
+	if filter.OlderThan < 0 {
+		return nil, fmt.Errorf("older-than threshold must be positive, got %s", filter.OlderThan)
+	}
+
+	result := &types.PurgeResult{Purged: []string{}}
+	for _, sessionID := range s.sessionIDs() {
+		unlock := s.lockSession(sessionID)
+		session, err := s.GetSession(sessionID)
+		if err == nil && filter.matches(session, s.now()) {
+			result.ReclaimedBytes += s.sessionFootprint(sessionID)
+			result.ItemsRemoved += s.removeSession(sessionID)
+			result.Purged = append(result.Purged, sessionID)
+		}
+		unlock()
+	}
+	sort.Strings(result.Purged)
+
+	if len(result.Purged) > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"deleted":         len(result.Purged),
+			"reclaimed_bytes": result.ReclaimedBytes,
+		}).Info("Deleted sessions by filter")
+	}
+
+	return result, nil
+}
+
+// ClearSession deletes a session record with all of its thoughts and mental
+// models, returning the number of thoughts and mental models removed. It
+// returns ErrSessionNotFound for a session that does not exist, including
+// one already cleared.
+func (s *Storage) ClearSession(sessionID string) (int, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	if _, err := s.GetSession(sessionID); err != nil {
+		return 0, err
+	}
+	removed := s.removeSession(sessionID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"removed":    removed,
+	}).Info("Cleared session")
+
+	return removed, nil
+}
+
+// sessionIDs lists the IDs of every stored session
+func (s *Storage) sessionIDs() []string {
+	var sessionIDs []string
+	for _, sh := range s.shards {
+		sh.sessionsMutex.RLock()
+		for sessionID := range sh.sessions {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+		sh.sessionsMutex.RUnlock()
+	}
+	return sessionIDs
+}
+
+// sessionFootprint estimates the bytes held by a session's thoughts and
+// mental models from the length of their text fields
+func (s *Storage) sessionFootprint(sessionID string) int {
+	sh := s.shardFor(sessionID)
+	size := 0
+
+	sh.thoughtsMutex.RLock()
+	for _, id := range sh.sessionThoughts[sessionID] {
+		if thought, exists := sh.thoughts[id]; exists {
+			size += len(thought.ID) + len(thought.Thought) + len(thought.BranchID)
+		}
+	}
+	sh.thoughtsMutex.RUnlock()
+
+	sh.mentalModelsMutex.RLock()
+	for _, id := range sh.sessionModels[sessionID] {
+		if model, exists := sh.mentalModels[id]; exists {
+			size += len(model.ID) + len(model.ModelName) + len(model.Problem) + len(model.Reasoning) + len(model.Conclusion)
+			for _, step := range model.Steps {
+				size += len(step)
+			}
+		}
+	}
+	sh.mentalModelsMutex.RUnlock()
+
+	return size
+}
+
+// reapOutcome is what reaping did to a single session
+type reapOutcome int
+
+const (
+	reapNone reapOutcome = iota
+	reapIdled
+	reapEvicted
+)
+
+// reapSession advances one session through the idle and eviction transitions,
+// evicting it outright once it outlives MaxSessionLifetime
+func (s *Storage) reapSession(sessionID string) reapOutcome {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return reapNone
+	}
+
+	now := s.now()
+	if lifetime := s.config.MaxSessionLifetime; lifetime > 0 && now.Sub(session.CreatedAt) >= lifetime {
+		s.publish(Event{Kind: EventSessionExpired, SessionID: sessionID, At: now})
+		s.removeSession(sessionID)
+		return reapEvicted
+	}
+	if s.config.SessionTimeout <= 0 {
+		return reapNone
+	}
+
+	idleFor := now.Sub(session.LastAccessedAt)
+	switch {
+	case idleFor >= s.config.SessionTimeout+s.config.GracePeriod:
+		s.removeSession(sessionID)
+		return reapEvicted
+	case idleFor >= s.config.SessionTimeout && session.State != types.SessionStateIdle:
+		session.IsActive = false
+		session.State = types.SessionStateIdle
+		return reapIdled
+	}
+
+	return reapNone
+}
+
+// removeSession deletes a session and everything stored for it, returning the
+// number of thoughts and mental models removed; callers hold the session lock
+func (s *Storage) removeSession(sessionID string) int {
+	removed := 0
+	sh := s.shardFor(sessionID)
+
+	sh.thoughtsMutex.Lock()
+	for _, id := range sh.sessionThoughts[sessionID] {
+		if thought, exists := sh.thoughts[id]; exists && thought.SessionID == sessionID {
+			delete(sh.thoughts, id)
+			removed++
+		}
+	}
+	delete(sh.sessionThoughts, sessionID)
+	sh.thoughtsMutex.Unlock()
+
+	sh.mentalModelsMutex.Lock()
+	for _, id := range sh.sessionModels[sessionID] {
+		if model, exists := sh.mentalModels[id]; exists && model.SessionID == sessionID {
+			sh.removeModelRef(s.modelKey(model.ModelName), sessionID)
+			delete(sh.mentalModels, id)
+			removed++
+		}
+	}
+	delete(sh.sessionModels, sessionID)
+	sh.mentalModelsMutex.Unlock()
+
+	sh.sessionsMutex.Lock()
+	if session, exists := sh.sessions[sessionID]; exists {
+		s.tags.remove(sessionID, session.Tags...)
+	}
+	delete(sh.sessions, sessionID)
+	sh.sessionsMutex.Unlock()
+	s.unpersistSession(sessionID)
+
+	s.recentSessions.remove(sessionID)
+
+	s.throughputMutex.Lock()
+	delete(s.sessionThroughput, sessionID)
+	s.throughputMutex.Unlock()
+
+	s.publish(Event{Kind: EventSessionCleared, SessionID: sessionID, At: s.now()})
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"removed":    removed,
+	}).Debug("Removed session")
+
+	return removed
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// recencyList keeps session IDs ordered from most to least recently accessed,
+// so the newest sessions can be read without sorting every session
+type recencyList struct {
+	mu       sync.Mutex
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+// newRecencyList creates an empty recency list
+func newRecencyList() *recencyList {
+	return &recencyList{
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// touch moves a session to the front of the list, adding it if needed
+func (r *recencyList) touch(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, exists := r.elements[sessionID]; exists {
+		r.order.MoveToFront(element)
+		return
+	}
+	r.elements[sessionID] = r.order.PushFront(sessionID)
+}
+
+// remove drops a session from the list
+func (r *recencyList) remove(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, exists := r.elements[sessionID]; exists {
+		r.order.Remove(element)
+		delete(r.elements, sessionID)
+	}
+}
+
+// newest returns up to n session IDs, most recently accessed first
+func (r *recencyList) newest(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, n)
+	for element := r.order.Front(); element != nil && len(ids) < n; element = element.Next() {
+		ids = append(ids, element.Value.(string))
+	}
+	return ids
+}
+
+// oldest returns every session ID, least recently accessed first
+func (r *recencyList) oldest() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, len(r.elements))
+	for element := r.order.Back(); element != nil; element = element.Prev() {
+		ids = append(ids, element.Value.(string))
+	}
+	return ids
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// AnnotateThought attaches references to a stored thought, skipping any it
+// already carries, and returns the thought's references. Every reference is
+// validated before the thought is changed.
+func (s *Storage) AnnotateThought(sessionID, thoughtID string, references []types.Reference) ([]types.Reference, error) {
+	if len(references) == 0 {
+		return nil, fmt.Errorf("at least one reference is required")
+	}
+	for _, reference := range references {
+		if err := reference.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Archived {
+		return nil, fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.Lock()
+	defer sh.thoughtsMutex.Unlock()
+
+	thought, exists := sh.thoughts[thoughtID]
+	if !exists || !containsString(sh.sessionThoughts[sessionID], thoughtID) {
+		return nil, fmt.Errorf("thought %s not found in session %s", thoughtID, sessionID)
+	}
+
+	// Build a new slice so copies of the thought never share its references
+	annotated := append([]types.Reference{}, thought.References...)
+	for _, reference := range references {
+		if !containsReference(annotated, reference) {
+			annotated = append(annotated, reference)
+		}
+	}
+	thought.References = annotated
+	s.persistThoughts(thought)
+	s.touchSession(session)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"thought_id": thoughtID,
+		"references": len(annotated),
+	}).Debug("Annotated thought")
+
+	return append([]types.Reference{}, annotated...), nil
+}
+
+// containsReference reports whether references contains reference
+func containsReference(references []types.Reference, reference types.Reference) bool {
+	for _, r := range references {
+		if r == reference {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// ResolvedThoughts returns the current state of a session's trunk: one entry
+// per original thought, in thought number order, carrying the latest version
+// reachable through revision links (a revision of a revision counts). The
+// most recently stored revision wins when several revise the same thought.
+// Revisions of thoughts that do not exist stand on their own, and branch
+// thoughts are left out.
+func (s *Storage) ResolvedThoughts(sessionID string) ([]types.ResolvedThought, error) {
+	if _, err := s.GetSession(sessionID); err != nil {
+		return nil, err
+	}
+	thoughts, err := s.GetThoughts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var trunk []*types.ThoughtData
+	numbers := make(map[int]bool)
+	for _, thought := range thoughts {
+		if thought.BranchID == "" {
+			trunk = append(trunk, thought)
+			numbers[thought.ThoughtNumber] = true
+		}
+	}
+
+	// Index revisions by the thought number they revise; positions record
+	// storage order so the latest revision can be picked
+	position := make(map[*types.ThoughtData]int, len(trunk))
+	revisedBy := make(map[int][]*types.ThoughtData)
+	var originals []*types.ThoughtData
+	for i, thought := range trunk {
+		position[thought] = i
+		if thought.RevisesThought != nil && numbers[*thought.RevisesThought] && *thought.RevisesThought != thought.ThoughtNumber {
+			revisedBy[*thought.RevisesThought] = append(revisedBy[*thought.RevisesThought], thought)
+			continue
+		}
+		originals = append(originals, thought)
+	}
+
+	resolved := make([]types.ResolvedThought, 0, len(originals))
+	for _, original := range originals {
+		latest := original
+		superseded := []int{}
+
+		visited := map[int]bool{original.ThoughtNumber: true}
+		pending := []int{original.ThoughtNumber}
+		for len(pending) > 0 {
+			number := pending[0]
+			pending = pending[1:]
+			for _, revision := range revisedBy[number] {
+				if position[revision] > position[latest] {
+					latest = revision
+				}
+				if !visited[revision.ThoughtNumber] {
+					visited[revision.ThoughtNumber] = true
+					pending = append(pending, revision.ThoughtNumber)
+				}
+			}
+		}
+		for number := range visited {
+			if number != latest.ThoughtNumber {
+				superseded = append(superseded, number)
+			}
+		}
+		sort.Ints(superseded)
+
+		resolved = append(resolved, types.ResolvedThought{
+			ThoughtNumber: original.ThoughtNumber,
+			Superseded:    superseded,
+			Thought:       latest,
+		})
+	}
+
+	sort.SliceStable(resolved, func(i, j int) bool {
+		return resolved[i].ThoughtNumber < resolved[j].ThoughtNumber
+	})
+
+	return resolved, nil
+}
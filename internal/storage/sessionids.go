@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+)
+
+// Session ID styles selectable via config.SessionIDStyle
+const (
+	SessionIDStyleUUID  = "uuid"
+	SessionIDStyleWords = "words"
+)
+
+// sessionIDWords are combined into readable "adjective-noun-number" IDs
+var (
+	sessionIDAdjectives = []string{
+		"amber", "bold", "brisk", "calm", "clever", "crisp", "eager", "fancy",
+		"gentle", "golden", "humble", "jolly", "keen", "lively", "lucky", "mellow",
+		"nimble", "noble", "plucky", "proud", "quiet", "rapid", "silver", "steady",
+		"sunny", "swift", "tidy", "vivid", "warm", "wise", "witty", "zesty",
+	}
+	sessionIDNouns = []string{
+		"badger", "beacon", "canyon", "cedar", "comet", "delta", "ember", "falcon",
+		"fjord", "garnet", "harbor", "heron", "island", "lantern", "maple", "meadow",
+		"nebula", "orchid", "otter", "pebble", "quartz", "raven", "river", "summit",
+		"thicket", "tiger", "tundra", "valley", "walrus", "willow", "yarrow", "zephyr",
+	}
+)
+
+// sessionIDGenerator mints a candidate ID for a new session
+type sessionIDGenerator func() string
+
+// newSessionIDGenerator returns the generator for a style (empty selects UUIDs)
+func newSessionIDGenerator(style string) (sessionIDGenerator, error) {
+	switch style {
+	case "", SessionIDStyleUUID:
+		return uuid.NewString, nil
+	case SessionIDStyleWords:
+		return wordsSessionID, nil
+	default:
+		return nil, fmt.Errorf("unknown session id style %q (expected %s or %s)", style, SessionIDStyleUUID, SessionIDStyleWords)
+	}
+}
+
+// wordsSessionID returns a human-friendly ID such as "brisk-falcon-4821"
+func wordsSessionID() string {
+	return fmt.Sprintf("%s-%s-%d",
+		sessionIDAdjectives[randomIndex(len(sessionIDAdjectives))],
+		sessionIDNouns[randomIndex(len(sessionIDNouns))],
+		1000+randomIndex(9000),
+	)
+}
+
+// randomIndex returns a uniformly random integer in [0, n)
+func randomIndex(n int) int {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return int(index.Int64())
+}
+
+// NewSessionID mints an ID in the configured style for a session the client
+// did not name, skipping IDs already in use
+func (s *Storage) NewSessionID() string {
+	for {
+		sessionID := s.sessionIDGenerator()
+		if _, err := s.GetSession(sessionID); err != nil {
+			return sessionID
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// shard holds the data of the sessions hashed to it. Each shard has its own
+// locks, so sessions in different shards never contend with each other.
+type shard struct {
+	thoughts     map[string]*types.ThoughtData
+	mentalModels map[string]*types.MentalModelData
+	sessions     map[string]*SessionData
+
+	// Per-session indexes of thought and mental model IDs in insertion order
+	sessionThoughts map[string][]string
+	sessionModels   map[string][]string
+
+	// Mental model applications by model name in insertion order, guarded
+	// by mentalModelsMutex
+	modelsByName map[string][]modelRef
+
+	// Mutexes for thread safety
+	thoughtsMutex     sync.RWMutex
+	mentalModelsMutex sync.RWMutex
+	sessionsMutex     sync.RWMutex
+
+	// Per-session locks serializing all mutations to a single session
+	sessionLocks *sessionLocks
+}
+
+// modelRef locates a mental model application in its session
+type modelRef struct {
+	sessionID string
+	modelID   string
+}
+
+// newShard creates an empty shard
+func newShard() *shard {
+	return &shard{
+		thoughts:        make(map[string]*types.ThoughtData),
+		mentalModels:    make(map[string]*types.MentalModelData),
+		sessions:        make(map[string]*SessionData),
+		sessionThoughts: make(map[string][]string),
+		sessionModels:   make(map[string][]string),
+		modelsByName:    make(map[string][]modelRef),
+		sessionLocks:    newSessionLocks(),
+	}
+}
+
+// removeModelRef drops a session's applications from a model name index
+// entry; callers hold mentalModelsMutex
+func (sh *shard) removeModelRef(name, sessionID string) {
+	refs := sh.modelsByName[name][:0]
+	for _, ref := range sh.modelsByName[name] {
+		if ref.sessionID != sessionID {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		delete(sh.modelsByName, name)
+		return
+	}
+	sh.modelsByName[name] = refs
+}
+
+// newShards creates n shards; values below one yield a single shard
+func newShards(n int) []*shard {
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*shard, n)
+	for i := range shards {
+		shards[i] = newShard()
+	}
+	return shards
+}
+
+// shardFor returns the shard owning a session
+func (s *Storage) shardFor(sessionID string) *shard {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(sessionID))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// lockSession acquires the lock of a session and returns its unlock function
+func (s *Storage) lockSession(sessionID string) func() {
+	return s.shardFor(sessionID).sessionLocks.lock(sessionID)
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// SimilarThoughts groups a session's thoughts whose trigram Jaccard
+// similarity reaches threshold. Thoughts join a cluster when they are similar
+// to any thought already in it, so each thought is in at most one cluster.
+// Clusters hold at least two thoughts and are ordered by their first thought.
+func (s *Storage) SimilarThoughts(sessionID string, threshold float64) ([]types.ThoughtCluster, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("similarity threshold must be in (0, 1], got %g", threshold)
+	}
+	if _, err := s.GetSession(sessionID); err != nil {
+		return nil, err
+	}
+
+	thoughts, err := s.GetThoughts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	grams := make([]map[string]struct{}, len(thoughts))
+	for i, thought := range thoughts {
+		grams[i] = trigrams(thought.Thought)
+	}
+
+	// Union-find over every pair above the threshold, remembering the
+	// closest pair seen in each cluster
+	parent := make([]int, len(thoughts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	best := make(map[int]float64)
+	for i := range thoughts {
+		for j := i + 1; j < len(thoughts); j++ {
+			similarity := jaccard(grams[i], grams[j])
+			if similarity < threshold {
+				continue
+			}
+			rootI, rootJ := find(i), find(j)
+			if rootI != rootJ {
+				// Keep the earlier thought as the root so clusters stay ordered
+				if rootJ < rootI {
+					rootI, rootJ = rootJ, rootI
+				}
+				parent[rootJ] = rootI
+				if best[rootJ] > best[rootI] {
+					best[rootI] = best[rootJ]
+				}
+				delete(best, rootJ)
+			}
+			if similarity > best[rootI] {
+				best[rootI] = similarity
+			}
+		}
+	}
+
+	members := make(map[int][]*types.ThoughtData)
+	for i, thought := range thoughts {
+		root := find(i)
+		members[root] = append(members[root], thought)
+	}
+
+	roots := make([]int, 0, len(members))
+	for root, cluster := range members {
+		if len(cluster) > 1 {
+			roots = append(roots, root)
+		}
+	}
+	sort.Ints(roots)
+
+	clusters := make([]types.ThoughtCluster, 0, len(roots))
+	for _, root := range roots {
+		clusters = append(clusters, types.ThoughtCluster{
+			Thoughts:      members[root],
+			MaxSimilarity: best[root],
+		})
+	}
+
+	return clusters, nil
+}
+
+// trigrams returns the set of three-character sequences of text, compared
+// case-insensitively with whitespace collapsed; shorter texts are their own
+// single gram
+func trigrams(text string) map[string]struct{} {
+	runes := []rune(strings.Join(strings.Fields(strings.ToLower(text)), " "))
+	grams := make(map[string]struct{})
+	if len(runes) < 3 {
+		if len(runes) > 0 {
+			grams[string(runes)] = struct{}{}
+		}
+		return grams
+	}
+	for i := 0; i+3 <= len(runes); i++ {
+		grams[string(runes[i:i+3])] = struct{}{}
+	}
+	return grams
+}
+
+// jaccard returns the size of the intersection of two sets over their union
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for gram := range a {
+		if _, exists := b[gram]; exists {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+
+	// Registers the pure-Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates one table per entity, keyed by session ID. Rows hold
+// the entity as JSON; seq keeps thoughts and models in insertion order.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS thoughts (
+	seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	UNIQUE (session_id, id)
+);
+CREATE INDEX IF NOT EXISTS thoughts_by_session ON thoughts (session_id, seq);
+CREATE TABLE IF NOT EXISTS mental_models (
+	seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	UNIQUE (session_id, id)
+);
+CREATE INDEX IF NOT EXISTS mental_models_by_session ON mental_models (session_id, seq);
+`
+
+// sqliteStore persists sessions, thoughts and mental models to a SQLite
+// database. Storage keeps serving reads from memory and writes through to
+// the database, which is loaded back into memory on startup.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLite opens (creating if needed) the database at path
+func openSQLite(path string) (*sqliteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("persistence is enabled but no persistence path is configured")
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	// SQLite allows a single writer; one connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema in %s: %w", path, err)
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+// close closes the database
+func (d *sqliteStore) close() error {
+	return d.db.Close()
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// saveSession inserts or replaces a session record
+func (d *sqliteStore) saveSession(session *SessionData) error {
+	return upsertSession(d.db, session)
+}
+
+// saveThoughts inserts or updates thoughts, keeping existing rows in place
+func (d *sqliteStore) saveThoughts(thoughts ...*types.ThoughtData) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		for _, thought := range thoughts {
+			if err := upsertEntity(tx, "thoughts", thought.SessionID, thought.ID, thought); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveMentalModels inserts or updates mental models, keeping existing rows in place
+func (d *sqliteStore) saveMentalModels(models ...*types.MentalModelData) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		for _, model := range models {
+			if err := upsertEntity(tx, "mental_models", model.SessionID, model.ID, model); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replaceSession rewrites a session and everything stored for it
+func (d *sqliteStore) replaceSession(session *SessionData, thoughts []*types.ThoughtData, models []*types.MentalModelData) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		if err := deleteSessionRows(tx, session.ID); err != nil {
+			return err
+		}
+		if err := upsertSession(tx, session); err != nil {
+			return err
+		}
+		for _, thought := range thoughts {
+			if err := upsertEntity(tx, "thoughts", session.ID, thought.ID, thought); err != nil {
+				return err
+			}
+		}
+		for _, model := range models {
+			if err := upsertEntity(tx, "mental_models", session.ID, model.ID, model); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteSession removes a session and everything stored for it
+func (d *sqliteStore) deleteSession(sessionID string) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		return deleteSessionRows(tx, sessionID)
+	})
+}
+
+// load reads every session, thought and mental model, with thoughts and
+// models in insertion order
+func (d *sqliteStore) load() ([]*SessionData, []*types.ThoughtData, []*types.MentalModelData, error) {
+	var sessions []*SessionData
+	if err := d.scan("SELECT data FROM sessions", func(data []byte) error {
+		session := &SessionData{}
+		if err := json.Unmarshal(data, session); err != nil {
+			return err
+		}
+		sessions = append(sessions, session)
+		return nil
+	}); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	var thoughts []*types.ThoughtData
+	if err := d.scan("SELECT data FROM thoughts ORDER BY seq", func(data []byte) error {
+		thought := &types.ThoughtData{}
+		if err := json.Unmarshal(data, thought); err != nil {
+			return err
+		}
+		thoughts = append(thoughts, thought)
+		return nil
+	}); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load thoughts: %w", err)
+	}
+
+	var models []*types.MentalModelData
+	if err := d.scan("SELECT data FROM mental_models ORDER BY seq", func(data []byte) error {
+		model := &types.MentalModelData{}
+		if err := json.Unmarshal(data, model); err != nil {
+			return err
+		}
+		models = append(models, model)
+		return nil
+	}); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load mental models: %w", err)
+	}
+
+	return sessions, thoughts, models, nil
+}
+
+// scan runs a query returning one data column and passes each row to fn
+func (d *sqliteStore) scan(query string, fn func(data []byte) error) error {
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// inTx runs fn in a transaction, committing only if it succeeds
+func (d *sqliteStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// upsertSession writes a session record
+func upsertSession(db execer, session *SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO sessions (id, data) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, session.ID, string(data))
+	return err
+}
+
+// upsertEntity writes a thought or mental model row
+func upsertEntity(db execer, table, sessionID, id string, entity interface{}) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO `+table+` (session_id, id, data) VALUES (?, ?, ?)
+		ON CONFLICT (session_id, id) DO UPDATE SET data = excluded.data`, sessionID, id, string(data))
+	return err
+}
+
+// deleteSessionRows removes every row stored for a session
+func deleteSessionRows(db execer, sessionID string) error {
+	for _, table := range []string{"thoughts", "mental_models", "sessions"} {
+		column := "session_id"
+		if table == "sessions" {
+			column = "id"
+		}
+		if _, err := db.Exec(`DELETE FROM `+table+` WHERE `+column+` = ?`, sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// Write-through helpers
+// ============================================================================
+
+// loadPersisted fills the in-memory stores from the database
+func (s *Storage) loadPersisted() error {
+	sessions, thoughts, models, err := s.db.load()
+	if err != nil {
+		return err
+	}
+
+	// Touch oldest first so the recency list ends newest first
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastAccessedAt.Before(sessions[j].LastAccessedAt)
+	})
+	for _, session := range sessions {
+		if session.ToolsUsed == nil {
+			session.ToolsUsed = []string{}
+		}
+		for key := range session.Metadata {
+			session.metadataOrder = append(session.metadataOrder, key)
+		}
+		sort.Strings(session.metadataOrder)
+
+		sh := s.shardFor(session.ID)
+		sh.sessions[session.ID] = session
+		s.recentSessions.touch(session.ID)
+		s.tags.add(session.ID, session.Tags...)
+	}
+	for _, thought := range thoughts {
+		sh := s.shardFor(thought.SessionID)
+		sh.thoughts[thought.ID] = thought
+		sh.sessionThoughts[thought.SessionID] = append(sh.sessionThoughts[thought.SessionID], thought.ID)
+	}
+	for _, model := range models {
+		sh := s.shardFor(model.SessionID)
+		sh.mentalModels[model.ID] = model
+		sh.sessionModels[model.SessionID] = append(sh.sessionModels[model.SessionID], model.ID)
+		name := s.modelKey(model.ModelName)
+		sh.modelsByName[name] = append(sh.modelsByName[name], modelRef{sessionID: model.SessionID, modelID: model.ID})
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"sessions":      len(sessions),
+		"thoughts":      len(thoughts),
+		"mental_models": len(models),
+	}).Info("Loaded persisted sessions")
+
+	return nil
+}
+
+// persistSession writes a session record through to the database when
+// persistence is enabled; callers hold the session lock
+func (s *Storage) persistSession(session *SessionData) {
+	if s.db == nil {
+		return
+	}
+	if err := s.db.saveSession(session); err != nil {
+		s.logger.WithError(err).WithField("session_id", session.ID).Error("Failed to persist session")
+	}
+}
+
+// persistThoughts writes changed thoughts through to the database when
+// persistence is enabled; callers hold the session lock
+func (s *Storage) persistThoughts(thoughts ...*types.ThoughtData) {
+	if s.db == nil || len(thoughts) == 0 {
+		return
+	}
+	if err := s.db.saveThoughts(thoughts...); err != nil {
+		s.logger.WithError(err).WithField("session_id", thoughts[0].SessionID).Error("Failed to persist thoughts")
+	}
+}
+
+// persistSessionContents rewrites a session and everything stored for it in
+// the database when persistence is enabled; callers hold the session lock but
+// not the shard's thought or mental model locks
+func (s *Storage) persistSessionContents(sessionID string) {
+	if s.db == nil {
+		return
+	}
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return
+	}
+	thoughts, _ := s.GetThoughts(sessionID)
+	models, _ := s.GetMentalModels(sessionID)
+
+	if err := s.db.replaceSession(session, thoughts, models); err != nil {
+		s.logger.WithError(err).WithField("session_id", sessionID).Error("Failed to persist session contents")
+	}
+}
+
+// unpersistSession deletes a session from the database when persistence is
+// enabled; callers hold the session lock
+func (s *Storage) unpersistSession(sessionID string) {
+	if s.db == nil {
+		return
+	}
+	if err := s.db.deleteSession(sessionID); err != nil {
+		s.logger.WithError(err).WithField("session_id", sessionID).Error("Failed to delete persisted session")
+	}
+}
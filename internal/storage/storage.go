@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rainmana/gothink/internal/config"
@@ -10,44 +14,221 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ErrSessionArchived is returned when a write targets an archived session
+var ErrSessionArchived = errors.New("archived")
+
+// ErrSessionNotFound is returned when a session does not exist
+var ErrSessionNotFound = errors.New("not found")
+
+// ErrSessionExists is returned when an import targets an existing session
+// without merging into it
+var ErrSessionExists = errors.New("already exists")
+
+// ErrThoughtLimitReached is returned when a session already holds
+// MaxThoughtsPerSession thoughts
+var ErrThoughtLimitReached = errors.New("thought limit reached")
+
+// ErrMentalModelLimitReached is returned when storing mental models would take
+// a session past MaxMentalModelsPerSession
+var ErrMentalModelLimitReached = errors.New("mental model limit reached")
+
 // Storage manages all data storage for the GoThink server
 type Storage struct {
 	config *config.Config
 	logger *logrus.Logger
 
-	// In-memory stores (in production, these would be backed by a database)
-	thoughts     map[string]*types.ThoughtData
-	mentalModels map[string]*types.MentalModelData
-	sessions     map[string]*SessionData
+	// In-memory stores partitioned by session ID (in production, these would
+	// be backed by a database)
+	shards []*shard
+
+	// Sessions ordered by most recent access
+	recentSessions *recencyList
+
+	// Sessions indexed by tag
+	tags *tagIndex
+
+	// Thought ingestion rate, globally and per session
+	thoughtThroughput throughputCounter
+	sessionThroughput map[string]*throughputCounter
+	throughputMutex   sync.Mutex
+
+	// ID generator selected by config.IDStrategy
+	idGenerator idGenerator
+	// Session ID generator selected by config.SessionIDStyle
+	sessionIDGenerator sessionIDGenerator
+
+	// Per-session event subscriptions
+	subscribers *subscribers
 
-	// Mutexes for thread safety
-	thoughtsMutex     sync.RWMutex
-	mentalModelsMutex sync.RWMutex
-	sessionsMutex     sync.RWMutex
+	// capacityMutex serializes making room under MaxTotalThoughts;
+	// reservedThoughts counts room reserved for thoughts not yet stored and
+	// is updated atomically
+	capacityMutex    sync.Mutex
+	reservedThoughts int64
+
+	// db persists sessions when config.EnablePersistence is set (nil otherwise)
+	db *sqliteStore
+
+	// now returns the current time; replaceable in tests
+	now func() time.Time
+
+	// Background reaper lifecycle
+	stopReaper chan struct{}
+	closeOnce  sync.Once
+	reaperDone sync.WaitGroup
+	closeDB    sync.Once
+}
+
+// sessionLocks hands out one mutex per session so that writes to the same
+// session are serialized while different sessions proceed concurrently.
+// Entries are reference counted and dropped once no caller holds or waits
+// for them, so the map only grows with sessions in use.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+
+	// contended counts acquisitions that found the session lock held and
+	// had to wait; updated atomically
+	contended uint64
+}
+
+// sessionLock is a session's mutex and the number of callers holding or
+// waiting for it; refs is guarded by sessionLocks.mu
+type sessionLock struct {
+	sync.Mutex
+	refs int
+}
+
+// newSessionLocks creates an empty keyed lock map
+func newSessionLocks() *sessionLocks {
+	return &sessionLocks{locks: make(map[string]*sessionLock)}
+}
+
+// lock acquires the mutex for a session and returns its unlock function
+func (l *sessionLocks) lock(sessionID string) func() {
+	l.mu.Lock()
+	m, exists := l.locks[sessionID]
+	if !exists {
+		m = &sessionLock{}
+		l.locks[sessionID] = m
+	}
+	m.refs++
+	l.mu.Unlock()
+
+	// Probe first so the uncontended path costs a single CAS
+	if !m.TryLock() {
+		atomic.AddUint64(&l.contended, 1)
+		m.Lock()
+	}
+	return l.unlockFunc(sessionID, m)
+}
+
+// tryLock acquires the mutex for a session only when no caller holds or is
+// waiting for it, returning its unlock function and whether it was acquired
+func (l *sessionLocks) tryLock(sessionID string) (func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, exists := l.locks[sessionID]; exists {
+		return nil, false
+	}
+	m := &sessionLock{refs: 1}
+	m.Lock()
+	l.locks[sessionID] = m
+	return l.unlockFunc(sessionID, m), true
+}
+
+// unlockFunc returns the function releasing a held session mutex, dropping
+// it from the map once no caller holds or waits for it
+func (l *sessionLocks) unlockFunc(sessionID string, m *sessionLock) func() {
+	return func() {
+		m.Unlock()
+
+		l.mu.Lock()
+		m.refs--
+		if m.refs == 0 {
+			delete(l.locks, sessionID)
+		}
+		l.mu.Unlock()
+	}
 }
 
 // SessionData represents session-specific data
 type SessionData struct {
-	ID                string    `json:"id"`
-	CreatedAt         time.Time `json:"created_at"`
-	LastAccessedAt    time.Time `json:"last_accessed_at"`
-	ThoughtCount      int       `json:"thought_count"`
-	ToolsUsed         []string  `json:"tools_used"`
-	TotalOperations   int       `json:"total_operations"`
-	IsActive          bool      `json:"is_active"`
-	RemainingThoughts int       `json:"remaining_thoughts"`
+	ID                string            `json:"id"`
+	CreatedAt         time.Time         `json:"created_at"`
+	LastAccessedAt    time.Time         `json:"last_accessed_at"`
+	ThoughtCount      int               `json:"thought_count"`
+	ToolsUsed         []string          `json:"tools_used"`
+	TotalOperations   int               `json:"total_operations"`
+	IsActive          bool              `json:"is_active"`
+	State             string            `json:"state"`
+	RemainingThoughts int               `json:"remaining_thoughts"`
+	Archived          bool              `json:"archived"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Tags              []string          `json:"tags,omitempty"`
+	// Verdict is the session's overall conclusion, once one is set
+	Verdict *types.SessionVerdict `json:"verdict,omitempty"`
+
+	// metadataOrder lists metadata keys oldest first for ring overflow
+	metadataOrder []string
+	// audit is the session's capped trail of tool calls
+	audit []types.AuditEntry
+	// checkpoints are named snapshots of the session, oldest first
+	checkpoints []*checkpoint
 }
 
 // New creates a new storage instance
 func New(cfg *config.Config) (*Storage, error) {
+	generator, err := newIDGenerator(cfg.IDStrategy)
+	if err != nil {
+		return nil, err
+	}
+	sessionIDGenerator, err := newSessionIDGenerator(cfg.SessionIDStyle)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ThoughtNumberBase != 0 && cfg.ThoughtNumberBase != 1 {
+		return nil, fmt.Errorf("invalid thought number base %d (expected 0 or 1)", cfg.ThoughtNumberBase)
+	}
+	for _, policy := range []string{cfg.ToolsUsedOverflow, cfg.AuditOverflow, cfg.MetadataOverflow} {
+		if err := validateOverflow(policy); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Storage{
+		config:         cfg,
+		logger:         logrus.New(),
+		shards:         newShards(cfg.StorageShards),
+		recentSessions: newRecencyList(),
+		tags:           newTagIndex(),
+
+		sessionThroughput:  make(map[string]*throughputCounter),
+		idGenerator:        generator,
+		sessionIDGenerator: sessionIDGenerator,
+		subscribers:        newSubscribers(),
+		now:                time.Now,
+		stopReaper:         make(chan struct{}),
+	}
+
+	if cfg.EnablePersistence {
+		db, err := openSQLite(cfg.PersistencePath)
+		if err != nil {
+			return nil, err
+		}
+		s.db = db
+		if err := s.loadPersisted(); err != nil {
+			db.close()
+			return nil, err
+		}
+	}
+
+	if (cfg.SessionTimeout > 0 || cfg.MaxSessionLifetime > 0) && cfg.ReaperInterval > 0 {
+		s.reaperDone.Add(1)
+		go s.runReaper(cfg.ReaperInterval)
+	}
 
-	return &Storage{
-		config:       cfg,
-		logger:       logrus.New(),
-		thoughts:     make(map[string]*types.ThoughtData),
-		mentalModels: make(map[string]*types.MentalModelData),
-		sessions:     make(map[string]*SessionData),
-	}, nil
+	return s, nil
 }
 
 // ============================================================================
@@ -56,27 +237,58 @@ func New(cfg *config.Config) (*Storage, error) {
 
 // AddThought adds a new thought to storage
 func (s *Storage) AddThought(sessionID string, thought *types.ThoughtData) error {
-	s.thoughtsMutex.Lock()
-	defer s.thoughtsMutex.Unlock()
+	unlock := s.lockSession(sessionID)
+	defer unlock()
 
-	// Check thought limit
 	session := s.getSession(sessionID)
+	if session.Archived {
+		return fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	// Check thought limit
 	if session.ThoughtCount >= s.config.MaxThoughtsPerSession {
-		return fmt.Errorf("thought limit reached for session %s", sessionID)
+		return fmt.Errorf("%w for session %s: it already holds the maximum of %d thoughts", ErrThoughtLimitReached, sessionID, s.config.MaxThoughtsPerSession)
+	}
+	if base := s.ThoughtNumberBase(); thought.ThoughtNumber < base {
+		return fmt.Errorf("thought number %d is below the first thought number %d", thought.ThoughtNumber, base)
+	}
+
+	release, err := s.reserveThoughts(sessionID, 1)
+	if err != nil {
+		return err
 	}
 
 	// Generate ID if not provided
 	if thought.ID == "" {
-		thought.ID = generateID()
+		thought.ID = s.generateID()
+	}
+	thought.SessionID = sessionID
+	thought.CreatedAt = s.now()
+	if s.db != nil {
+		if err := s.db.saveThoughts(thought); err != nil {
+			release()
+			return fmt.Errorf("failed to persist thought: %w", err)
+		}
 	}
-	thought.CreatedAt = time.Now()
 
-	s.thoughts[thought.ID] = thought
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.Lock()
+	sh.thoughts[thought.ID] = thought
+	sh.sessionThoughts[sessionID] = append(sh.sessionThoughts[sessionID], thought.ID)
+	release()
+	sh.thoughtsMutex.Unlock()
 
 	// Update session
-	session.ThoughtCount++
-	session.LastAccessedAt = time.Now()
-	s.sessions[sessionID] = session
+	s.countEntities(session, 1, 0)
+	s.touchSession(session)
+
+	s.recordThroughput(sessionID, thought.CreatedAt)
+
+	thoughtCopy := *thought
+	s.publish(Event{Kind: EventThoughtAdded, SessionID: sessionID, Thought: &thoughtCopy, At: thought.CreatedAt})
+	if session.ThoughtCount == s.config.MaxThoughtsPerSession {
+		s.publish(Event{Kind: EventThoughtLimitReached, SessionID: sessionID, Thought: &thoughtCopy, At: thought.CreatedAt})
+	}
 
 	s.logger.WithFields(logrus.Fields{
 		"session_id":     sessionID,
@@ -87,75 +299,360 @@ func (s *Storage) AddThought(sessionID string, thought *types.ThoughtData) error
 	return nil
 }
 
+// ThoughtNumberBase returns the number of the first thought in a sequence
+func (s *Storage) ThoughtNumberBase() int {
+	return s.config.ThoughtNumberBase
+}
+
 // GetThoughts retrieves all thoughts for a session
 func (s *Storage) GetThoughts(sessionID string) ([]*types.ThoughtData, error) {
-	s.thoughtsMutex.RLock()
-	defer s.thoughtsMutex.RUnlock()
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.RLock()
+	defer sh.thoughtsMutex.RUnlock()
 
 	var sessionThoughts []*types.ThoughtData
-	for _, thought := range s.thoughts {
-		// In a real implementation, you'd filter by session ID
-		sessionThoughts = append(sessionThoughts, thought)
+	for _, id := range sh.sessionThoughts[sessionID] {
+		if thought, exists := sh.thoughts[id]; exists && thought.SessionID == sessionID {
+			sessionThoughts = append(sessionThoughts, thought)
+		}
 	}
 
 	return sessionThoughts, nil
 }
 
+// ThoughtsAfter returns the thoughts stored in a session after the one with
+// the given ID, in session order, for cursor-based tailing. An empty ID
+// returns every thought; an ID not in the session is an error.
+func (s *Storage) ThoughtsAfter(sessionID, afterID string) ([]*types.ThoughtData, error) {
+	thoughts, err := s.GetThoughts(sessionID)
+	if err != nil || afterID == "" {
+		return thoughts, err
+	}
+
+	for i, thought := range thoughts {
+		if thought.ID == afterID {
+			return thoughts[i+1:], nil
+		}
+	}
+	return nil, fmt.Errorf("thought %s not found in session %s", afterID, sessionID)
+}
+
+// ThoughtFilter selects thoughts by simple predicates; zero values match everything
+type ThoughtFilter struct {
+	IsRevision *bool
+	BranchID   string
+	Since      time.Time
+	// MinNumber and MaxNumber bound the thought number, inclusive
+	MinNumber *int
+	MaxNumber *int
+}
+
+// matches reports whether a thought satisfies every set predicate
+func (f ThoughtFilter) matches(thought *types.ThoughtData) bool {
+	if f.IsRevision != nil && thought.IsRevision != *f.IsRevision {
+		return false
+	}
+	if f.BranchID != "" && thought.BranchID != f.BranchID {
+		return false
+	}
+	if !f.Since.IsZero() && thought.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if f.MinNumber != nil && thought.ThoughtNumber < *f.MinNumber {
+		return false
+	}
+	if f.MaxNumber != nil && thought.ThoughtNumber > *f.MaxNumber {
+		return false
+	}
+	return true
+}
+
+// CountThoughts counts a session's thoughts matching the filter without copying them
+func (s *Storage) CountThoughts(sessionID string, filter ThoughtFilter) int {
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.RLock()
+	defer sh.thoughtsMutex.RUnlock()
+
+	count := 0
+	for _, id := range sh.sessionThoughts[sessionID] {
+		if thought, exists := sh.thoughts[id]; exists && thought.SessionID == sessionID && filter.matches(thought) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// GetPendingThoughts returns thoughts still awaiting continuation, keyed by session.
+// An empty sessionID searches every session.
+func (s *Storage) GetPendingThoughts(sessionID string) map[string][]*types.ThoughtData {
+	shards := s.shards
+	if sessionID != "" {
+		shards = []*shard{s.shardFor(sessionID)}
+	}
+
+	pending := make(map[string][]*types.ThoughtData)
+	for _, sh := range shards {
+		sh.thoughtsMutex.RLock()
+		for id, thoughtIDs := range sh.sessionThoughts {
+			if sessionID != "" && id != sessionID {
+				continue
+			}
+			for _, thoughtID := range thoughtIDs {
+				thought, exists := sh.thoughts[thoughtID]
+				if exists && (thought.NextThoughtNeeded || thought.NeedsMoreThoughts) {
+					pending[id] = append(pending[id], thought)
+				}
+			}
+		}
+		sh.thoughtsMutex.RUnlock()
+	}
+
+	return pending
+}
+
 // ============================================================================
 // Mental Model Management
 // ============================================================================
 
 // AddMentalModel adds a mental model application to storage
 func (s *Storage) AddMentalModel(sessionID string, model *types.MentalModelData) error {
-	s.mentalModelsMutex.Lock()
-	defer s.mentalModelsMutex.Unlock()
+	return s.AddMentalModels(sessionID, []*types.MentalModelData{model})
+}
+
+// AddMentalModels adds a batch of mental model applications to storage.
+// The per-session cap is enforced for the batch as a whole: either every
+// model is stored or none are.
+func (s *Storage) AddMentalModels(sessionID string, models []*types.MentalModelData) error {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
 
-	if model.ID == "" {
-		model.ID = generateID()
+	session := s.getSession(sessionID)
+	if session.Archived {
+		return fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
 	}
-	model.CreatedAt = time.Now()
 
-	s.mentalModels[model.ID] = model
+	sh := s.shardFor(sessionID)
+	sh.mentalModelsMutex.Lock()
+	defer sh.mentalModelsMutex.Unlock()
 
-	// Update session
-	session := s.getSession(sessionID)
-	session.LastAccessedAt = time.Now()
-	s.sessions[sessionID] = session
+	// Check mental model limit
+	stored := len(sh.sessionModels[sessionID])
+	if limit := s.config.MaxMentalModelsPerSession; limit > 0 && stored+len(models) > limit {
+		return fmt.Errorf("%w for session %s: %d stored, %d requested, limit %d", ErrMentalModelLimitReached, sessionID, stored, len(models), limit)
+	}
+	if err := s.checkCooldown(sh, sessionID, models); err != nil {
+		return err
+	}
 
-	s.logger.WithFields(logrus.Fields{
-		"session_id": sessionID,
-		"model_id":   model.ID,
-		"model_name": model.ModelName,
-	}).Debug("Added mental model to storage")
+	for _, model := range models {
+		if model.ID == "" {
+			model.ID = s.generateID()
+		}
+		model.SessionID = sessionID
+		model.CreatedAt = s.now()
+	}
+	if s.db != nil {
+		if err := s.db.saveMentalModels(models...); err != nil {
+			return fmt.Errorf("failed to persist mental models: %w", err)
+		}
+	}
+
+	for _, model := range models {
+		sh.mentalModels[model.ID] = model
+		sh.sessionModels[sessionID] = append(sh.sessionModels[sessionID], model.ID)
+		name := s.modelKey(model.ModelName)
+		sh.modelsByName[name] = append(sh.modelsByName[name], modelRef{sessionID: sessionID, modelID: model.ID})
+
+		modelCopy := *model
+		s.publish(Event{Kind: EventModelAdded, SessionID: sessionID, Model: &modelCopy, At: model.CreatedAt})
+
+		s.logger.WithFields(logrus.Fields{
+			"session_id": sessionID,
+			"model_id":   model.ID,
+			"model_name": model.ModelName,
+		}).Debug("Added mental model to storage")
+	}
+
+	// Update session
+	s.countEntities(session, 0, len(models))
+	s.touchSession(session)
 
 	return nil
 }
 
 // GetMentalModels retrieves all mental models for a session
 func (s *Storage) GetMentalModels(sessionID string) ([]*types.MentalModelData, error) {
-	s.mentalModelsMutex.RLock()
-	defer s.mentalModelsMutex.RUnlock()
+	sh := s.shardFor(sessionID)
+	sh.mentalModelsMutex.RLock()
+	defer sh.mentalModelsMutex.RUnlock()
 
 	var sessionModels []*types.MentalModelData
-	for _, model := range s.mentalModels {
-		sessionModels = append(sessionModels, model)
+	for _, id := range sh.sessionModels[sessionID] {
+		if model, exists := sh.mentalModels[id]; exists && model.SessionID == sessionID {
+			sessionModels = append(sessionModels, model)
+		}
 	}
 
 	return sessionModels, nil
 }
 
+// GetMentalModel retrieves one mental model application of a session by ID
+func (s *Storage) GetMentalModel(sessionID, modelID string) (*types.MentalModelData, error) {
+	sh := s.shardFor(sessionID)
+	sh.mentalModelsMutex.RLock()
+	defer sh.mentalModelsMutex.RUnlock()
+
+	for _, id := range sh.sessionModels[sessionID] {
+		if id == modelID {
+			if model, exists := sh.mentalModels[id]; exists && model.SessionID == sessionID {
+				return model, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("mental model %s not found in session %s", modelID, sessionID)
+}
+
+// SessionModelSummary groups a session's mental model applications by model
+// name, in order of each model's first application
+func (s *Storage) SessionModelSummary(sessionID string) ([]types.ModelUsage, error) {
+	mentalModels, err := s.GetMentalModels(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := []types.ModelUsage{}
+	positions := make(map[string]int)
+	for _, model := range mentalModels {
+		position, exists := positions[model.ModelName]
+		if !exists {
+			position = len(summary)
+			positions[model.ModelName] = position
+			summary = append(summary, types.ModelUsage{ModelName: model.ModelName, Problems: []string{}})
+		}
+		summary[position].Count++
+		summary[position].Problems = append(summary[position].Problems, model.Problem)
+	}
+
+	return summary, nil
+}
+
+// ModelApplications returns every application of a mental model across all
+// sessions, oldest first. Model names match case-insensitively when
+// CaseInsensitiveNames is set.
+func (s *Storage) ModelApplications(modelName string) []types.ModelApplication {
+	name := s.modelKey(modelName)
+
+	applications := []types.ModelApplication{}
+	for _, sh := range s.shards {
+		sh.mentalModelsMutex.RLock()
+		for _, ref := range sh.modelsByName[name] {
+			model, exists := sh.mentalModels[ref.modelID]
+			if !exists || model.SessionID != ref.sessionID {
+				continue
+			}
+			applications = append(applications, types.ModelApplication{
+				SessionID:  ref.sessionID,
+				ModelID:    model.ID,
+				ModelName:  model.ModelName,
+				Problem:    model.Problem,
+				Conclusion: model.Conclusion,
+				Confidence: model.Confidence,
+				CreatedAt:  model.CreatedAt,
+			})
+		}
+		sh.mentalModelsMutex.RUnlock()
+	}
+
+	sort.SliceStable(applications, func(i, j int) bool {
+		return applications[i].CreatedAt.Before(applications[j].CreatedAt)
+	})
+	return applications
+}
+
+// modelKey is the model name index key for a model name
+func (s *Storage) modelKey(modelName string) string {
+	if s.config.CaseInsensitiveNames {
+		return strings.ToLower(modelName)
+	}
+	return modelName
+}
+
+// SessionDecisions returns the conclusions reached in a session in the order
+// they were recorded, skipping inconclusive applications. Debugging approaches
+// are reported by approach name with their resolution as the conclusion.
+func (s *Storage) SessionDecisions(sessionID string) ([]types.Decision, error) {
+	mentalModels, err := s.GetMentalModels(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := []types.Decision{}
+	for _, model := range mentalModels {
+		conclusion := strings.TrimSpace(model.Conclusion)
+		if conclusion == "" {
+			continue
+		}
+		kind, name := types.DecisionKindMentalModel, model.ModelName
+		if approach, ok := strings.CutPrefix(name, types.DebuggingApproachPrefix); ok {
+			kind, name = types.DecisionKindDebugging, approach
+		}
+		decisions = append(decisions, types.Decision{
+			Kind:       kind,
+			Name:       name,
+			Problem:    model.Problem,
+			Conclusion: conclusion,
+			Confidence: model.Confidence,
+			CreatedAt:  model.CreatedAt,
+		})
+	}
+
+	return decisions, nil
+}
+
+// SessionProblems returns the distinct problems explored in a session in the
+// order they first appeared, with the mental models applied to each. Problems
+// are compared after trimming surrounding whitespace. Debugging approaches are
+// stored as mental models, so their issues are included.
+func (s *Storage) SessionProblems(sessionID string) ([]types.SessionProblem, error) {
+	mentalModels, err := s.GetMentalModels(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []types.SessionProblem{}
+	index := make(map[string]int)
+	for _, model := range mentalModels {
+		problem := strings.TrimSpace(model.Problem)
+		if problem == "" {
+			continue
+		}
+		i, seen := index[problem]
+		if !seen {
+			i = len(problems)
+			index[problem] = i
+			problems = append(problems, types.SessionProblem{Problem: problem, Models: []string{}})
+		}
+		problems[i].ModelCount++
+		problems[i].Models = append(problems[i].Models, model.ModelName)
+	}
+
+	return problems, nil
+}
+
 // ============================================================================
 // Session Management
 // ============================================================================
 
 // GetSession retrieves session data
 func (s *Storage) GetSession(sessionID string) (*SessionData, error) {
-	s.sessionsMutex.RLock()
-	defer s.sessionsMutex.RUnlock()
+	sh := s.shardFor(sessionID)
+	sh.sessionsMutex.RLock()
+	defer sh.sessionsMutex.RUnlock()
 
-	session, exists := s.sessions[sessionID]
+	session, exists := sh.sessions[sessionID]
 	if !exists {
-		return nil, fmt.Errorf("session %s not found", sessionID)
+		return nil, fmt.Errorf("session %s %w", sessionID, ErrSessionNotFound)
 	}
 
 	return session, nil
@@ -163,69 +660,234 @@ func (s *Storage) GetSession(sessionID string) (*SessionData, error) {
 
 // CreateSession creates a new session
 func (s *Storage) CreateSession(sessionID string) (*SessionData, error) {
-	s.sessionsMutex.Lock()
-	defer s.sessionsMutex.Unlock()
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	sh := s.shardFor(sessionID)
+	sh.sessionsMutex.Lock()
+	defer sh.sessionsMutex.Unlock()
 
 	session := &SessionData{
 		ID:                sessionID,
-		CreatedAt:         time.Now(),
-		LastAccessedAt:    time.Now(),
+		CreatedAt:         s.now(),
+		LastAccessedAt:    s.now(),
 		ThoughtCount:      0,
 		ToolsUsed:         []string{},
 		TotalOperations:   0,
 		IsActive:          true,
+		State:             types.SessionStateActive,
 		RemainingThoughts: s.config.MaxThoughtsPerSession,
 	}
 
-	s.sessions[sessionID] = session
+	sh.sessions[sessionID] = session
+	s.recentSessions.touch(sessionID)
+	s.persistSession(session)
+	s.publish(Event{Kind: EventSessionCreated, SessionID: sessionID, At: session.CreatedAt})
 
 	s.logger.WithField("session_id", sessionID).Debug("Created new session")
 
 	return session, nil
 }
 
+// ArchiveSession marks a session read-only; later writes to it are rejected
+func (s *Storage) ArchiveSession(sessionID string) error {
+	return s.setArchived(sessionID, true)
+}
+
+// UnarchiveSession makes an archived session writable again
+func (s *Storage) UnarchiveSession(sessionID string) error {
+	return s.setArchived(sessionID, false)
+}
+
+// setArchived updates the archived flag of an existing session
+func (s *Storage) setArchived(sessionID string, archived bool) error {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	session.Archived = archived
+	s.persistSession(session)
+	if archived {
+		s.publish(Event{Kind: EventSessionArchived, SessionID: sessionID, At: s.now()})
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"archived":   archived,
+	}).Debug("Updated session archive state")
+
+	return nil
+}
+
+// SetSessionMetadata stores a client-defined key/value on a session,
+// overwriting any previous value for the key
+func (s *Storage) SetSessionMetadata(sessionID, key, value string) error {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session := s.getSession(sessionID)
+	if session.Archived {
+		return fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	if key == "" {
+		return fmt.Errorf("metadata key must not be empty")
+	}
+	if limit := s.config.MaxMetadataKeyLength; limit > 0 && len(key) > limit {
+		return fmt.Errorf("metadata key exceeds maximum length of %d characters", limit)
+	}
+	if limit := s.config.MaxMetadataValueLength; limit > 0 && len(value) > limit {
+		return fmt.Errorf("metadata value for key '%s' exceeds maximum length of %d characters", key, limit)
+	}
+
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]string)
+	}
+	if _, exists := session.Metadata[key]; !exists {
+		if limit := s.config.MaxMetadataEntries; limit > 0 && len(session.Metadata) >= limit {
+			if s.config.MetadataOverflow != OverflowRing {
+				return fmt.Errorf("metadata limit reached for session %s: limit %d", sessionID, limit)
+			}
+			oldest := session.metadataOrder[0]
+			session.metadataOrder = session.metadataOrder[1:]
+			delete(session.Metadata, oldest)
+		}
+		session.metadataOrder = append(session.metadataOrder, key)
+	}
+	session.Metadata[key] = value
+	s.touchSession(session)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"key":        key,
+	}).Debug("Set session metadata")
+
+	return nil
+}
+
+// GetSessionMetadata returns a copy of a session's metadata
+func (s *Storage) GetSessionMetadata(sessionID string) (map[string]string, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]string, len(session.Metadata))
+	for key, value := range session.Metadata {
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
 // getSession gets or creates a session
 func (s *Storage) getSession(sessionID string) *SessionData {
-	s.sessionsMutex.Lock()
-	defer s.sessionsMutex.Unlock()
+	sh := s.shardFor(sessionID)
+	sh.sessionsMutex.Lock()
+	defer sh.sessionsMutex.Unlock()
 
-	session, exists := s.sessions[sessionID]
+	session, exists := sh.sessions[sessionID]
 	if !exists {
 		session = &SessionData{
 			ID:                sessionID,
-			CreatedAt:         time.Now(),
-			LastAccessedAt:    time.Now(),
+			CreatedAt:         s.now(),
+			LastAccessedAt:    s.now(),
 			ThoughtCount:      0,
 			ToolsUsed:         []string{},
 			TotalOperations:   0,
 			IsActive:          true,
+			State:             types.SessionStateActive,
 			RemainingThoughts: s.config.MaxThoughtsPerSession,
 		}
-		s.sessions[sessionID] = session
+		sh.sessions[sessionID] = session
+		s.recentSessions.touch(sessionID)
+		s.persistSession(session)
+		s.publish(Event{Kind: EventSessionCreated, SessionID: sessionID, At: session.CreatedAt})
 	}
 
 	return session
 }
 
+// setCounters records a session's thought and mental model counts, keeping
+// the remaining thoughts and total operations in step; callers hold the
+// session lock
+func (s *Storage) setCounters(session *SessionData, thoughts, models int) {
+	session.ThoughtCount = thoughts
+	session.RemainingThoughts = s.config.MaxThoughtsPerSession - thoughts
+	session.TotalOperations = thoughts + models
+}
+
+// countEntities adjusts a session's counters for added (or, when negative,
+// removed) thoughts and mental models; callers hold the session lock
+func (s *Storage) countEntities(session *SessionData, thoughts, models int) {
+	s.setCounters(session, session.ThoughtCount+thoughts, session.TotalOperations-session.ThoughtCount+models)
+}
+
+// touchSession records a write to a session, reactivating it if it was idle;
+// callers hold the session lock
+func (s *Storage) touchSession(session *SessionData) {
+	session.LastAccessedAt = s.now()
+	if session.State == types.SessionStateIdle {
+		s.logger.WithField("session_id", session.ID).Debug("Reactivated idle session")
+	}
+	session.IsActive = true
+	session.State = types.SessionStateActive
+	s.recentSessions.touch(session.ID)
+	s.persistSession(session)
+}
+
+// RecentSessions returns statistics for up to n sessions, most recently accessed first
+func (s *Storage) RecentSessions(n int) []*types.SessionStatistics {
+	ids := s.recentSessions.newest(n)
+
+	recent := make([]*types.SessionStatistics, 0, len(ids))
+	for _, sessionID := range ids {
+		stats, err := s.GetSessionStats(sessionID)
+		if err != nil {
+			continue
+		}
+		recent = append(recent, stats)
+	}
+
+	return recent
+}
+
 // GetSessionStats retrieves comprehensive session statistics
 func (s *Storage) GetSessionStats(sessionID string) (*types.SessionStatistics, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
 	session := s.getSession(sessionID)
 
 	thoughts, _ := s.GetThoughts(sessionID)
 	mentalModels, _ := s.GetMentalModels(sessionID)
 
-	// Collect tools used
-	toolsUsed := make(map[string]bool)
-	if len(thoughts) > 0 {
-		toolsUsed["sequential-thinking"] = true
-	}
-	if len(mentalModels) > 0 {
-		toolsUsed["mental-model"] = true
+	// Prefer the recorded tool calls; sessions written to directly through
+	// storage fall back to the tools implied by their contents
+	toolsList := append([]string(nil), session.ToolsUsed...)
+	if len(toolsList) == 0 {
+		if len(thoughts) > 0 {
+			toolsList = append(toolsList, "sequential-thinking")
+		}
+		if len(mentalModels) > 0 {
+			toolsList = append(toolsList, "mental-model")
+		}
 	}
 
-	var toolsList []string
-	for tool := range toolsUsed {
-		toolsList = append(toolsList, tool)
+	revisionCount := 0
+	for _, thought := range thoughts {
+		if thought.IsRevision {
+			revisionCount++
+		}
+	}
+	revisionRatio := 0.0
+	if len(thoughts) > 0 {
+		revisionRatio = float64(revisionCount) / float64(len(thoughts))
 	}
 
 	stats := &types.SessionStatistics{
@@ -236,16 +898,216 @@ func (s *Storage) GetSessionStats(sessionID string) (*types.SessionStatistics, e
 		ToolsUsed:         toolsList,
 		TotalOperations:   len(thoughts) + len(mentalModels),
 		IsActive:          session.IsActive,
+		State:             session.State,
+		Archived:          session.Archived,
 		RemainingThoughts: s.config.MaxThoughtsPerSession - len(thoughts),
+		RevisionCount:     revisionCount,
+		RevisionRatio:     revisionRatio,
 		Stores: map[string]interface{}{
 			"thoughts":      map[string]int{"count": len(thoughts)},
 			"mental_models": map[string]int{"count": len(mentalModels)},
 		},
 	}
+	if session.Verdict != nil {
+		verdict := *session.Verdict
+		stats.Verdict = &verdict
+	}
 
 	return stats, nil
 }
 
+// ============================================================================
+// Metrics
+// ============================================================================
+
+// recordThroughput counts a thought towards the global and session ingestion rates
+func (s *Storage) recordThroughput(sessionID string, at time.Time) {
+	s.throughputMutex.Lock()
+	defer s.throughputMutex.Unlock()
+
+	s.thoughtThroughput.add(at)
+
+	counter, exists := s.sessionThroughput[sessionID]
+	if !exists {
+		counter = &throughputCounter{}
+		s.sessionThroughput[sessionID] = counter
+	}
+	counter.add(at)
+}
+
+// ThoughtsPerMinute returns the global thought ingestion rate over the sliding window
+func (s *Storage) ThoughtsPerMinute() float64 {
+	s.throughputMutex.Lock()
+	defer s.throughputMutex.Unlock()
+
+	return s.thoughtThroughput.perMinute(s.now())
+}
+
+// SessionThoughtsPerMinute returns the thought ingestion rate of one session
+func (s *Storage) SessionThoughtsPerMinute(sessionID string) float64 {
+	s.throughputMutex.Lock()
+	defer s.throughputMutex.Unlock()
+
+	counter, exists := s.sessionThroughput[sessionID]
+	if !exists {
+		return 0
+	}
+	return counter.perMinute(s.now())
+}
+
+// GetGlobalStats retrieves server-wide statistics across all sessions
+func (s *Storage) GetGlobalStats() *types.GlobalStatistics {
+	stats := &types.GlobalStatistics{
+		SessionThoughtsPerMinute: make(map[string]float64),
+	}
+
+	for _, sh := range s.shards {
+		sh.sessionsMutex.RLock()
+		sessions := make([]*SessionData, 0, len(sh.sessions))
+		for _, session := range sh.sessions {
+			sessions = append(sessions, session)
+		}
+		sh.sessionsMutex.RUnlock()
+
+		// Session state is guarded by the session lock, which is always
+		// taken before the shard mutexes
+		stats.TotalSessions += len(sessions)
+		for _, session := range sessions {
+			unlock := sh.sessionLocks.lock(session.ID)
+			if session.IsActive {
+				stats.ActiveSessions++
+			}
+			unlock()
+		}
+
+		sh.thoughtsMutex.RLock()
+		stats.TotalThoughts += len(sh.thoughts)
+		sh.thoughtsMutex.RUnlock()
+
+		sh.mentalModelsMutex.RLock()
+		stats.TotalMentalModels += len(sh.mentalModels)
+		sh.mentalModelsMutex.RUnlock()
+	}
+
+	s.throughputMutex.Lock()
+	now := s.now()
+	stats.ThoughtsPerMinute = s.thoughtThroughput.perMinute(now)
+	for sessionID, counter := range s.sessionThroughput {
+		if rate := counter.perMinute(now); rate > 0 {
+			stats.SessionThoughtsPerMinute[sessionID] = rate
+		}
+	}
+	s.throughputMutex.Unlock()
+
+	stats.LockContention = s.LockContention()
+
+	return stats
+}
+
+// LockContention returns how many times a session lock acquisition has had
+// to wait for another holder since storage was created
+func (s *Storage) LockContention() uint64 {
+	var total uint64
+	for _, sh := range s.shards {
+		total += atomic.LoadUint64(&sh.sessionLocks.contended)
+	}
+	return total
+}
+
+// ============================================================================
+// Session Comparison
+// ============================================================================
+
+// DiffSessions compares the reasoning recorded in two sessions
+func (s *Storage) DiffSessions(sessionA, sessionB string) (*types.SessionDiff, error) {
+	for _, sessionID := range []string{sessionA, sessionB} {
+		if _, err := s.GetSession(sessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	thoughtsA, _ := s.GetThoughts(sessionA)
+	thoughtsB, _ := s.GetThoughts(sessionB)
+	modelsA, _ := s.GetMentalModels(sessionA)
+	modelsB, _ := s.GetMentalModels(sessionB)
+
+	diff := &types.SessionDiff{
+		SessionA:        sessionA,
+		SessionB:        sessionB,
+		ThoughtsOnlyInA: thoughtsMissingFrom(thoughtsA, thoughtsB),
+		ThoughtsOnlyInB: thoughtsMissingFrom(thoughtsB, thoughtsA),
+		ModelsOnlyInA:   modelsMissingFrom(modelsA, modelsB),
+		ModelsOnlyInB:   modelsMissingFrom(modelsB, modelsA),
+	}
+
+	// Walk both sequences in thought order to find the first difference
+	orderedA := sortedByThoughtNumber(thoughtsA)
+	orderedB := sortedByThoughtNumber(thoughtsB)
+	for i := 0; i < len(orderedA) || i < len(orderedB); i++ {
+		if i >= len(orderedA) || i >= len(orderedB) || normalizeText(orderedA[i].Thought) != normalizeText(orderedB[i].Thought) {
+			var point int
+			if i < len(orderedA) {
+				point = orderedA[i].ThoughtNumber
+			} else {
+				point = orderedB[i].ThoughtNumber
+			}
+			diff.DivergencePoint = &point
+			break
+		}
+	}
+
+	diff.Identical = diff.DivergencePoint == nil && len(diff.ModelsOnlyInA) == 0 && len(diff.ModelsOnlyInB) == 0
+
+	return diff, nil
+}
+
+// thoughtsMissingFrom returns the thoughts whose normalized text is absent from other
+func thoughtsMissingFrom(thoughts, other []*types.ThoughtData) []*types.ThoughtData {
+	seen := make(map[string]bool, len(other))
+	for _, thought := range other {
+		seen[normalizeText(thought.Thought)] = true
+	}
+
+	missing := []*types.ThoughtData{}
+	for _, thought := range thoughts {
+		if !seen[normalizeText(thought.Thought)] {
+			missing = append(missing, thought)
+		}
+	}
+	return missing
+}
+
+// modelsMissingFrom returns the model applications whose name and problem are absent from other
+func modelsMissingFrom(models, other []*types.MentalModelData) []*types.MentalModelData {
+	seen := make(map[string]bool, len(other))
+	for _, model := range other {
+		seen[model.ModelName+"\x00"+normalizeText(model.Problem)] = true
+	}
+
+	missing := []*types.MentalModelData{}
+	for _, model := range models {
+		if !seen[model.ModelName+"\x00"+normalizeText(model.Problem)] {
+			missing = append(missing, model)
+		}
+	}
+	return missing
+}
+
+// sortedByThoughtNumber returns a copy of thoughts ordered by thought number
+func sortedByThoughtNumber(thoughts []*types.ThoughtData) []*types.ThoughtData {
+	sorted := make([]*types.ThoughtData, len(thoughts))
+	copy(sorted, thoughts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ThoughtNumber < sorted[j].ThoughtNumber
+	})
+	return sorted
+}
+
+// normalizeText lowercases text and collapses whitespace for comparison
+func normalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
 // ============================================================================
 // Export/Import
 // ============================================================================
@@ -253,31 +1115,67 @@ func (s *Storage) GetSessionStats(sessionID string) (*types.SessionStatistics, e
 // ExportSession exports session data
 func (s *Storage) ExportSession(sessionID string) (*types.SessionExport, error) {
 	thoughts, _ := s.GetThoughts(sessionID)
+	return s.buildExport(sessionID, thoughts), nil
+}
+
+// ExportSessionBranch exports a session with its thoughts narrowed to the
+// lineage of one branch: the trunk up to the branch point followed by the
+// branch. Mental models are not tied to branches, so all of them are kept.
+func (s *Storage) ExportSessionBranch(sessionID, branchID string) (*types.SessionExport, error) {
+	thoughts, err := s.BranchLineage(sessionID, branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := s.buildExport(sessionID, thoughts)
+	export.Metadata["branch_id"] = branchID
+	return export, nil
+}
+
+// buildExport assembles an export of the given thoughts with the session's
+// mental models, metadata and verdict
+func (s *Storage) buildExport(sessionID string, thoughts []*types.ThoughtData) *types.SessionExport {
 	mentalModels, _ := s.GetMentalModels(sessionID)
+	metadata, err := s.GetSessionMetadata(sessionID)
+	if err != nil {
+		metadata = map[string]string{}
+	}
+	data := map[string]interface{}{
+		"thoughts":      thoughts,
+		"mental_models": mentalModels,
+		"metadata":      metadata,
+	}
+	if verdict, _ := s.GetSessionVerdict(sessionID); verdict != nil {
+		data["verdict"] = verdict
+	}
 
 	export := &types.SessionExport{
-		Version:     "1.0.0",
-		Timestamp:   time.Now(),
+		Version:     CurrentExportVersion,
+		Timestamp:   s.now(),
 		SessionID:   sessionID,
 		SessionType: "hybrid",
-		Data: map[string]interface{}{
-			"thoughts":      thoughts,
-			"mental_models": mentalModels,
-		},
+		Data:        data,
 		Metadata: map[string]interface{}{
-			"exported_at": time.Now(),
+			"exported_at": s.now(),
 			"version":     "0.1.0",
 		},
 	}
 
-	return export, nil
+	return export
 }
 
 // ============================================================================
 // Utility Functions
 // ============================================================================
 
-// generateID generates a unique ID
-func generateID() string {
-	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), time.Now().Nanosecond())
+// generateID generates a unique ID using the configured strategy
+func (s *Storage) generateID() string {
+	return s.idGenerator(s.now())
+}
+
+// NewID returns a unique ID from the configured strategy, for callers that
+// must identify something before or without storing it. Thoughts and mental
+// models stored without an ID receive one automatically.
+func (s *Storage) NewID() string {
+	return s.generateID()
 }
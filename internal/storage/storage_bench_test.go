@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// benchSessionSize bounds how many operations go into one session before a
+// worker moves on to a fresh one, keeping per-operation cost constant
+const benchSessionSize = 64
+
+// benchShardCounts compares the unsharded layout with the default sharding
+var benchShardCounts = []int{1, config.DefaultConfig().StorageShards}
+
+// newBenchStorage creates a storage with the given shard count and no
+// per-session limits or reaper
+func newBenchStorage(b *testing.B, shards int) *Storage {
+	b.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.StorageShards = shards
+	cfg.MaxThoughtsPerSession = math.MaxInt
+	cfg.MaxMentalModelsPerSession = 0
+	cfg.ReaperInterval = 0
+	store, err := New(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	store.logger.SetOutput(io.Discard)
+	b.Cleanup(store.Close)
+
+	return store
+}
+
+// runParallelSessions runs op from parallel workers, each cycling through its
+// own sessions
+func runParallelSessions(b *testing.B, op func(store *Storage, sessionID string, i int) error) {
+	for _, shards := range benchShardCounts {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store := newBenchStorage(b, shards)
+			var workers int64
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				worker := atomic.AddInt64(&workers, 1)
+				for i := 0; pb.Next(); i++ {
+					sessionID := fmt.Sprintf("bench-%d-%d", worker, i/benchSessionSize)
+					if err := op(store, sessionID, i%benchSessionSize); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkAddThought_ConcurrentSessions(b *testing.B) {
+	runParallelSessions(b, func(store *Storage, sessionID string, i int) error {
+		return store.AddThought(sessionID, &types.ThoughtData{Thought: "thought", ThoughtNumber: i + 1})
+	})
+}
+
+func BenchmarkMixedWorkload_ConcurrentSessions(b *testing.B) {
+	runParallelSessions(b, func(store *Storage, sessionID string, i int) error {
+		switch i % 4 {
+		case 0:
+			_, err := store.GetSessionStats(sessionID)
+			return err
+		case 1:
+			return store.AddMentalModel(sessionID, &types.MentalModelData{ModelName: "first_principles", Problem: "bench"})
+		default:
+			return store.AddThought(sessionID, &types.ThoughtData{Thought: "thought", ThoughtNumber: i + 1})
+		}
+	})
+}
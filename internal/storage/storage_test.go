@@ -0,0 +1,2720 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.MaxThoughtsPerSession = 1000
+	store, err := New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+
+	return store
+}
+
+func TestSessionLocks_SerializeSameSession(t *testing.T) {
+	store := newTestStorage(t)
+	sessionID := "shared-session"
+
+	const workers = 20
+	var wg sync.WaitGroup
+
+	// Mutate one session concurrently through different operations
+	for i := 0; i < workers; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			err := store.AddThought(sessionID, &types.ThoughtData{
+				Thought:       fmt.Sprintf("thought %d", i),
+				ThoughtNumber: i + 1,
+				TotalThoughts: workers,
+			})
+			assert.NoError(t, err)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			err := store.AddMentalModel(sessionID, &types.MentalModelData{
+				ModelName: "first_principles",
+				Problem:   fmt.Sprintf("problem %d", i),
+			})
+			assert.NoError(t, err)
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := store.GetSessionStats(sessionID)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	session, err := store.GetSession(sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, workers, session.ThoughtCount)
+}
+
+func TestSessionLocks_IndependentSessions(t *testing.T) {
+	locks := newSessionLocks()
+
+	unlockA := locks.lock("session-a")
+	defer unlockA()
+
+	// A different session must not be blocked by session-a's lock
+	done := make(chan struct{})
+	go func() {
+		unlockB := locks.lock("session-b")
+		unlockB()
+		close(done)
+	}()
+	<-done
+}
+
+func TestSessionLocks_TryLockFailsWhileHeld(t *testing.T) {
+	locks := newSessionLocks()
+
+	unlock := locks.lock("session-a")
+	_, ok := locks.tryLock("session-a")
+	assert.False(t, ok)
+	unlock()
+
+	unlock, ok = locks.tryLock("session-a")
+	require.True(t, ok)
+	unlock()
+	assert.Empty(t, locks.locks)
+}
+
+func TestAddMentalModels_PerSessionCap(t *testing.T) {
+	batch := func(n int) []*types.MentalModelData {
+		models := make([]*types.MentalModelData, n)
+		for i := range models {
+			models[i] = &types.MentalModelData{ModelName: "first_principles", Problem: fmt.Sprintf("problem %d", i)}
+		}
+		return models
+	}
+
+	// Unlimited by default
+	store := newTestStorage(t)
+	require.NoError(t, store.AddMentalModels("uncapped", batch(150)))
+	models, err := store.GetMentalModels("uncapped")
+	require.NoError(t, err)
+	assert.Len(t, models, 150)
+
+	// A batch crossing the cap stores none of its models
+	store.config.MaxMentalModelsPerSession = 3
+	require.NoError(t, store.AddMentalModels("capped", batch(2)))
+	assert.Error(t, store.AddMentalModels("capped", batch(2)))
+	models, err = store.GetMentalModels("capped")
+	require.NoError(t, err)
+	assert.Len(t, models, 2)
+}
+
+func TestShards_ConcurrentSessions(t *testing.T) {
+	store := newTestStorage(t)
+	require.Len(t, store.shards, store.config.StorageShards)
+
+	const sessions = 64
+	const thoughtsPerSession = 10
+	var wg sync.WaitGroup
+
+	// Write to many sessions while cross-shard readers walk every shard
+	for i := 0; i < sessions; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for n := 1; n <= thoughtsPerSession; n++ {
+				assert.NoError(t, store.AddThought(sessionID, &types.ThoughtData{
+					Thought:           fmt.Sprintf("thought %d", n),
+					ThoughtNumber:     n,
+					TotalThoughts:     thoughtsPerSession,
+					NextThoughtNeeded: n < thoughtsPerSession,
+				}))
+			}
+			assert.NoError(t, store.AddMentalModel(sessionID, &types.MentalModelData{ModelName: "first_principles"}))
+		}()
+		go func() {
+			defer wg.Done()
+			store.GetGlobalStats()
+			store.GetPendingThoughts("")
+			store.ReapSessions()
+		}()
+	}
+	wg.Wait()
+
+	stats := store.GetGlobalStats()
+	assert.Equal(t, sessions, stats.TotalSessions)
+	assert.Equal(t, sessions*thoughtsPerSession, stats.TotalThoughts)
+	assert.Equal(t, sessions, stats.TotalMentalModels)
+	assert.Len(t, store.GetPendingThoughts(""), sessions)
+
+	// Every session lives only in the shard its ID hashes to
+	used := make(map[*shard]bool)
+	for i := 0; i < sessions; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		owner := store.shardFor(sessionID)
+		used[owner] = true
+		for _, sh := range store.shards {
+			_, exists := sh.sessions[sessionID]
+			assert.Equal(t, sh == owner, exists)
+		}
+
+		thoughts, err := store.GetThoughts(sessionID)
+		require.NoError(t, err)
+		assert.Len(t, thoughts, thoughtsPerSession)
+	}
+	assert.Greater(t, len(used), 1)
+}
+
+func TestShards_SingleShard(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StorageShards = 0
+	store, err := New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+
+	require.Len(t, store.shards, 1)
+	addThoughts(t, store, "a", "one", "two")
+	addThoughts(t, store, "b", "one")
+	assert.Equal(t, 3, store.GetGlobalStats().TotalThoughts)
+}
+
+// addThoughts appends a numbered sequence of thoughts to a session
+func addThoughts(t *testing.T, store *Storage, sessionID string, thoughts ...string) {
+	t.Helper()
+
+	for i, thought := range thoughts {
+		require.NoError(t, store.AddThought(sessionID, &types.ThoughtData{
+			Thought:       thought,
+			ThoughtNumber: i + 1,
+			TotalThoughts: len(thoughts),
+		}))
+	}
+}
+
+func TestDiffSessions_DivergencePoint(t *testing.T) {
+	store := newTestStorage(t)
+
+	addThoughts(t, store, "run-a", "Define the problem", "Gather  data", "Pick option A")
+	addThoughts(t, store, "run-b", "define the problem", "Gather data", "Pick option B")
+	require.NoError(t, store.AddMentalModel("run-a", &types.MentalModelData{ModelName: "first_principles", Problem: "Choose"}))
+	require.NoError(t, store.AddMentalModel("run-b", &types.MentalModelData{ModelName: "first_principles", Problem: "choose"}))
+	require.NoError(t, store.AddMentalModel("run-b", &types.MentalModelData{ModelName: "opportunity_cost", Problem: "Choose"}))
+
+	diff, err := store.DiffSessions("run-a", "run-b")
+	require.NoError(t, err)
+
+	// Case and whitespace differences are ignored; thought 3 is the first real difference
+	require.NotNil(t, diff.DivergencePoint)
+	assert.Equal(t, 3, *diff.DivergencePoint)
+	assert.False(t, diff.Identical)
+
+	require.Len(t, diff.ThoughtsOnlyInA, 1)
+	assert.Equal(t, "Pick option A", diff.ThoughtsOnlyInA[0].Thought)
+	require.Len(t, diff.ThoughtsOnlyInB, 1)
+	assert.Equal(t, "Pick option B", diff.ThoughtsOnlyInB[0].Thought)
+
+	assert.Empty(t, diff.ModelsOnlyInA)
+	require.Len(t, diff.ModelsOnlyInB, 1)
+	assert.Equal(t, "opportunity_cost", diff.ModelsOnlyInB[0].ModelName)
+}
+
+func TestDiffSessions_PrefixAndIdentical(t *testing.T) {
+	store := newTestStorage(t)
+
+	addThoughts(t, store, "short", "One", "Two")
+	addThoughts(t, store, "long", "One", "Two", "Three")
+	addThoughts(t, store, "copy", "One", "Two")
+
+	diff, err := store.DiffSessions("short", "long")
+	require.NoError(t, err)
+	require.NotNil(t, diff.DivergencePoint)
+	assert.Equal(t, 3, *diff.DivergencePoint)
+
+	diff, err = store.DiffSessions("short", "copy")
+	require.NoError(t, err)
+	assert.Nil(t, diff.DivergencePoint)
+	assert.True(t, diff.Identical)
+
+	_, err = store.DiffSessions("short", "missing")
+	assert.Error(t, err)
+}
+
+// fakeClock is a manually advanced clock for time-dependent tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestThoughtsPerMinute_SlidingWindow(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	// 10 thoughts in the first minute, 5 in the next
+	addThoughts(t, store, "session-a", make([]string, 10)...)
+	clock.Advance(time.Minute)
+	addThoughts(t, store, "session-b", make([]string, 5)...)
+
+	assert.InDelta(t, 15.0/throughputWindowMinutes, store.ThoughtsPerMinute(), 0.001)
+	assert.InDelta(t, 10.0/throughputWindowMinutes, store.SessionThoughtsPerMinute("session-a"), 0.001)
+	assert.InDelta(t, 5.0/throughputWindowMinutes, store.SessionThoughtsPerMinute("session-b"), 0.001)
+
+	// Once the first minute slides out of the window only session-b's thoughts count
+	clock.Advance((throughputWindowMinutes - 1) * time.Minute)
+	assert.InDelta(t, 5.0/throughputWindowMinutes, store.ThoughtsPerMinute(), 0.001)
+	assert.Zero(t, store.SessionThoughtsPerMinute("session-a"))
+
+	stats := store.GetGlobalStats()
+	assert.Equal(t, 2, stats.TotalSessions)
+	assert.Equal(t, 15, stats.TotalThoughts)
+	assert.InDelta(t, 5.0/throughputWindowMinutes, stats.ThoughtsPerMinute, 0.001)
+	assert.NotContains(t, stats.SessionThoughtsPerMinute, "session-a")
+	assert.Contains(t, stats.SessionThoughtsPerMinute, "session-b")
+
+	// Everything expires after a full window of inactivity
+	clock.Advance(throughputWindowMinutes * time.Minute)
+	assert.Zero(t, store.ThoughtsPerMinute())
+}
+
+func TestArchiveSession_BlocksWrites(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "archived-session", "Only thought")
+
+	require.NoError(t, store.ArchiveSession("archived-session"))
+
+	// Writes are rejected with the archived error
+	err := store.AddThought("archived-session", &types.ThoughtData{Thought: "late", ThoughtNumber: 2})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrSessionArchived)
+
+	err = store.AddMentalModel("archived-session", &types.MentalModelData{ModelName: "first_principles"})
+	assert.ErrorIs(t, err, ErrSessionArchived)
+
+	// Reads and export still work
+	thoughts, err := store.GetThoughts("archived-session")
+	require.NoError(t, err)
+	assert.Len(t, thoughts, 1)
+
+	stats, err := store.GetSessionStats("archived-session")
+	require.NoError(t, err)
+	assert.True(t, stats.Archived)
+
+	export, err := store.ExportSession("archived-session")
+	require.NoError(t, err)
+	assert.Equal(t, "archived-session", export.SessionID)
+
+	// Unarchiving restores writes
+	require.NoError(t, store.UnarchiveSession("archived-session"))
+	assert.NoError(t, store.AddThought("archived-session", &types.ThoughtData{Thought: "resumed", ThoughtNumber: 2}))
+
+	assert.Error(t, store.ArchiveSession("missing-session"))
+}
+
+func TestGetPendingThoughts(t *testing.T) {
+	store := newTestStorage(t)
+
+	seed := []struct {
+		sessionID string
+		thought   *types.ThoughtData
+	}{
+		{"session-a", &types.ThoughtData{Thought: "next needed", ThoughtNumber: 1, NextThoughtNeeded: true}},
+		{"session-a", &types.ThoughtData{Thought: "done", ThoughtNumber: 2}},
+		{"session-a", &types.ThoughtData{Thought: "needs more", ThoughtNumber: 3, NeedsMoreThoughts: true}},
+		{"session-b", &types.ThoughtData{Thought: "finished", ThoughtNumber: 1}},
+		{"session-c", &types.ThoughtData{Thought: "both flags", ThoughtNumber: 1, NextThoughtNeeded: true, NeedsMoreThoughts: true}},
+	}
+	for _, entry := range seed {
+		require.NoError(t, store.AddThought(entry.sessionID, entry.thought))
+	}
+
+	pending := store.GetPendingThoughts("")
+	require.Len(t, pending, 2)
+	require.Len(t, pending["session-a"], 2)
+	assert.Equal(t, "next needed", pending["session-a"][0].Thought)
+	assert.Equal(t, "needs more", pending["session-a"][1].Thought)
+	assert.NotContains(t, pending, "session-b")
+	require.Len(t, pending["session-c"], 1)
+
+	// Restricting to one session only returns that session
+	pending = store.GetPendingThoughts("session-c")
+	require.Len(t, pending, 1)
+	assert.Contains(t, pending, "session-c")
+
+	assert.Empty(t, store.GetPendingThoughts("session-b"))
+}
+
+func TestRecentSessions_OrderAndLimit(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	for _, sessionID := range []string{"s1", "s2", "s3", "s4"} {
+		_, err := store.CreateSession(sessionID)
+		require.NoError(t, err)
+		clock.Advance(time.Second)
+	}
+
+	// Touching s2 moves it to the front
+	addThoughts(t, store, "s2", "revisited")
+
+	recent := store.RecentSessions(3)
+	require.Len(t, recent, 3)
+	assert.Equal(t, "s2", recent[0].SessionID)
+	assert.Equal(t, "s4", recent[1].SessionID)
+	assert.Equal(t, "s3", recent[2].SessionID)
+
+	// Asking for more than exist returns them all
+	assert.Len(t, store.RecentSessions(10), 4)
+}
+
+func TestIDStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		pattern  string
+	}{
+		{IDStrategyTimestamp, `^\d+-\d+$`},
+		{IDStrategyUUID, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{IDStrategyULID, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.IDStrategy = tt.strategy
+			store, err := New(cfg)
+			require.NoError(t, err)
+
+			thought := &types.ThoughtData{Thought: "id check", ThoughtNumber: 1}
+			require.NoError(t, store.AddThought("ids", thought))
+			assert.Regexp(t, tt.pattern, thought.ID)
+		})
+	}
+}
+
+func TestIDStrategies_Unknown(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.IDStrategy = "sequential"
+
+	_, err := New(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown id strategy")
+}
+
+func TestULIDs_SortByCreationOrder(t *testing.T) {
+	generate := newULIDGenerator()
+	clock := newFakeClock()
+
+	ids := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		// Several IDs share a millisecond, exercising the monotonic increment
+		if i%10 == 0 {
+			clock.Advance(time.Millisecond)
+		}
+		ids = append(ids, generate(clock.Now()))
+	}
+
+	assert.True(t, sort.StringsAreSorted(ids), "ULIDs should sort in creation order")
+
+	unique := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	assert.Len(t, unique, len(ids))
+}
+
+func TestNewID_UniqueUnderConcurrency(t *testing.T) {
+	for _, strategy := range []string{IDStrategyUUID, IDStrategyULID, IDStrategyTimestamp} {
+		t.Run(strategy, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.IDStrategy = strategy
+			store, err := New(cfg)
+			require.NoError(t, err)
+			t.Cleanup(store.Close)
+
+			const workers = 10
+			const idsPerWorker = 1000
+
+			ids := make(chan string, workers*idsPerWorker)
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < idsPerWorker; i++ {
+						ids <- store.NewID()
+					}
+				}()
+			}
+			wg.Wait()
+			close(ids)
+
+			unique := make(map[string]bool, workers*idsPerWorker)
+			for id := range ids {
+				require.False(t, unique[id], "duplicate id %s", id)
+				unique[id] = true
+			}
+			assert.Len(t, unique, workers*idsPerWorker)
+		})
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+
+	assert.Equal(t, "00000000000000000000000000", encodeULID([16]byte{}))
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeULID(max))
+}
+
+// violationKinds collects the kinds of the reported violations
+func violationKinds(verification *types.SessionVerification) []string {
+	kinds := make([]string, 0, len(verification.Violations))
+	for _, violation := range verification.Violations {
+		kinds = append(kinds, violation.Kind)
+	}
+	return kinds
+}
+
+func TestVerifySession_Valid(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "clean", "One", "Two", "Three")
+
+	revises := 2
+	require.NoError(t, store.AddThought("clean", &types.ThoughtData{
+		Thought: "Rethink two", ThoughtNumber: 4, IsRevision: true, RevisesThought: &revises,
+	}))
+	branchFrom := 1
+	require.NoError(t, store.AddThought("clean", &types.ThoughtData{
+		Thought: "Alternative", ThoughtNumber: 2, BranchFromThought: &branchFrom, BranchID: "alt",
+	}))
+
+	verification, err := store.VerifySession("clean")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Empty(t, verification.Violations)
+}
+
+func TestThoughtNumberBase_Validation(t *testing.T) {
+	for _, base := range []int{0, 1} {
+		t.Run(fmt.Sprintf("base=%d", base), func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.ThoughtNumberBase = base
+			store, err := New(cfg)
+			require.NoError(t, err)
+			t.Cleanup(store.Close)
+
+			// A sequence starting at the base is valid
+			for i, thought := range []string{"One", "Two", "Three"} {
+				require.NoError(t, store.AddThought("numbered", &types.ThoughtData{Thought: thought, ThoughtNumber: base + i}))
+			}
+			verification, err := store.VerifySession("numbered")
+			require.NoError(t, err)
+			assert.True(t, verification.Valid, verification.Violations)
+
+			// Numbers below the base are rejected
+			err = store.AddThought("numbered", &types.ThoughtData{Thought: "Too low", ThoughtNumber: base - 1})
+			assert.ErrorContains(t, err, fmt.Sprintf("below the first thought number %d", base))
+
+			// Skipping the base itself is a gap at the base
+			require.NoError(t, store.AddThought("late-start", &types.ThoughtData{Thought: "Second", ThoughtNumber: base + 1}))
+			verification, err = store.VerifySession("late-start")
+			require.NoError(t, err)
+			require.Len(t, verification.Violations, 1)
+			assert.Equal(t, types.ViolationNumberingGap, verification.Violations[0].Kind)
+			assert.Equal(t, base, verification.Violations[0].ThoughtNumber)
+
+			// Repair renumbers from the base
+			repair, err := store.RepairSession("late-start", false)
+			require.NoError(t, err)
+			assert.NotEmpty(t, repair.Changes)
+			thoughts, err := store.GetThoughts("late-start")
+			require.NoError(t, err)
+			assert.Equal(t, base, thoughts[0].ThoughtNumber)
+
+			// Promotion onto an empty trunk starts at the base
+			require.NoError(t, store.AddThought("branch-only", &types.ThoughtData{Thought: "Alt", ThoughtNumber: base + 1, BranchID: "alt"}))
+			length, err := store.PromoteBranch("branch-only", "alt", true)
+			require.NoError(t, err)
+			assert.Equal(t, 1, length)
+			thoughts, err = store.GetThoughts("branch-only")
+			require.NoError(t, err)
+			assert.Equal(t, base, thoughts[0].ThoughtNumber)
+		})
+	}
+}
+
+func TestThoughtNumberBase_Invalid(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ThoughtNumberBase = 2
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "invalid thought number base 2")
+}
+
+func TestVerifySession_CorruptedSession(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "corrupt", "One", "Two", "Three", "Four")
+
+	thoughts, err := store.GetThoughts("corrupt")
+	require.NoError(t, err)
+
+	// Gap: thought 2 renumbered away, leaving 1, 3, 3, 4
+	thoughts[1].ThoughtNumber = 3
+	// Dangling revision and branch pointers
+	missing := 42
+	thoughts[2].IsRevision = true
+	thoughts[2].RevisesThought = &missing
+	thoughts[3].BranchFromThought = &missing
+	thoughts[3].BranchID = "ghost"
+	// Index entry with no stored thought
+	sh := store.shardFor("corrupt")
+	sh.sessionThoughts["corrupt"] = append(sh.sessionThoughts["corrupt"], "vanished-id")
+	// Counter drift
+	session, err := store.GetSession("corrupt")
+	require.NoError(t, err)
+	session.ThoughtCount = 7
+
+	verification, err := store.VerifySession("corrupt")
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+
+	kinds := violationKinds(verification)
+	assert.Contains(t, kinds, types.ViolationNumberingGap)
+	assert.Contains(t, kinds, types.ViolationDuplicateNumber)
+	assert.Contains(t, kinds, types.ViolationDanglingRevision)
+	assert.Contains(t, kinds, types.ViolationDanglingBranch)
+	assert.Contains(t, kinds, types.ViolationMissingEntity)
+	assert.Contains(t, kinds, types.ViolationCountMismatch)
+
+	_, err = store.VerifySession("missing-session")
+	assert.Error(t, err)
+}
+
+func TestRepairSession_DanglingRevisionAndCountMismatch(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "broken", "One", "Two", "Three")
+
+	thoughts, err := store.GetThoughts("broken")
+	require.NoError(t, err)
+
+	missing := 42
+	thoughts[2].IsRevision = true
+	thoughts[2].RevisesThought = &missing
+	session, err := store.GetSession("broken")
+	require.NoError(t, err)
+	session.ThoughtCount = 9
+
+	// A dry run reports the fixes without applying them
+	preview, err := store.RepairSession("broken", true)
+	require.NoError(t, err)
+	assert.True(t, preview.DryRun)
+	require.Len(t, preview.Changes, 2)
+	assert.Equal(t, types.RepairDroppedRevision, preview.Changes[0].Kind)
+	assert.Equal(t, thoughts[2].ID, preview.Changes[0].ThoughtID)
+	assert.Equal(t, types.RepairReconciledCount, preview.Changes[1].Kind)
+	assert.Equal(t, &missing, thoughts[2].RevisesThought)
+	assert.Equal(t, 9, session.ThoughtCount)
+
+	repair, err := store.RepairSession("broken", false)
+	require.NoError(t, err)
+	assert.False(t, repair.DryRun)
+	assert.Equal(t, preview.Changes, repair.Changes)
+
+	repaired, err := store.GetThoughts("broken")
+	require.NoError(t, err)
+	assert.Nil(t, repaired[2].RevisesThought)
+	assert.False(t, repaired[2].IsRevision)
+
+	session, err = store.GetSession("broken")
+	require.NoError(t, err)
+	assert.Equal(t, 3, session.ThoughtCount)
+	assert.Equal(t, 997, session.RemainingThoughts)
+
+	verification, err := store.VerifySession("broken")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+}
+
+func TestRecomputeStats_CorrectsSkewedCounters(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "skewed", "One", "Two")
+	require.NoError(t, store.AddMentalModel("skewed", &types.MentalModelData{ModelName: "inversion"}))
+
+	// Skew the counters and leave a dangling index entry behind
+	session, err := store.GetSession("skewed")
+	require.NoError(t, err)
+	session.ThoughtCount = 7
+	session.RemainingThoughts = -3
+	session.TotalOperations = 50
+	sh := store.shardFor("skewed")
+	sh.sessionThoughts["skewed"] = append(sh.sessionThoughts["skewed"], "missing-thought")
+
+	recomputation, err := store.RecomputeStats("skewed")
+	require.NoError(t, err)
+	assert.Equal(t, 2, recomputation.ThoughtCount)
+	assert.Equal(t, store.config.MaxThoughtsPerSession-2, recomputation.RemainingThoughts)
+	assert.Equal(t, 3, recomputation.TotalOperations)
+	assert.Equal(t, map[string]int{"thoughts": 2, "mental_models": 1}, recomputation.Stores)
+	assert.Equal(t, []types.StatCorrection{
+		{Field: "thought_count", Before: 7, After: 2},
+		{Field: "remaining_thoughts", Before: -3, After: store.config.MaxThoughtsPerSession - 2},
+		{Field: "total_operations", Before: 50, After: 3},
+	}, recomputation.Corrections)
+
+	// The corrected values are persisted on the session
+	assert.Equal(t, 2, session.ThoughtCount)
+	assert.Equal(t, store.config.MaxThoughtsPerSession-2, session.RemainingThoughts)
+	assert.Equal(t, 3, session.TotalOperations)
+
+	// A second pass finds nothing left to correct
+	again, err := store.RecomputeStats("skewed")
+	require.NoError(t, err)
+	assert.Empty(t, again.Corrections)
+
+	_, err = store.RecomputeStats("no-such-session")
+	assert.Error(t, err)
+}
+
+func TestRecomputeStats_UntouchedSessionNeedsNoCorrections(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "steady", "One", "Two", "Three")
+	require.NoError(t, store.AddMentalModel("steady", &types.MentalModelData{ModelName: "inversion"}))
+	_, err := store.CheckpointSession("steady", "baseline")
+	require.NoError(t, err)
+	addBranch(t, store, "steady")
+	_, err = store.PromoteBranch("steady", "alt", false)
+	require.NoError(t, err)
+	require.NoError(t, store.AddMentalModels("steady", []*types.MentalModelData{
+		{ModelName: "first_principles"},
+		{ModelName: "second_order"},
+	}))
+
+	// Every write keeps the counters in step, so there is nothing to correct
+	recomputation, err := store.RecomputeStats("steady")
+	require.NoError(t, err)
+	assert.Empty(t, recomputation.Corrections)
+	assert.Equal(t, 10, recomputation.TotalOperations)
+
+	_, err = store.RestoreCheckpoint("steady", "baseline")
+	require.NoError(t, err)
+	recomputation, err = store.RecomputeStats("steady")
+	require.NoError(t, err)
+	assert.Empty(t, recomputation.Corrections)
+	assert.Equal(t, 4, recomputation.TotalOperations)
+	assert.Equal(t, store.config.MaxThoughtsPerSession-3, recomputation.RemainingThoughts)
+}
+
+func TestRepairSession_RenumbersAndRemapsLinks(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "gappy", "One", "Two", "Three")
+
+	revises := 3
+	require.NoError(t, store.AddThought("gappy", &types.ThoughtData{
+		Thought: "Rethink three", ThoughtNumber: 5, IsRevision: true, RevisesThought: &revises,
+	}))
+	sh := store.shardFor("gappy")
+	sh.sessionThoughts["gappy"] = append(sh.sessionThoughts["gappy"], "vanished-id")
+
+	repair, err := store.RepairSession("gappy", false)
+	require.NoError(t, err)
+
+	kinds := make([]string, 0, len(repair.Changes))
+	for _, change := range repair.Changes {
+		kinds = append(kinds, change.Kind)
+	}
+	assert.Equal(t, []string{types.RepairDroppedIndexEntry, types.RepairRenumbered}, kinds)
+
+	thoughts, err := store.GetThoughts("gappy")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 4)
+	assert.Equal(t, 4, thoughts[3].ThoughtNumber)
+	require.NotNil(t, thoughts[3].RevisesThought)
+	assert.Equal(t, 3, *thoughts[3].RevisesThought)
+
+	verification, err := store.VerifySession("gappy")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+
+	_, err = store.RepairSession("missing-session", false)
+	assert.Error(t, err)
+}
+
+func TestSessionMetadata_SetOverwriteAndGet(t *testing.T) {
+	store := newTestStorage(t)
+
+	require.NoError(t, store.SetSessionMetadata("meta", "project", "gothink"))
+	require.NoError(t, store.SetSessionMetadata("meta", "ticket", "https://example.com/T-1"))
+	require.NoError(t, store.SetSessionMetadata("meta", "ticket", "https://example.com/T-2"))
+
+	metadata, err := store.GetSessionMetadata("meta")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"project": "gothink",
+		"ticket":  "https://example.com/T-2",
+	}, metadata)
+
+	// The returned map is a copy
+	metadata["project"] = "changed"
+	stored, err := store.GetSessionMetadata("meta")
+	require.NoError(t, err)
+	assert.Equal(t, "gothink", stored["project"])
+
+	_, err = store.GetSessionMetadata("missing-session")
+	assert.Error(t, err)
+}
+
+func TestSessionMetadata_Limits(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxMetadataEntries = 2
+	store.config.MaxMetadataKeyLength = 8
+	store.config.MaxMetadataValueLength = 16
+
+	assert.Error(t, store.SetSessionMetadata("limits", "", "value"))
+	assert.Error(t, store.SetSessionMetadata("limits", "much-too-long-key", "value"))
+	assert.Error(t, store.SetSessionMetadata("limits", "key", "a value that is far too long"))
+
+	require.NoError(t, store.SetSessionMetadata("limits", "a", "1"))
+	require.NoError(t, store.SetSessionMetadata("limits", "b", "2"))
+	assert.Error(t, store.SetSessionMetadata("limits", "c", "3"))
+	// Overwriting an existing key does not count against the entry limit
+	assert.NoError(t, store.SetSessionMetadata("limits", "b", "20"))
+
+	require.NoError(t, store.ArchiveSession("limits"))
+	assert.ErrorIs(t, store.SetSessionMetadata("limits", "a", "10"), ErrSessionArchived)
+}
+
+func TestSessionMetadata_RingOverflow(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxMetadataEntries = 2
+	store.config.MetadataOverflow = OverflowRing
+
+	require.NoError(t, store.SetSessionMetadata("ring", "a", "1"))
+	require.NoError(t, store.SetSessionMetadata("ring", "b", "2"))
+	require.NoError(t, store.SetSessionMetadata("ring", "a", "10"))
+	require.NoError(t, store.SetSessionMetadata("ring", "c", "3"))
+
+	// The oldest key is dropped even though it was overwritten since
+	metadata, err := store.GetSessionMetadata("ring")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"b": "2", "c": "3"}, metadata)
+}
+
+func TestRecordToolUse_ToolsUsedCap(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxToolsUsed = 2
+	store.config.ToolsUsedOverflow = OverflowReject
+	_, err := store.CreateSession("tools")
+	require.NoError(t, err)
+
+	require.NoError(t, store.RecordToolUse("tools", "a"))
+	require.NoError(t, store.RecordToolUse("tools", "b"))
+	// Repeating a known tool does not grow the list
+	require.NoError(t, store.RecordToolUse("tools", "a"))
+	assert.Error(t, store.RecordToolUse("tools", "c"))
+
+	stats, err := store.GetSessionStats("tools")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, stats.ToolsUsed)
+
+	// A rejected call leaves the audit trail untouched
+	audit, err := store.GetSessionAudit("tools")
+	require.NoError(t, err)
+	assert.Len(t, audit, 3)
+
+	store.config.ToolsUsedOverflow = OverflowRing
+	require.NoError(t, store.RecordToolUse("tools", "c"))
+	stats, err = store.GetSessionStats("tools")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, stats.ToolsUsed)
+
+	assert.Error(t, store.RecordToolUse("missing-session", "a"))
+}
+
+func TestRecordToolUse_AuditCap(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxAuditEntries = 2
+	store.config.AuditOverflow = OverflowRing
+	_, err := store.CreateSession("audit")
+	require.NoError(t, err)
+
+	for _, tool := range []string{"a", "b", "c"} {
+		require.NoError(t, store.RecordToolUse("audit", tool))
+	}
+
+	audit, err := store.GetSessionAudit("audit")
+	require.NoError(t, err)
+	require.Len(t, audit, 2)
+	assert.Equal(t, "b", audit[0].Tool)
+	assert.Equal(t, "c", audit[1].Tool)
+
+	store.config.AuditOverflow = OverflowReject
+	assert.Error(t, store.RecordToolUse("audit", "d"))
+
+	// A rejected call does not add the tool either
+	stats, err := store.GetSessionStats("audit")
+	require.NoError(t, err)
+	assert.NotContains(t, stats.ToolsUsed, "d")
+}
+
+func TestNew_InvalidOverflowPolicy(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AuditOverflow = "drop"
+	_, err := New(cfg)
+	assert.Error(t, err)
+}
+
+func TestSessionMetadata_ExportRoundTrip(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "exported", "One")
+	require.NoError(t, store.SetSessionMetadata("exported", "project", "gothink"))
+
+	export, err := store.ExportSession("exported")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(export)
+	require.NoError(t, err)
+
+	var decoded struct {
+		Data struct {
+			Metadata map[string]string `json:"metadata"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, map[string]string{"project": "gothink"}, decoded.Data.Metadata)
+}
+
+// addBranch stores an "alt" branch off thought 2 whose second thought revises its first
+func addBranch(t *testing.T, store *Storage, sessionID string) {
+	t.Helper()
+
+	branchFrom := 2
+	require.NoError(t, store.AddThought(sessionID, &types.ThoughtData{
+		Thought: "Alternative", ThoughtNumber: 3, BranchFromThought: &branchFrom, BranchID: "alt",
+	}))
+	revises := 3
+	require.NoError(t, store.AddThought(sessionID, &types.ThoughtData{
+		Thought: "Refined alternative", ThoughtNumber: 4, BranchID: "alt", IsRevision: true, RevisesThought: &revises,
+	}))
+}
+
+func TestPromoteBranch_MovesBranchIntoTrunk(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "promote", "One", "Two", "Three")
+	addBranch(t, store, "promote")
+
+	trunkLength, err := store.PromoteBranch("promote", "alt", true)
+	require.NoError(t, err)
+	assert.Equal(t, 5, trunkLength)
+
+	thoughts, err := store.GetThoughts("promote")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 5)
+
+	trunk := make(map[int]*types.ThoughtData)
+	for _, thought := range thoughts {
+		assert.Empty(t, thought.BranchID)
+		trunk[thought.ThoughtNumber] = thought
+	}
+	for number := 1; number <= 5; number++ {
+		require.Contains(t, trunk, number)
+	}
+	assert.Equal(t, "Alternative", trunk[4].Thought)
+	assert.Nil(t, trunk[4].BranchFromThought)
+	require.NotNil(t, trunk[5].RevisesThought)
+	assert.Equal(t, 4, *trunk[5].RevisesThought)
+
+	session, err := store.GetSession("promote")
+	require.NoError(t, err)
+	assert.Equal(t, 5, session.ThoughtCount)
+
+	verification, err := store.VerifySession("promote")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+
+	_, err = store.PromoteBranch("promote", "alt", true)
+	assert.Error(t, err)
+}
+
+func TestPromoteBranch_CopiesAndKeepsBranch(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "copy", "One", "Two", "Three")
+	addBranch(t, store, "copy")
+
+	trunkLength, err := store.PromoteBranch("copy", "alt", false)
+	require.NoError(t, err)
+	assert.Equal(t, 5, trunkLength)
+
+	thoughts, err := store.GetThoughts("copy")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 7)
+
+	branchThoughts := 0
+	for _, thought := range thoughts {
+		if thought.BranchID == "alt" {
+			branchThoughts++
+			assert.LessOrEqual(t, thought.ThoughtNumber, 4)
+		}
+	}
+	assert.Equal(t, 2, branchThoughts)
+
+	promoted := thoughts[6]
+	assert.Equal(t, 5, promoted.ThoughtNumber)
+	require.NotNil(t, promoted.RevisesThought)
+	assert.Equal(t, 4, *promoted.RevisesThought)
+	assert.NotEqual(t, thoughts[4].ID, promoted.ID)
+
+	session, err := store.GetSession("copy")
+	require.NoError(t, err)
+	assert.Equal(t, 7, session.ThoughtCount)
+
+	verification, err := store.VerifySession("copy")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+
+	_, err = store.PromoteBranch("copy", "missing-branch", false)
+	assert.Error(t, err)
+}
+
+func TestExportSessionBranch_KeepsOnlyBranchLineage(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "branched", "One", "Two", "Three")
+	addBranch(t, store, "branched")
+	branchFrom := 1
+	require.NoError(t, store.AddThought("branched", &types.ThoughtData{
+		Thought: "Dead end", ThoughtNumber: 2, BranchFromThought: &branchFrom, BranchID: "abandoned",
+	}))
+	require.NoError(t, store.AddMentalModel("branched", &types.MentalModelData{ModelName: "inversion", Problem: "Which path"}))
+
+	export, err := store.ExportSessionBranch("branched", "alt")
+	require.NoError(t, err)
+	assert.Equal(t, "alt", export.Metadata["branch_id"])
+
+	data := export.Data.(map[string]interface{})
+	var texts []string
+	for _, thought := range data["thoughts"].([]*types.ThoughtData) {
+		texts = append(texts, thought.Thought)
+	}
+	assert.Equal(t, []string{"One", "Two", "Alternative", "Refined alternative"}, texts)
+	assert.Len(t, data["mental_models"], 1)
+
+	// The whole-session export is unaffected
+	full, err := store.ExportSession("branched")
+	require.NoError(t, err)
+	assert.Len(t, full.Data.(map[string]interface{})["thoughts"], 6)
+
+	_, err = store.ExportSessionBranch("branched", "missing")
+	assert.Error(t, err)
+	_, err = store.ExportSessionBranch("branched", "")
+	assert.Error(t, err)
+}
+
+func TestResolvedThoughts_KeepsLatestRevisions(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "revised", "Plan", "Guess", "Measure")
+
+	revise := func(number, revises int, text string) {
+		t.Helper()
+		require.NoError(t, store.AddThought("revised", &types.ThoughtData{
+			Thought: text, ThoughtNumber: number, IsRevision: true, RevisesThought: &revises,
+		}))
+	}
+	revise(4, 2, "Better guess")
+	revise(5, 2, "Best guess")
+	// A revision of a revision still resolves to the original slot
+	revise(6, 3, "Measure again")
+	revise(7, 6, "Measure properly")
+	addBranch(t, store, "revised")
+
+	resolved, err := store.ResolvedThoughts("revised")
+	require.NoError(t, err)
+	require.Len(t, resolved, 3)
+
+	assert.Equal(t, 1, resolved[0].ThoughtNumber)
+	assert.Equal(t, "Plan", resolved[0].Thought.Thought)
+	assert.Empty(t, resolved[0].Superseded)
+
+	assert.Equal(t, 2, resolved[1].ThoughtNumber)
+	assert.Equal(t, "Best guess", resolved[1].Thought.Thought)
+	assert.Equal(t, []int{2, 4}, resolved[1].Superseded)
+
+	assert.Equal(t, 3, resolved[2].ThoughtNumber)
+	assert.Equal(t, "Measure properly", resolved[2].Thought.Thought)
+	assert.Equal(t, []int{3, 6}, resolved[2].Superseded)
+
+	_, err = store.ResolvedThoughts("missing")
+	assert.Error(t, err)
+}
+
+func TestSessionModelSummary_GroupsByModelName(t *testing.T) {
+	store := newTestStorage(t)
+
+	applications := []*types.MentalModelData{
+		{ModelName: "first_principles", Problem: "Reduce latency"},
+		{ModelName: "rubber_duck", Problem: "Explain the cache"},
+		{ModelName: "first_principles", Problem: "Cut memory use"},
+		{ModelName: "pareto_principle", Problem: "Prioritize fixes"},
+	}
+	for _, application := range applications {
+		require.NoError(t, store.AddMentalModel("summary", application))
+	}
+	require.NoError(t, store.AddMentalModel("other", &types.MentalModelData{ModelName: "rubber_duck", Problem: "Elsewhere"}))
+
+	summary, err := store.SessionModelSummary("summary")
+	require.NoError(t, err)
+	assert.Equal(t, []types.ModelUsage{
+		{ModelName: "first_principles", Count: 2, Problems: []string{"Reduce latency", "Cut memory use"}},
+		{ModelName: "rubber_duck", Count: 1, Problems: []string{"Explain the cache"}},
+		{ModelName: "pareto_principle", Count: 1, Problems: []string{"Prioritize fixes"}},
+	}, summary)
+
+	empty, err := store.SessionModelSummary("no-models")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestSessionDecisions_SkipsInconclusiveModels(t *testing.T) {
+	store := newTestStorage(t)
+
+	require.NoError(t, store.AddMentalModels("decided", []*types.MentalModelData{
+		{ModelName: "first_principles", Problem: "Build or buy", Conclusion: "Buy", Confidence: 0.8},
+		{ModelName: "opportunity_cost", Problem: "Build or buy"},
+		{ModelName: "pareto_principle", Problem: "Which bugs first", Conclusion: "  "},
+		{ModelName: "inversion", Problem: "Launch risks", Conclusion: "Delay the launch"},
+	}))
+
+	decisions, err := store.SessionDecisions("decided")
+	require.NoError(t, err)
+	require.Len(t, decisions, 2)
+
+	assert.Equal(t, types.DecisionKindMentalModel, decisions[0].Kind)
+	assert.Equal(t, "first_principles", decisions[0].Name)
+	assert.Equal(t, "Buy", decisions[0].Conclusion)
+	assert.Equal(t, 0.8, decisions[0].Confidence)
+	assert.Equal(t, "inversion", decisions[1].Name)
+	assert.Equal(t, "Delay the launch", decisions[1].Conclusion)
+
+	empty, err := store.SessionDecisions("no-models")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestSessionDecisions_ReportsDebuggingResolutions(t *testing.T) {
+	store := newTestStorage(t)
+
+	require.NoError(t, store.AddMentalModels("debugged", []*types.MentalModelData{
+		{ModelName: types.DebuggingApproachPrefix + "binary_search", Problem: "Flaky test", Conclusion: "Shared temp dir"},
+		{ModelName: types.DebuggingApproachPrefix + "rubber_duck", Problem: "Slow start"},
+		{ModelName: "inversion", Problem: "Launch risks", Conclusion: "Delay the launch"},
+	}))
+
+	decisions, err := store.SessionDecisions("debugged")
+	require.NoError(t, err)
+	require.Len(t, decisions, 2)
+
+	assert.Equal(t, types.DecisionKindDebugging, decisions[0].Kind)
+	assert.Equal(t, "binary_search", decisions[0].Name)
+	assert.Equal(t, "Flaky test", decisions[0].Problem)
+	assert.Equal(t, "Shared temp dir", decisions[0].Conclusion)
+	assert.Equal(t, types.DecisionKindMentalModel, decisions[1].Kind)
+	assert.Equal(t, "inversion", decisions[1].Name)
+}
+
+func TestSessionProblems_DeduplicatesAndCounts(t *testing.T) {
+	store := newTestStorage(t)
+
+	require.NoError(t, store.AddMentalModels("explored", []*types.MentalModelData{
+		{ModelName: "first_principles", Problem: "Build or buy"},
+		{ModelName: "inversion", Problem: "Launch risks"},
+		{ModelName: "opportunity_cost", Problem: " Build or buy "},
+	}))
+
+	problems, err := store.SessionProblems("explored")
+	require.NoError(t, err)
+	require.Len(t, problems, 2)
+
+	assert.Equal(t, "Build or buy", problems[0].Problem)
+	assert.Equal(t, 2, problems[0].ModelCount)
+	assert.Equal(t, []string{"first_principles", "opportunity_cost"}, problems[0].Models)
+	assert.Equal(t, "Launch risks", problems[1].Problem)
+	assert.Equal(t, 1, problems[1].ModelCount)
+
+	empty, err := store.SessionProblems("no-models")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestGetThoughts_OnlyReturnsSessionEntities(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "alice", "Alice one", "Alice two")
+	addThoughts(t, store, "bob", "Bob one")
+	require.NoError(t, store.AddMentalModel("alice", &types.MentalModelData{ModelName: "inversion"}))
+	require.NoError(t, store.AddMentalModel("bob", &types.MentalModelData{ModelName: "first_principles"}))
+
+	for sessionID, expected := range map[string][]string{
+		"alice": {"Alice one", "Alice two"},
+		"bob":   {"Bob one"},
+	} {
+		thoughts, err := store.GetThoughts(sessionID)
+		require.NoError(t, err)
+		var texts []string
+		for _, thought := range thoughts {
+			assert.Equal(t, sessionID, thought.SessionID)
+			texts = append(texts, thought.Thought)
+		}
+		assert.Equal(t, expected, texts)
+
+		models, err := store.GetMentalModels(sessionID)
+		require.NoError(t, err)
+		require.Len(t, models, 1)
+		assert.Equal(t, sessionID, models[0].SessionID)
+
+		stats, err := store.GetSessionStats(sessionID)
+		require.NoError(t, err)
+		assert.Equal(t, len(expected), stats.ThoughtCount)
+	}
+}
+
+func TestGetThoughts_IgnoresOtherSessionsSharingAnID(t *testing.T) {
+	store := newTestStorage(t)
+
+	// Find two sessions in the same shard so their thoughts share one map
+	first := "shared-0"
+	second := ""
+	for i := 1; second == ""; i++ {
+		if candidate := fmt.Sprintf("shared-%d", i); store.shardFor(candidate) == store.shardFor(first) {
+			second = candidate
+		}
+	}
+
+	require.NoError(t, store.AddThought(first, &types.ThoughtData{ID: "same-id", Thought: "First", ThoughtNumber: 1}))
+	require.NoError(t, store.AddThought(second, &types.ThoughtData{ID: "same-id", Thought: "Second", ThoughtNumber: 1}))
+
+	thoughts, err := store.GetThoughts(first)
+	require.NoError(t, err)
+	for _, thought := range thoughts {
+		assert.NotEqual(t, "Second", thought.Thought)
+	}
+
+	// Deleting the first session leaves the second session's thought alone
+	_, err = store.DeleteSessions(SessionFilter{IDPrefix: first})
+	require.NoError(t, err)
+	thoughts, err = store.GetThoughts(second)
+	require.NoError(t, err)
+	require.Len(t, thoughts, 1)
+	assert.Equal(t, "Second", thoughts[0].Thought)
+}
+
+func TestCountThoughts_Filters(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "counted", "One", "Two")
+	cutoff := clock.Now().Add(time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	revises := 1
+	require.NoError(t, store.AddThought("counted", &types.ThoughtData{
+		Thought: "Rethink one", ThoughtNumber: 3, IsRevision: true, RevisesThought: &revises,
+	}))
+	addBranch(t, store, "counted")
+	addThoughts(t, store, "elsewhere", "Not counted")
+
+	isRevision, notRevision := true, false
+
+	assert.Equal(t, 5, store.CountThoughts("counted", ThoughtFilter{}))
+	assert.Equal(t, 2, store.CountThoughts("counted", ThoughtFilter{IsRevision: &isRevision}))
+	assert.Equal(t, 3, store.CountThoughts("counted", ThoughtFilter{IsRevision: &notRevision}))
+	assert.Equal(t, 2, store.CountThoughts("counted", ThoughtFilter{BranchID: "alt"}))
+	assert.Equal(t, 3, store.CountThoughts("counted", ThoughtFilter{Since: cutoff}))
+	assert.Equal(t, 1, store.CountThoughts("counted", ThoughtFilter{BranchID: "alt", IsRevision: &isRevision, Since: cutoff}))
+	assert.Equal(t, 0, store.CountThoughts("missing-session", ThoughtFilter{}))
+}
+
+func TestTagThoughts_ByBranch(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "reviewed", "One", "Two")
+	addBranch(t, store, "reviewed")
+
+	tagged, err := store.TagThoughts("reviewed", ThoughtFilter{BranchID: "alt"}, " explored ")
+	require.NoError(t, err)
+	assert.Equal(t, 2, tagged)
+
+	thoughts, err := store.GetThoughts("reviewed")
+	require.NoError(t, err)
+	for _, thought := range thoughts {
+		if thought.BranchID == "alt" {
+			assert.Equal(t, []string{"explored"}, thought.Tags)
+		} else {
+			assert.Empty(t, thought.Tags)
+		}
+	}
+
+	// Tagging again leaves thoughts that already carry the tag unchanged
+	tagged, err = store.TagThoughts("reviewed", ThoughtFilter{BranchID: "alt"}, "explored")
+	require.NoError(t, err)
+	assert.Equal(t, 0, tagged)
+
+	_, err = store.TagThoughts("reviewed", ThoughtFilter{}, "  ")
+	assert.Error(t, err)
+	_, err = store.TagThoughts("no-such-session", ThoughtFilter{}, "explored")
+	assert.Error(t, err)
+}
+
+func TestTagThoughts_ByRevisionFlagAndNumberRange(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "reviewed", "One", "Two", "Three")
+	revises := 1
+	require.NoError(t, store.AddThought("reviewed", &types.ThoughtData{
+		Thought: "Rethink one", ThoughtNumber: 4, IsRevision: true, RevisesThought: &revises,
+	}))
+
+	isRevision := true
+	tagged, err := store.TagThoughts("reviewed", ThoughtFilter{IsRevision: &isRevision}, "revised")
+	require.NoError(t, err)
+	assert.Equal(t, 1, tagged)
+
+	from, to := 2, 3
+	tagged, err = store.TagThoughts("reviewed", ThoughtFilter{MinNumber: &from, MaxNumber: &to}, "middle")
+	require.NoError(t, err)
+	assert.Equal(t, 2, tagged)
+
+	thoughts, err := store.GetThoughts("reviewed")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 4)
+	assert.Empty(t, thoughts[0].Tags)
+	assert.Equal(t, []string{"middle"}, thoughts[1].Tags)
+	assert.Equal(t, []string{"middle"}, thoughts[2].Tags)
+	assert.Equal(t, []string{"revised"}, thoughts[3].Tags)
+
+	require.NoError(t, store.ArchiveSession("reviewed"))
+	_, err = store.TagThoughts("reviewed", ThoughtFilter{}, "late")
+	assert.ErrorIs(t, err, ErrSessionArchived)
+}
+
+func TestGetSessionStats_RevisionRatio(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "revised", "One", "Two", "Three")
+
+	for _, revises := range []int{1, 2} {
+		target := revises
+		require.NoError(t, store.AddThought("revised", &types.ThoughtData{
+			Thought: "Revision", ThoughtNumber: 3 + revises, IsRevision: true, RevisesThought: &target,
+		}))
+	}
+
+	stats, err := store.GetSessionStats("revised")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.RevisionCount)
+	assert.InDelta(t, 0.4, stats.RevisionRatio, 1e-9)
+
+	empty, err := store.GetSessionStats("no-thoughts")
+	require.NoError(t, err)
+	assert.Equal(t, 0, empty.RevisionCount)
+	assert.Equal(t, 0.0, empty.RevisionRatio)
+}
+
+func TestReapSessions_IdleSessionReactivatedByWrite(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "returning", "One")
+	addThoughts(t, store, "busy", "One")
+
+	clock.Advance(store.config.SessionTimeout)
+	addThoughts(t, store, "busy", "Two")
+
+	idled, evicted := store.ReapSessions()
+	assert.Equal(t, []string{"returning"}, idled)
+	assert.Empty(t, evicted)
+
+	stats, err := store.GetSessionStats("returning")
+	require.NoError(t, err)
+	assert.False(t, stats.IsActive)
+	assert.Equal(t, types.SessionStateIdle, stats.State)
+
+	// A write during the grace period reactivates the session
+	clock.Advance(store.config.GracePeriod / 2)
+	addThoughts(t, store, "returning", "Two")
+
+	stats, err = store.GetSessionStats("returning")
+	require.NoError(t, err)
+	assert.True(t, stats.IsActive)
+	assert.Equal(t, types.SessionStateActive, stats.State)
+
+	clock.Advance(store.config.GracePeriod)
+	idled, evicted = store.ReapSessions()
+	assert.Empty(t, idled)
+	assert.Empty(t, evicted)
+}
+
+func TestReapSessions_IdleSessionEvictedAfterGracePeriod(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "abandoned", "One", "Two")
+	require.NoError(t, store.AddMentalModel("abandoned", &types.MentalModelData{ModelName: "first_principles", Problem: "Gone"}))
+
+	clock.Advance(store.config.SessionTimeout)
+	idled, _ := store.ReapSessions()
+	assert.Equal(t, []string{"abandoned"}, idled)
+
+	// Still retained just before the grace period ends
+	clock.Advance(store.config.GracePeriod - time.Second)
+	idled, evicted := store.ReapSessions()
+	assert.Empty(t, idled)
+	assert.Empty(t, evicted)
+
+	clock.Advance(time.Second)
+	_, evicted = store.ReapSessions()
+	assert.Equal(t, []string{"abandoned"}, evicted)
+
+	_, err := store.GetSession("abandoned")
+	assert.Error(t, err)
+	thoughts, err := store.GetThoughts("abandoned")
+	require.NoError(t, err)
+	assert.Empty(t, thoughts)
+	assert.Equal(t, 0, store.GetGlobalStats().TotalMentalModels)
+	assert.Empty(t, store.RecentSessions(10))
+}
+
+func TestReapSessions_ActiveSessionEvictedAfterMaxLifetime(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxSessionLifetime = 2 * time.Hour
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "long-running", "Start")
+	events, cancel := store.Subscribe("long-running")
+	defer cancel()
+
+	// Writes keep the session active well past SessionTimeout
+	step := store.config.SessionTimeout / 2
+	for elapsed := step; elapsed < store.config.MaxSessionLifetime; elapsed += step {
+		clock.Advance(step)
+		addThoughts(t, store, "long-running", "Still working")
+		idled, evicted := store.ReapSessions()
+		require.Empty(t, idled)
+		require.Empty(t, evicted)
+	}
+
+	clock.Advance(step)
+	addThoughts(t, store, "long-running", "Still working")
+	_, evicted := store.ReapSessions()
+	assert.Equal(t, []string{"long-running"}, evicted)
+
+	_, err := store.GetSession("long-running")
+	assert.Error(t, err)
+
+	var kinds []EventKind
+	for event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	assert.Equal(t, []EventKind{EventThoughtAdded, EventSessionExpired, EventSessionCleared}, kinds[len(kinds)-3:])
+}
+
+func TestPurgeInactive_RemovesOnlyIdleSessions(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "stale-a", "Old")
+	require.NoError(t, store.AddMentalModel("stale-b", &types.MentalModelData{ModelName: "inversion", Problem: "Old"}))
+	clock.Advance(time.Hour)
+	addThoughts(t, store, "fresh", "New")
+
+	result, err := store.PurgeInactive(30 * time.Minute)
+	require.NoError(t, err)
+
+	sort.Strings(result.Purged)
+	assert.Equal(t, []string{"stale-a", "stale-b"}, result.Purged)
+	assert.Equal(t, 2, result.ItemsRemoved)
+	assert.Greater(t, result.ReclaimedBytes, len("Old")*2)
+
+	_, err = store.GetSession("stale-a")
+	assert.Error(t, err)
+	_, err = store.GetSession("fresh")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, store.GetGlobalStats().TotalThoughts)
+
+	_, err = store.PurgeInactive(0)
+	assert.Error(t, err)
+}
+
+func TestClearSession_RemovesEverything(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "cleared", "One", "Two")
+	require.NoError(t, store.AddMentalModel("cleared", &types.MentalModelData{ModelName: "inversion"}))
+	addThoughts(t, store, "kept", "Untouched")
+
+	removed, err := store.ClearSession("cleared")
+	require.NoError(t, err)
+	assert.Equal(t, 3, removed)
+
+	_, err = store.GetSession("cleared")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	thoughts, err := store.GetThoughts("cleared")
+	require.NoError(t, err)
+	assert.Empty(t, thoughts)
+	assert.Empty(t, store.ModelApplications("inversion"))
+
+	thoughts, err = store.GetThoughts("kept")
+	require.NoError(t, err)
+	assert.Len(t, thoughts, 1)
+
+	// Clearing again reports the session as missing
+	removed, err = store.ClearSession("cleared")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	assert.Equal(t, 0, removed)
+}
+
+func TestClearSession_NonexistentSession(t *testing.T) {
+	store := newTestStorage(t)
+
+	_, err := store.ClearSession("never-created")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	_, err = store.GetSession("never-created")
+	assert.Error(t, err)
+}
+
+func TestSubscribe_ReceivesSessionEvents(t *testing.T) {
+	store := newTestStorage(t)
+
+	events, cancel := store.Subscribe("watched")
+	defer cancel()
+
+	require.NoError(t, store.AddThought("other", &types.ThoughtData{Thought: "Elsewhere", ThoughtNumber: 1}))
+	require.NoError(t, store.AddThought("watched", &types.ThoughtData{Thought: "Hello", ThoughtNumber: 1}))
+	require.NoError(t, store.AddMentalModel("watched", &types.MentalModelData{ModelName: "inversion"}))
+	require.NoError(t, store.ArchiveSession("watched"))
+
+	event := <-events
+	assert.Equal(t, EventSessionCreated, event.Kind)
+
+	event = <-events
+	assert.Equal(t, EventThoughtAdded, event.Kind)
+	assert.Equal(t, "watched", event.SessionID)
+	assert.Equal(t, "Hello", event.Thought.Thought)
+
+	event = <-events
+	assert.Equal(t, EventModelAdded, event.Kind)
+	assert.Equal(t, "inversion", event.Model.ModelName)
+
+	event = <-events
+	assert.Equal(t, EventSessionArchived, event.Kind)
+}
+
+func TestSubscribe_CancelClosesChannel(t *testing.T) {
+	store := newTestStorage(t)
+
+	events, cancel := store.Subscribe("watched")
+	assert.Equal(t, 1, store.SubscriberCount("watched"))
+
+	// Cancelling unsubscribes and closes the channel
+	cancel()
+	assert.Equal(t, 0, store.SubscriberCount("watched"))
+	_, open := <-events
+	assert.False(t, open)
+	cancel()
+}
+
+func TestSubscribe_ReceivesEventsDuringConcurrentWrites(t *testing.T) {
+	store := newTestStorage(t)
+
+	const writers = 8
+	const thoughtsPerWriter = 20
+
+	events, cancel := store.Subscribe("")
+	defer cancel()
+
+	received := make(chan int)
+	go func() {
+		count := 0
+		for event := range events {
+			if event.Kind == EventThoughtAdded {
+				count++
+			}
+		}
+		received <- count
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			sessionID := fmt.Sprintf("writer-%d", w)
+			for n := 1; n <= thoughtsPerWriter; n++ {
+				assert.NoError(t, store.AddThought(sessionID, &types.ThoughtData{Thought: "busy", ThoughtNumber: n}))
+			}
+		}(w)
+	}
+	wg.Wait()
+	cancel()
+
+	// The subscriber may fall behind and miss events, but never sees extras
+	count := <-received
+	assert.Greater(t, count, 0)
+	assert.LessOrEqual(t, count, writers*thoughtsPerWriter)
+}
+
+func TestSubscribe_SlowSubscriberDoesNotBlockWriters(t *testing.T) {
+	store := newTestStorage(t)
+
+	// Never read from the subscription
+	_, cancel := store.Subscribe("stalled")
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 1; n <= subscriberBuffer*3; n++ {
+			assert.NoError(t, store.AddThought("stalled", &types.ThoughtData{Thought: "more", ThoughtNumber: n}))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes blocked on a full subscriber")
+	}
+}
+
+func TestSubscribe_SessionClearedOnEviction(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	addThoughts(t, store, "evicted", "Only thought")
+	events, cancel := store.Subscribe("evicted")
+	defer cancel()
+
+	now = now.Add(store.config.SessionTimeout + store.config.GracePeriod)
+	_, evicted := store.ReapSessions()
+	require.Equal(t, []string{"evicted"}, evicted)
+
+	event := <-events
+	assert.Equal(t, EventSessionCleared, event.Kind)
+	assert.Equal(t, "evicted", event.SessionID)
+}
+
+func TestSubscribe_ClosedWhenSessionDeleted(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	addThoughts(t, store, "deleted", "Only thought")
+	events, cancel := store.Subscribe("deleted")
+	defer cancel()
+	all, cancelAll := store.Subscribe("")
+	defer cancelAll()
+
+	now = now.Add(time.Hour)
+	_, err := store.PurgeInactive(time.Minute)
+	require.NoError(t, err)
+
+	// The session's subscription ends with the termination event
+	event, open := <-events
+	require.True(t, open)
+	assert.Equal(t, EventSessionCleared, event.Kind)
+	assert.Equal(t, "deleted", event.SessionID)
+	_, open = <-events
+	assert.False(t, open)
+	assert.Equal(t, 0, store.SubscriberCount("deleted"))
+
+	// Subscribers to every session keep streaming
+	event = <-all
+	assert.Equal(t, EventSessionCleared, event.Kind)
+	assert.Equal(t, 1, store.SubscriberCount(""))
+}
+
+func TestSubscribe_ClosedWhenSessionArchivedWithFullBuffer(t *testing.T) {
+	store := newTestStorage(t)
+
+	// Fill the buffer without reading; the final event still gets through
+	events, cancel := store.Subscribe("busy")
+	defer cancel()
+	for n := 1; n <= subscriberBuffer+5; n++ {
+		require.NoError(t, store.AddThought("busy", &types.ThoughtData{Thought: "more", ThoughtNumber: n}))
+	}
+	require.NoError(t, store.ArchiveSession("busy"))
+
+	var last Event
+	for event := range events {
+		last = event
+	}
+	assert.Equal(t, EventSessionArchived, last.Kind)
+	assert.Equal(t, 0, store.SubscriberCount("busy"))
+}
+
+func TestSubscribe_StaysOpenWhenEndingDisabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.EndSubscriptionsWithSession = false
+	store, err := New(cfg)
+	require.NoError(t, err)
+
+	_, err = store.CreateSession("kept")
+	require.NoError(t, err)
+	events, cancel := store.Subscribe("kept")
+	defer cancel()
+
+	require.NoError(t, store.ArchiveSession("kept"))
+	event := <-events
+	assert.Equal(t, EventSessionArchived, event.Kind)
+	assert.Equal(t, 1, store.SubscriberCount("kept"))
+}
+
+func TestClose_StopsReaper(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ReaperInterval = time.Millisecond
+	store, err := New(cfg)
+	require.NoError(t, err)
+
+	store.Close()
+	store.Close()
+}
+
+func TestReaper_EvictsIdleSessionInBackground(t *testing.T) {
+	for name, configure := range map[string]func(cfg *config.Config){
+		"session timeout": func(cfg *config.Config) {
+			cfg.SessionTimeout = 20 * time.Millisecond
+			cfg.GracePeriod = 0
+		},
+		"max lifetime only": func(cfg *config.Config) {
+			cfg.SessionTimeout = 0
+			cfg.MaxSessionLifetime = 20 * time.Millisecond
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.ReaperInterval = 5 * time.Millisecond
+			configure(cfg)
+			store, err := New(cfg)
+			require.NoError(t, err)
+			t.Cleanup(store.Close)
+
+			addThoughts(t, store, "forgotten", "One", "Two")
+
+			assert.Eventually(t, func() bool {
+				_, err := store.GetSession("forgotten")
+				return err != nil
+			}, 2*time.Second, 5*time.Millisecond)
+			assert.Equal(t, 0, store.GetGlobalStats().TotalThoughts)
+		})
+	}
+}
+
+func TestSessionsByTag_SingleAndMultipleTags(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	_, err := store.TagSession("alpha", []string{"gothink", "design"})
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+	_, err = store.TagSession("beta", []string{"gothink"})
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+	tags, err := store.TagSession("gamma", []string{"design", " design "})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"design"}, tags)
+
+	sessionIDs, err := store.SessionsByTag([]string{"gothink"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"beta", "alpha"}, sessionIDs)
+
+	sessionIDs, err = store.SessionsByTag([]string{"gothink", "design"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gamma", "beta", "alpha"}, sessionIDs)
+
+	sessionIDs, err = store.SessionsByTag([]string{"gothink", "design"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alpha"}, sessionIDs)
+
+	// Writing to a session moves it to the front
+	clock.Advance(time.Minute)
+	addThoughts(t, store, "alpha", "Back to alpha")
+	sessionIDs, err = store.SessionsByTag([]string{"design"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alpha", "gamma"}, sessionIDs)
+
+	tags, err = store.UntagSession("alpha", []string{"design"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gothink"}, tags)
+	sessionIDs, err = store.SessionsByTag([]string{"design"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gamma"}, sessionIDs)
+
+	_, err = store.SessionsByTag([]string{""}, false)
+	assert.Error(t, err)
+}
+
+func TestSessionsByTag_RemovedSessionsLeaveIndex(t *testing.T) {
+	store := newTestStorage(t)
+	_, err := store.TagSession("purged", []string{"temp"})
+	require.NoError(t, err)
+
+	store.now = func() time.Time { return time.Now().Add(time.Hour) }
+	result, err := store.PurgeInactive(time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"purged"}, result.Purged)
+
+	sessionIDs, err := store.SessionsByTag([]string{"temp"}, false)
+	require.NoError(t, err)
+	assert.Empty(t, sessionIDs)
+	assert.Empty(t, store.tags.sessions)
+}
+
+func TestModelApplications_AcrossSessions(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.CaseInsensitiveNames = true
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	require.NoError(t, store.AddMentalModel("first", &types.MentalModelData{ModelName: "first_principles", Problem: "Why so slow?", Conclusion: "Too many hops"}))
+	clock.Advance(time.Minute)
+	require.NoError(t, store.AddMentalModel("second", &types.MentalModelData{ModelName: "opportunity_cost", Problem: "Build or buy?"}))
+	clock.Advance(time.Minute)
+	require.NoError(t, store.AddMentalModel("second", &types.MentalModelData{ModelName: "first_principles", Problem: "Why so costly?", Confidence: 0.6}))
+
+	applications := store.ModelApplications("First_Principles")
+	require.Len(t, applications, 2)
+	assert.Equal(t, "first", applications[0].SessionID)
+	assert.Equal(t, "Why so slow?", applications[0].Problem)
+	assert.Equal(t, "Too many hops", applications[0].Conclusion)
+	assert.Equal(t, "second", applications[1].SessionID)
+	assert.Equal(t, 0.6, applications[1].Confidence)
+
+	assert.Empty(t, store.ModelApplications("inversion"))
+
+	// Removing a session removes its applications from the index
+	unlock := store.lockSession("first")
+	store.removeSession("first")
+	unlock()
+	applications = store.ModelApplications("first_principles")
+	require.Len(t, applications, 1)
+	assert.Equal(t, "second", applications[0].SessionID)
+}
+
+func TestNewSessionID_Styles(t *testing.T) {
+	for style, pattern := range map[string]*regexp.Regexp{
+		SessionIDStyleUUID:  regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`),
+		SessionIDStyleWords: regexp.MustCompile(`^[a-z]+-[a-z]+-[1-9][0-9]{3}$`),
+	} {
+		t.Run(style, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.SessionIDStyle = style
+			store, err := New(cfg)
+			require.NoError(t, err)
+			t.Cleanup(store.Close)
+
+			seen := make(map[string]bool)
+			for i := 0; i < 500; i++ {
+				sessionID := store.NewSessionID()
+				assert.Regexp(t, pattern, sessionID)
+				assert.False(t, seen[sessionID], "duplicate session ID %s", sessionID)
+				seen[sessionID] = true
+
+				_, err := store.CreateSession(sessionID)
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewSessionID_SkipsExistingSessions(t *testing.T) {
+	store := newTestStorage(t)
+	minted := []string{"taken", "taken", "free"}
+	store.sessionIDGenerator = func() string {
+		sessionID := minted[0]
+		minted = minted[1:]
+		return sessionID
+	}
+	_, err := store.CreateSession("taken")
+	require.NoError(t, err)
+
+	assert.Equal(t, "free", store.NewSessionID())
+}
+
+func TestNew_InvalidSessionIDStyle(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SessionIDStyle = "emoji"
+	_, err := New(cfg)
+	assert.Error(t, err)
+}
+
+func TestSimilarThoughts_ClustersNearDuplicates(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "dupes",
+		"The cache is invalidated too often under load",
+		"Check the database indexes for the orders table",
+		"The cache is invalidated  too often under heavy load",
+		"Consider a write-behind queue for audit events",
+		"check the database indexes on the orders table",
+		"the cache is INVALIDATED too often under load.",
+	)
+
+	clusters, err := store.SimilarThoughts("dupes", 0.6)
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+
+	numbers := func(cluster types.ThoughtCluster) []int {
+		var result []int
+		for _, thought := range cluster.Thoughts {
+			result = append(result, thought.ThoughtNumber)
+		}
+		return result
+	}
+	assert.Equal(t, []int{1, 3, 6}, numbers(clusters[0]))
+	assert.Equal(t, []int{2, 5}, numbers(clusters[1]))
+	assert.InDelta(t, 1.0, clusters[0].MaxSimilarity, 0.1)
+	assert.GreaterOrEqual(t, clusters[1].MaxSimilarity, 0.6)
+
+	// A strict threshold only keeps exact matches after normalization
+	clusters, err = store.SimilarThoughts("dupes", 1)
+	require.NoError(t, err)
+	assert.Empty(t, clusters)
+
+	_, err = store.SimilarThoughts("dupes", 0)
+	assert.Error(t, err)
+	_, err = store.SimilarThoughts("missing-session", 0.5)
+	assert.Error(t, err)
+}
+
+func TestJaccard_Trigrams(t *testing.T) {
+	assert.Equal(t, 1.0, jaccard(trigrams("Same  Text"), trigrams("same text")))
+	assert.Equal(t, 0.0, jaccard(trigrams("abc"), trigrams("xyz")))
+	assert.InDelta(t, 0.5, jaccard(trigrams("abcd"), trigrams("bcd")), 1e-9)
+}
+
+func TestAddThought_LimitReachedErrorAndEventOnce(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxThoughtsPerSession = 2
+
+	events, cancel := store.Subscribe("capped")
+	defer cancel()
+
+	addThoughts(t, store, "capped", "One", "Two")
+	for i := 0; i < 3; i++ {
+		err := store.AddThought("capped", &types.ThoughtData{Thought: "Over", ThoughtNumber: 3 + i})
+		assert.ErrorIs(t, err, ErrThoughtLimitReached)
+		assert.Contains(t, err.Error(), "capped")
+	}
+
+	limitEvents := 0
+	for len(events) > 0 {
+		if event := <-events; event.Kind == EventThoughtLimitReached {
+			limitEvents++
+			assert.Equal(t, 2, event.Thought.ThoughtNumber)
+		}
+	}
+	assert.Equal(t, 1, limitEvents)
+}
+
+func TestAnnotateThought_ValidatesAndDeduplicates(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "linked", "Check the fix")
+	thoughts, err := store.GetThoughts("linked")
+	require.NoError(t, err)
+	thoughtID := thoughts[0].ID
+
+	references, err := store.AnnotateThought("linked", thoughtID, []types.Reference{
+		{Type: types.ReferenceCommit, Value: "3f2c9ab"},
+		{Type: types.ReferenceURL, Value: "https://example.com/design"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, references, 2)
+
+	references, err = store.AnnotateThought("linked", thoughtID, []types.Reference{
+		{Type: types.ReferenceCommit, Value: "3f2c9ab"},
+		{Type: types.ReferenceDoc, Value: "docs/architecture.md"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []types.Reference{
+		{Type: types.ReferenceCommit, Value: "3f2c9ab"},
+		{Type: types.ReferenceURL, Value: "https://example.com/design"},
+		{Type: types.ReferenceDoc, Value: "docs/architecture.md"},
+	}, references)
+
+	// One invalid reference rejects the whole call
+	for _, invalid := range []types.Reference{
+		{Type: types.ReferenceURL, Value: "not a url"},
+		{Type: types.ReferenceURL, Value: "ftp://example.com/file"},
+		{Type: types.ReferenceCommit, Value: "xyz"},
+		{Type: "tweet", Value: "hello"},
+		{Type: types.ReferenceDoc, Value: " "},
+	} {
+		_, err := store.AnnotateThought("linked", thoughtID, []types.Reference{{Type: types.ReferenceIssue, Value: "GT-1"}, invalid})
+		assert.Error(t, err, invalid)
+	}
+	thoughts, _ = store.GetThoughts("linked")
+	assert.Len(t, thoughts[0].References, 3)
+
+	_, err = store.AnnotateThought("linked", "missing", []types.Reference{{Type: types.ReferenceIssue, Value: "GT-1"}})
+	assert.Error(t, err)
+	_, err = store.AnnotateThought("other", thoughtID, []types.Reference{{Type: types.ReferenceIssue, Value: "GT-1"}})
+	assert.Error(t, err)
+}
+
+func TestSessionDuration_MeasuresThoughtTimestamps(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+	start := clock.Now()
+
+	addThoughts(t, store, "timed", "One")
+	clock.Advance(time.Minute)
+	addThoughts(t, store, "timed", "Two")
+	clock.Advance(2 * time.Minute)
+	addThoughts(t, store, "timed", "Three")
+	// A gap longer than SessionTimeout counts as idle
+	clock.Advance(store.config.SessionTimeout + time.Minute)
+	addThoughts(t, store, "timed", "Four")
+
+	duration, err := store.SessionDuration("timed")
+	require.NoError(t, err)
+	assert.Equal(t, 4, duration.ThoughtCount)
+	require.NotNil(t, duration.FirstThoughtAt)
+	assert.True(t, start.Equal(*duration.FirstThoughtAt))
+	assert.True(t, clock.Now().Equal(*duration.LastThoughtAt))
+
+	wallClock := 3*time.Minute + store.config.SessionTimeout + time.Minute
+	assert.Equal(t, wallClock.Seconds(), duration.WallClockSeconds)
+	assert.Equal(t, wallClock.Seconds()/3, duration.AverageIntervalSeconds)
+	assert.Equal(t, (3 * time.Minute).Seconds(), duration.ActiveSeconds)
+}
+
+func TestSessionDuration_EmptyAndMissingSessions(t *testing.T) {
+	store := newTestStorage(t)
+	_, err := store.CreateSession("empty")
+	require.NoError(t, err)
+
+	duration, err := store.SessionDuration("empty")
+	require.NoError(t, err)
+	assert.Equal(t, 0, duration.ThoughtCount)
+	assert.Nil(t, duration.FirstThoughtAt)
+	assert.Zero(t, duration.WallClockSeconds)
+
+	addThoughts(t, store, "single", "Only")
+	duration, err = store.SessionDuration("single")
+	require.NoError(t, err)
+	assert.Zero(t, duration.WallClockSeconds)
+	assert.Zero(t, duration.AverageIntervalSeconds)
+
+	_, err = store.SessionDuration("missing")
+	assert.Error(t, err)
+}
+
+func TestSessionTimeline_MergesEntitiesInTimeOrder(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	_, err := store.CreateSession("history")
+	require.NoError(t, err)
+
+	clock.Advance(time.Second)
+	require.NoError(t, store.AddThought("history", &types.ThoughtData{Thought: "First", ThoughtNumber: 1}))
+	require.NoError(t, store.RecordToolUse("history", "sequential_thinking"))
+	clock.Advance(time.Second)
+	require.NoError(t, store.AddMentalModel("history", &types.MentalModelData{ModelName: "inversion"}))
+	clock.Advance(time.Second)
+	require.NoError(t, store.AddThought("history", &types.ThoughtData{Thought: "Second", ThoughtNumber: 2}))
+	clock.Advance(time.Second)
+	require.NoError(t, store.AddMentalModel("history", &types.MentalModelData{ModelName: "second_order"}))
+	clock.Advance(time.Second)
+	require.NoError(t, store.RecordToolUse("history", "debugging_approach"))
+
+	timeline, err := store.SessionTimeline("history")
+	require.NoError(t, err)
+
+	var kinds []string
+	for i, event := range timeline {
+		kinds = append(kinds, event.Kind)
+		if i > 0 {
+			assert.False(t, event.At.Before(timeline[i-1].At))
+		}
+	}
+	assert.Equal(t, []string{
+		types.TimelineSessionCreated,
+		types.TimelineThought,
+		types.TimelineToolCall,
+		types.TimelineMentalModel,
+		types.TimelineThought,
+		types.TimelineMentalModel,
+		types.TimelineToolCall,
+	}, kinds)
+	assert.Equal(t, "First", timeline[1].Thought.Thought)
+	assert.Equal(t, "sequential_thinking", timeline[2].Tool)
+	assert.Equal(t, "inversion", timeline[3].MentalModel.ModelName)
+	assert.Equal(t, "Second", timeline[4].Thought.Thought)
+	assert.Equal(t, "second_order", timeline[5].MentalModel.ModelName)
+	assert.Equal(t, "debugging_approach", timeline[6].Tool)
+
+	_, err = store.SessionTimeline("missing")
+	assert.Error(t, err)
+}
+
+func TestImportSession_UpgradesLegacyExport(t *testing.T) {
+	store := newTestStorage(t)
+
+	// A 1.0.0 export predates session metadata and thought references
+	legacy := `{
+		"version": "1.0.0",
+		"timestamp": "2024-06-01T10:00:00Z",
+		"session_id": "legacy",
+		"session_type": "hybrid",
+		"data": {
+			"thoughts": [
+				{"id": "old-1", "thought": "Start", "thought_number": 1, "total_thoughts": 2, "next_thought_needed": true, "created_at": "2024-06-01T09:00:00Z"},
+				{"id": "old-2", "thought": "Finish", "thought_number": 2, "total_thoughts": 2, "next_thought_needed": false, "created_at": "2024-06-01T09:05:00Z"}
+			],
+			"mental_models": [
+				{"id": "old-m", "model_name": "inversion", "problem": "Avoid failure", "steps": ["Invert"], "reasoning": "", "conclusion": "Ship", "created_at": "2024-06-01T09:10:00Z"}
+			]
+		},
+		"metadata": {"exported_at": "2024-06-01T10:00:00Z", "version": "0.1.0"}
+	}`
+
+	sessionID, err := store.ImportSession([]byte(legacy))
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", sessionID)
+
+	thoughts, err := store.GetThoughts("legacy")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 2)
+	assert.Equal(t, "Start", thoughts[0].Thought)
+	assert.NotEqual(t, "old-1", thoughts[0].ID)
+	assert.Equal(t, time.Date(2024, 6, 1, 9, 5, 0, 0, time.UTC), thoughts[1].CreatedAt.UTC())
+
+	models, err := store.GetMentalModels("legacy")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "Ship", models[0].Conclusion)
+	assert.Len(t, store.ModelApplications("inversion"), 1)
+
+	stats, err := store.GetSessionStats("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.ThoughtCount)
+
+	// The migrated session exports in the current format
+	export, err := store.ExportSession("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, CurrentExportVersion, export.Version)
+	metadata, err := store.GetSessionMetadata("legacy")
+	require.NoError(t, err)
+	assert.Empty(t, metadata)
+}
+
+func TestImportSession_RoundTripsCurrentExport(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "original", "One", "Two")
+	require.NoError(t, store.AddMentalModel("original", &types.MentalModelData{ModelName: "first_principles", Problem: "Why"}))
+	require.NoError(t, store.SetSessionMetadata("original", "ticket", "GT-7"))
+
+	export, err := store.ExportSession("original")
+	require.NoError(t, err)
+	export.SessionID = "copy"
+	payload, err := json.Marshal(export)
+	require.NoError(t, err)
+
+	_, err = store.ImportSession(payload)
+	require.NoError(t, err)
+
+	thoughts, _ := store.GetThoughts("copy")
+	assert.Len(t, thoughts, 2)
+	models, _ := store.GetMentalModels("copy")
+	assert.Len(t, models, 1)
+	metadata, _ := store.GetSessionMetadata("copy")
+	assert.Equal(t, map[string]string{"ticket": "GT-7"}, metadata)
+
+	// The original is untouched and cannot be imported over
+	thoughts, _ = store.GetThoughts("original")
+	assert.Len(t, thoughts, 2)
+	export.SessionID = "original"
+	payload, _ = json.Marshal(export)
+	_, err = store.ImportSession(payload)
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestImportSession_RejectsNewerAndInvalidExports(t *testing.T) {
+	store := newTestStorage(t)
+
+	_, err := store.ImportSession([]byte(`{"version": "2.0.0", "session_id": "future", "data": {}}`))
+	assert.ErrorContains(t, err, "newer than the newest supported version "+CurrentExportVersion)
+
+	_, err = store.ImportSession([]byte(`{"version": "0.9.0", "session_id": "ancient", "data": {}}`))
+	assert.ErrorContains(t, err, "not supported")
+
+	_, err = store.ImportSession([]byte(`{"version": "one", "session_id": "bad", "data": {}}`))
+	assert.ErrorContains(t, err, "malformed version")
+
+	_, err = store.ImportSession([]byte(`{"version": "1.1.0", "session_id": "", "data": {}}`))
+	assert.Error(t, err)
+
+	_, err = store.ImportSession([]byte(`not json`))
+	assert.Error(t, err)
+
+	// A thought below the first thought number rejects the import entirely
+	_, err = store.ImportSession([]byte(`{"version": "1.1.0", "session_id": "partial", "data": {"thoughts": [{"thought": "ok", "thought_number": 1}, {"thought": "bad", "thought_number": 0}]}}`))
+	assert.Error(t, err)
+	_, err = store.GetSession("partial")
+	assert.Error(t, err)
+}
+
+func TestImportSession_RejectsOversizedPayload(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxImportBytes = 256
+
+	payload := fmt.Sprintf(`{"version": "1.1.0", "session_id": "huge", "data": {"thoughts": [{"thought": %q, "thought_number": 1}]}}`, strings.Repeat("x", 300))
+	_, err := store.ImportSession([]byte(payload))
+	assert.ErrorIs(t, err, ErrImportTooLarge)
+	_, err = store.GetSession("huge")
+	assert.Error(t, err)
+
+	// Rejected by size even when the body is not valid JSON
+	_, err = store.ImportSession([]byte(strings.Repeat("[", 300)))
+	assert.ErrorIs(t, err, ErrImportTooLarge)
+}
+
+func TestImportSession_RejectsTooManyEntities(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxImportEntities = 3
+
+	payload := `{"version": "1.1.0", "session_id": "crowded", "data": {
+		"thoughts": [{"thought": "a", "thought_number": 1}, {"thought": "b", "thought_number": 2}],
+		"mental_models": [{"model_name": "inversion"}, {"model_name": "first_principles"}]}}`
+	_, err := store.ImportSession([]byte(payload))
+	assert.ErrorIs(t, err, ErrImportTooLarge)
+
+	_, err = store.GetSession("crowded")
+	assert.Error(t, err)
+	thoughts, err := store.GetThoughts("crowded")
+	require.NoError(t, err)
+	assert.Empty(t, thoughts)
+	assert.Empty(t, store.ModelApplications("inversion"))
+}
+
+func TestVersionNewer(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		newer bool
+	}{
+		{"1.1.0", "1.0.0", true},
+		{"1.0.0", "1.1.0", false},
+		{"1.10.0", "1.9.0", true},
+		{"1.1", "1.1.0", false},
+		{"2", "1.9.9", true},
+	}
+	for _, tt := range tests {
+		newer, err := versionNewer(tt.a, tt.b)
+		require.NoError(t, err)
+		assert.Equal(t, tt.newer, newer, "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestAddThought_EvictsLeastRecentInactiveSessionsPastTotalLimit(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxTotalThoughts = 5
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "idle-oldest", "a1", "a2")
+	clock.Advance(time.Second)
+	addThoughts(t, store, "idle-newer", "b1", "b2")
+	clock.Advance(store.config.SessionTimeout)
+	idled, evicted := store.ReapSessions()
+	require.Len(t, idled, 2)
+	require.Empty(t, evicted)
+	addThoughts(t, store, "active", "c1")
+
+	// At the limit the next write evicts the least recently accessed idle session
+	require.NoError(t, store.AddThought("writer", &types.ThoughtData{Thought: "d1", ThoughtNumber: 1}))
+	_, err := store.GetSession("idle-oldest")
+	assert.Error(t, err)
+	_, err = store.GetSession("idle-newer")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, store.totalThoughts())
+
+	// Idle sessions go before active ones, even when the active one is older
+	require.NoError(t, store.AddThought("writer", &types.ThoughtData{Thought: "d2", ThoughtNumber: 2}))
+	require.NoError(t, store.AddThought("writer", &types.ThoughtData{Thought: "d3", ThoughtNumber: 3}))
+	_, err = store.GetSession("idle-newer")
+	assert.Error(t, err)
+	_, err = store.GetSession("active")
+	assert.NoError(t, err)
+
+	// With no idle sessions left writes are rejected and active sessions kept
+	require.NoError(t, store.AddThought("writer", &types.ThoughtData{Thought: "d4", ThoughtNumber: 4}))
+	err = store.AddThought("writer", &types.ThoughtData{Thought: "d5", ThoughtNumber: 5})
+	assert.ErrorIs(t, err, ErrTotalThoughtLimitReached)
+	_, err = store.GetSession("active")
+	assert.NoError(t, err)
+	thoughts, _ := store.GetThoughts("writer")
+	assert.Len(t, thoughts, 4)
+	assert.Equal(t, 5, store.totalThoughts())
+}
+
+func TestAddThought_EvictsTimedOutSessionsWithoutReaper(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ReaperInterval = 0
+	cfg.MaxTotalThoughts = 4
+	store, err := New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "stale", "a1", "a2", "a3")
+	clock.Advance(cfg.SessionTimeout)
+	addThoughts(t, store, "fresh", "b1")
+
+	// Past the cap the session untouched for SessionTimeout makes room, though
+	// no reaper ever marked it idle
+	addThoughts(t, store, "writer", "c1")
+	_, err = store.GetSession("stale")
+	assert.Error(t, err)
+	assert.Equal(t, 2, store.totalThoughts())
+
+	// Recently used sessions are kept
+	addThoughts(t, store, "writer2", "d1", "e1")
+	err = store.AddThought("writer2", &types.ThoughtData{Thought: "f1", ThoughtNumber: 3})
+	assert.ErrorIs(t, err, ErrTotalThoughtLimitReached)
+	_, err = store.GetSession("fresh")
+	assert.NoError(t, err)
+}
+
+func TestAddThought_RejectedWriteEvictsNothing(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxTotalThoughts = 3
+	store.config.MaxThoughtsPerSession = 1
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	addThoughts(t, store, "idle", "a1")
+	addThoughts(t, store, "full", "b1")
+	addThoughts(t, store, "archived", "c1")
+	require.NoError(t, store.ArchiveSession("archived"))
+	clock.Advance(store.config.SessionTimeout)
+	store.ReapSessions()
+
+	err := store.AddThought("full", &types.ThoughtData{Thought: "b2", ThoughtNumber: 2})
+	assert.ErrorIs(t, err, ErrThoughtLimitReached)
+	err = store.AddThought("archived", &types.ThoughtData{Thought: "c2", ThoughtNumber: 2})
+	assert.ErrorIs(t, err, ErrSessionArchived)
+	err = store.AddThought("numbered", &types.ThoughtData{Thought: "d0", ThoughtNumber: 0})
+	assert.Error(t, err)
+
+	_, err = store.GetSession("idle")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, store.totalThoughts())
+}
+
+func TestAddThought_ConcurrentWritersStayUnderTotalLimit(t *testing.T) {
+	const limit, idleSessions, writers = 10, 4, 40
+
+	store := newTestStorage(t)
+	store.config.MaxTotalThoughts = limit
+	clock := newFakeClock()
+	store.now = clock.Now
+
+	for i := 0; i < idleSessions; i++ {
+		addThoughts(t, store, fmt.Sprintf("idle-%d", i), "first", "second")
+	}
+	clock.Advance(store.config.SessionTimeout)
+	store.ReapSessions()
+
+	var wg sync.WaitGroup
+	var stored, rejected int64
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.AddThought(fmt.Sprintf("writer-%d", i), &types.ThoughtData{Thought: "racing", ThoughtNumber: 1})
+			switch {
+			case err == nil:
+				atomic.AddInt64(&stored, 1)
+			case errors.Is(err, ErrTotalThoughtLimitReached):
+				atomic.AddInt64(&rejected, 1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every idle session is evicted exactly once, freeing room for as many
+	// writers as the limit allows and no more
+	for i := 0; i < idleSessions; i++ {
+		_, err := store.GetSession(fmt.Sprintf("idle-%d", i))
+		assert.Error(t, err)
+	}
+	assert.EqualValues(t, limit, stored)
+	assert.EqualValues(t, writers-limit, rejected)
+	assert.Equal(t, limit, store.totalThoughts())
+	assert.Zero(t, atomic.LoadInt64(&store.reservedThoughts))
+}
+
+func TestDeleteSessions_ByEachFilter(t *testing.T) {
+	setup := func(t *testing.T) (*Storage, *fakeClock) {
+		store := newTestStorage(t)
+		clock := newFakeClock()
+		store.now = clock.Now
+
+		addThoughts(t, store, "test-old", "Old test thought")
+		clock.Advance(store.config.SessionTimeout)
+		store.ReapSessions()
+		addThoughts(t, store, "test-new", "New test thought")
+		addThoughts(t, store, "prod-new", "Production thought")
+		_, err := store.TagSession("prod-new", []string{"keep"})
+		require.NoError(t, err)
+		_, err = store.TagSession("test-new", []string{"scratch"})
+		require.NoError(t, err)
+		return store, clock
+	}
+	surviving := func(store *Storage) []string {
+		sessionIDs := store.sessionIDs()
+		sort.Strings(sessionIDs)
+		return sessionIDs
+	}
+
+	tests := []struct {
+		name    string
+		filter  SessionFilter
+		deleted []string
+	}{
+		{"inactive", SessionFilter{Inactive: true}, []string{"test-old"}},
+		{"older than", SessionFilter{OlderThan: time.Minute}, []string{"test-old"}},
+		{"tag", SessionFilter{Tag: "scratch"}, []string{"test-new"}},
+		{"id prefix", SessionFilter{IDPrefix: "test-"}, []string{"test-new", "test-old"}},
+		{"combined", SessionFilter{IDPrefix: "test-", Inactive: true}, []string{"test-old"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := setup(t)
+			all := surviving(store)
+
+			result, err := store.DeleteSessions(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.deleted, result.Purged)
+			assert.Equal(t, len(tt.deleted), result.ItemsRemoved)
+			assert.Positive(t, result.ReclaimedBytes)
+
+			var expected []string
+			for _, sessionID := range all {
+				if !containsString(tt.deleted, sessionID) {
+					expected = append(expected, sessionID)
+				}
+			}
+			assert.Equal(t, expected, surviving(store))
+		})
+	}
+
+	store, _ := setup(t)
+	_, err := store.DeleteSessions(SessionFilter{})
+	assert.Error(t, err)
+	assert.Len(t, store.sessionIDs(), 3)
+}
+
+func TestCheckpoints_RestoreMatchesSnapshot(t *testing.T) {
+	store := newTestStorage(t)
+	store.config.MaxCheckpointsPerSession = 2
+
+	addThoughts(t, store, "investigation", "Hypothesis", "Evidence")
+	require.NoError(t, store.AddMentalModel("investigation", &types.MentalModelData{ModelName: "inversion", Steps: []string{"Invert"}}))
+	thoughts, _ := store.GetThoughts("investigation")
+	_, err := store.AnnotateThought("investigation", thoughts[0].ID, []types.Reference{{Type: types.ReferenceIssue, Value: "GT-9"}})
+	require.NoError(t, err)
+
+	export, err := store.ExportSession("investigation")
+	require.NoError(t, err)
+	before, err := json.Marshal(export.Data)
+	require.NoError(t, err)
+	checkpoint, err := store.CheckpointSession("investigation", "baseline")
+	require.NoError(t, err)
+	assert.Equal(t, 2, checkpoint.ThoughtCount)
+	assert.Equal(t, 1, checkpoint.MentalModelCount)
+
+	// Mutate after the checkpoint, including objects the snapshot was taken from
+	thoughts[0].Thought = "Rewritten"
+	addThoughts(t, store, "investigation", "Dead end")
+	require.NoError(t, store.AddMentalModel("investigation", &types.MentalModelData{ModelName: "second_order"}))
+
+	_, err = store.RestoreCheckpoint("investigation", "baseline")
+	require.NoError(t, err)
+
+	export, err = store.ExportSession("investigation")
+	require.NoError(t, err)
+	after, err := json.Marshal(export.Data)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(after))
+	thoughts, _ = store.GetThoughts("investigation")
+	require.Len(t, thoughts, 2)
+	assert.Equal(t, "Hypothesis", thoughts[0].Thought)
+	stats, _ := store.GetSessionStats("investigation")
+	assert.Equal(t, 2, stats.ThoughtCount)
+	assert.Empty(t, store.ModelApplications("second_order"))
+	assert.Len(t, store.ModelApplications("inversion"), 1)
+
+	// Restored state is a copy, so the checkpoint can be restored again
+	thoughts[0].Thought = "Changed again"
+	_, err = store.RestoreCheckpoint("investigation", "baseline")
+	require.NoError(t, err)
+	thoughts, _ = store.GetThoughts("investigation")
+	assert.Equal(t, "Hypothesis", thoughts[0].Thought)
+
+	// Names are unique and checkpoints are capped
+	_, err = store.CheckpointSession("investigation", "baseline")
+	assert.Error(t, err)
+	_, err = store.CheckpointSession("investigation", "second")
+	require.NoError(t, err)
+	_, err = store.CheckpointSession("investigation", "third")
+	assert.ErrorContains(t, err, "checkpoint limit reached")
+
+	checkpoints, err := store.ListCheckpoints("investigation")
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 2)
+	assert.Equal(t, "baseline", checkpoints[0].Name)
+
+	_, err = store.RestoreCheckpoint("investigation", "missing")
+	assert.Error(t, err)
+	_, err = store.CheckpointSession("missing", "baseline")
+	assert.Error(t, err)
+}
+
+func newPersistentStorage(t *testing.T, path string) *Storage {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.EnablePersistence = true
+	cfg.PersistencePath = path
+	store, err := New(cfg)
+	require.NoError(t, err)
+	return store
+}
+
+func TestPersistence_SessionSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gothink.db")
+
+	store := newPersistentStorage(t, path)
+	_, err := store.CreateSession("durable")
+	require.NoError(t, err)
+	addThoughts(t, store, "durable", "One", "Two")
+	require.NoError(t, store.AddMentalModel("durable", &types.MentalModelData{
+		ModelName: "first_principles", Problem: "Build or buy", Steps: []string{"Identify"}, Conclusion: "Buy",
+	}))
+	require.NoError(t, store.SetSessionMetadata("durable", "owner", "team-a"))
+	_, err = store.TagSession("durable", []string{"review"})
+	require.NoError(t, err)
+	require.NoError(t, store.ArchiveSession("durable"))
+
+	addThoughts(t, store, "discarded", "Gone")
+	_, err = store.DeleteSessions(SessionFilter{IDPrefix: "discarded"})
+	require.NoError(t, err)
+	store.Close()
+
+	reopened := newPersistentStorage(t, path)
+	defer reopened.Close()
+
+	thoughts, err := reopened.GetThoughts("durable")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 2)
+	assert.Equal(t, "One", thoughts[0].Thought)
+	assert.Equal(t, "Two", thoughts[1].Thought)
+
+	models, err := reopened.GetMentalModels("durable")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "Buy", models[0].Conclusion)
+	assert.Equal(t, []string{"Identify"}, models[0].Steps)
+	assert.Len(t, reopened.ModelApplications("first_principles"), 1)
+
+	stats, err := reopened.GetSessionStats("durable")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.ThoughtCount)
+	assert.True(t, stats.Archived)
+
+	metadata, err := reopened.GetSessionMetadata("durable")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, metadata)
+	tagged, err := reopened.SessionsByTag([]string{"review"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"durable"}, tagged)
+
+	// Deleted sessions stay deleted and archived ones stay read-only
+	_, err = reopened.GetSession("discarded")
+	assert.Error(t, err)
+	err = reopened.AddThought("durable", &types.ThoughtData{Thought: "Late", ThoughtNumber: 3})
+	assert.ErrorIs(t, err, ErrSessionArchived)
+}
+
+func TestPersistence_EntityChangesSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gothink.db")
+
+	store := newPersistentStorage(t, path)
+	addThoughts(t, store, "edited", "One", "Two")
+	_, err := store.TagThoughts("edited", ThoughtFilter{}, "reviewed")
+	require.NoError(t, err)
+	_, err = store.CheckpointSession("edited", "before")
+	require.NoError(t, err)
+	addThoughts(t, store, "edited", "Three")
+	_, err = store.RestoreCheckpoint("edited", "before")
+	require.NoError(t, err)
+	store.Close()
+
+	reopened := newPersistentStorage(t, path)
+	defer reopened.Close()
+
+	thoughts, err := reopened.GetThoughts("edited")
+	require.NoError(t, err)
+	require.Len(t, thoughts, 2)
+	for _, thought := range thoughts {
+		assert.Equal(t, []string{"reviewed"}, thought.Tags)
+	}
+}
+
+func TestPersistence_RequiresPath(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.EnablePersistence = true
+	_, err := New(cfg)
+	assert.Error(t, err)
+}
+
+func TestLockContention_CountsWaitingWrites(t *testing.T) {
+	store := newTestStorage(t)
+	addThoughts(t, store, "busy", "uncontended")
+	assert.Equal(t, uint64(0), store.LockContention())
+
+	// Hold the session lock so concurrent writers must wait for it
+	unlock := store.lockSession("busy")
+	var writers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		writers.Add(1)
+		go func(i int) {
+			defer writers.Done()
+			assert.NoError(t, store.AddThought("busy", &types.ThoughtData{Thought: "waiting", ThoughtNumber: i + 2, TotalThoughts: 5}))
+		}(i)
+	}
+
+	assert.Eventually(t, func() bool { return store.LockContention() == 4 }, time.Second, time.Millisecond)
+	unlock()
+	writers.Wait()
+
+	assert.Equal(t, uint64(4), store.GetGlobalStats().LockContention)
+	stats, err := store.GetSessionStats("busy")
+	require.NoError(t, err)
+	assert.Equal(t, 5, stats.ThoughtCount)
+}
+
+func TestModelCooldown_RejectsRapidReapplication(t *testing.T) {
+	store := newTestStorage(t)
+	clock := newFakeClock()
+	store.now = clock.Now
+	store.config.ModelCooldown = time.Minute
+
+	first := &types.MentalModelData{ModelName: "first_principles", Problem: "Scope"}
+	require.NoError(t, store.AddMentalModel("cooling", first))
+
+	clock.Advance(30 * time.Second)
+	err := store.AddMentalModel("cooling", &types.MentalModelData{ModelName: "first_principles", Problem: " Scope "})
+	require.ErrorIs(t, err, ErrModelCooldown)
+	var cooldown *CooldownError
+	require.ErrorAs(t, err, &cooldown)
+	assert.Equal(t, first.ID, cooldown.PriorID)
+	assert.Equal(t, 30*time.Second, cooldown.RetryAfter)
+
+	// A different problem or model is not a duplicate
+	require.NoError(t, store.AddMentalModel("cooling", &types.MentalModelData{ModelName: "first_principles", Problem: "Budget"}))
+	require.NoError(t, store.AddMentalModel("cooling", &types.MentalModelData{ModelName: "rubber_duck", Problem: "Scope"}))
+
+	clock.Advance(30 * time.Second)
+	require.NoError(t, store.AddMentalModel("cooling", &types.MentalModelData{ModelName: "first_principles", Problem: "Scope"}))
+
+	models, err := store.GetMentalModels("cooling")
+	require.NoError(t, err)
+	assert.Len(t, models, 4)
+}
+
+func TestModelCooldown_OffByDefault(t *testing.T) {
+	store := newTestStorage(t)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, store.AddMentalModel("warm", &types.MentalModelData{ModelName: "first_principles", Problem: "Scope"}))
+	}
+}
+
+func TestSessionLocks_DroppedWhenUnused(t *testing.T) {
+	store := newTestStorage(t)
+	lockCount := func() int {
+		count := 0
+		for _, sh := range store.shards {
+			sh.sessionLocks.mu.Lock()
+			count += len(sh.sessionLocks.locks)
+			sh.sessionLocks.mu.Unlock()
+		}
+		return count
+	}
+
+	// Lookups of unknown sessions leave nothing behind
+	for i := 0; i < 100; i++ {
+		_, err := store.GetSessionMetadata(fmt.Sprintf("missing-%d", i))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 0, lockCount())
+
+	addThoughts(t, store, "kept", "one", "two")
+	_, err := store.ClearSession("kept")
+	require.NoError(t, err)
+	assert.Equal(t, 0, lockCount())
+
+	// A held lock stays shared with waiters until the last one releases it
+	unlock := store.lockSession("busy")
+	acquired := make(chan struct{})
+	go func() {
+		release := store.lockSession("busy")
+		close(acquired)
+		release()
+	}()
+	assert.Eventually(t, func() bool { return store.LockContention() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, lockCount())
+	unlock()
+	<-acquired
+	assert.Eventually(t, func() bool { return lockCount() == 0 }, time.Second, time.Millisecond)
+}
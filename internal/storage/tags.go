@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// tagIndex maps each tag to the sessions carrying it, so sessions can be
+// found by tag without scanning every session
+type tagIndex struct {
+	mu       sync.RWMutex
+	sessions map[string]map[string]struct{}
+}
+
+// newTagIndex creates an empty tag index
+func newTagIndex() *tagIndex {
+	return &tagIndex{sessions: make(map[string]map[string]struct{})}
+}
+
+// add indexes a session under the given tags
+func (t *tagIndex) add(sessionID string, tags ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tag := range tags {
+		if t.sessions[tag] == nil {
+			t.sessions[tag] = make(map[string]struct{})
+		}
+		t.sessions[tag][sessionID] = struct{}{}
+	}
+}
+
+// remove drops a session from the given tags
+func (t *tagIndex) remove(sessionID string, tags ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tag := range tags {
+		delete(t.sessions[tag], sessionID)
+		if len(t.sessions[tag]) == 0 {
+			delete(t.sessions, tag)
+		}
+	}
+}
+
+// match returns the sessions carrying all of the tags when matchAll is set,
+// or any of them otherwise
+func (t *tagIndex) match(tags []string, matchAll bool) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, tag := range tags {
+		for sessionID := range t.sessions[tag] {
+			counts[sessionID]++
+		}
+	}
+
+	var sessionIDs []string
+	for sessionID, count := range counts {
+		if !matchAll || count == len(tags) {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+	return sessionIDs
+}
+
+// normalizeTags trims tags, drops duplicates and rejects empty ones
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("tags must not be empty")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("at least one tag is required")
+	}
+	return normalized, nil
+}
+
+// TagSession adds tags to a session, ignoring tags it already carries, and
+// returns the session's tags
+func (s *Storage) TagSession(sessionID string, tags []string) ([]string, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session := s.getSession(sessionID)
+	if session.Archived {
+		return nil, fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	for _, tag := range tags {
+		if !containsString(session.Tags, tag) {
+			session.Tags = append(session.Tags, tag)
+		}
+	}
+	s.tags.add(sessionID, tags...)
+	s.touchSession(session)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"tags":       tags,
+	}).Debug("Tagged session")
+
+	return append([]string{}, session.Tags...), nil
+}
+
+// UntagSession removes tags from a session, ignoring tags it does not carry,
+// and returns the session's remaining tags
+func (s *Storage) UntagSession(sessionID string, tags []string) ([]string, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Archived {
+		return nil, fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	remaining := session.Tags[:0]
+	for _, tag := range session.Tags {
+		if !containsString(tags, tag) {
+			remaining = append(remaining, tag)
+		}
+	}
+	session.Tags = remaining
+	s.tags.remove(sessionID, tags...)
+	s.touchSession(session)
+
+	return append([]string{}, session.Tags...), nil
+}
+
+// SessionsByTag returns the IDs of sessions carrying all of the tags when
+// matchAll is set, or any of them otherwise, most recently accessed first
+func (s *Storage) SessionsByTag(tags []string, matchAll bool) ([]string, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	lastAccessed := make(map[string]time.Time)
+	for _, sessionID := range s.tags.match(tags, matchAll) {
+		unlock := s.lockSession(sessionID)
+		if session, err := s.GetSession(sessionID); err == nil {
+			lastAccessed[sessionID] = session.LastAccessedAt
+		}
+		unlock()
+	}
+
+	sessionIDs := make([]string, 0, len(lastAccessed))
+	for sessionID := range lastAccessed {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	sort.Slice(sessionIDs, func(i, j int) bool {
+		a, b := lastAccessed[sessionIDs[i]], lastAccessed[sessionIDs[j]]
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return sessionIDs[i] < sessionIDs[j]
+	})
+
+	return sessionIDs, nil
+}
+
+// TagThoughts adds a tag to every thought in a session matching the filter
+// and returns how many thoughts were newly tagged; matching thoughts that
+// already carry the tag are left unchanged
+func (s *Storage) TagThoughts(sessionID string, filter ThoughtFilter, tag string) (int, error) {
+	tags, err := normalizeTags([]string{tag})
+	if err != nil {
+		return 0, err
+	}
+	tag = tags[0]
+
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if session.Archived {
+		return 0, fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	sh := s.shardFor(sessionID)
+	sh.thoughtsMutex.Lock()
+	defer sh.thoughtsMutex.Unlock()
+
+	var tagged []*types.ThoughtData
+	for _, id := range sh.sessionThoughts[sessionID] {
+		thought, exists := sh.thoughts[id]
+		if !exists || !filter.matches(thought) || containsString(thought.Tags, tag) {
+			continue
+		}
+		// Build a new slice so copies of the thought never share its tags
+		thought.Tags = append(append([]string{}, thought.Tags...), tag)
+		tagged = append(tagged, thought)
+	}
+	if len(tagged) > 0 {
+		s.persistThoughts(tagged...)
+		s.touchSession(session)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"tag":        tag,
+		"tagged":     len(tagged),
+	}).Debug("Tagged thoughts")
+
+	return len(tagged), nil
+}
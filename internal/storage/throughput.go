@@ -0,0 +1,39 @@
+package storage
+
+import "time"
+
+// throughputWindowMinutes is the width of the sliding window used for rates
+const throughputWindowMinutes = 5
+
+// throughputCounter counts events in a ring buffer of per-minute buckets
+type throughputCounter struct {
+	counts  [throughputWindowMinutes]int
+	minutes [throughputWindowMinutes]int64
+}
+
+// add records one event at the given time
+func (c *throughputCounter) add(now time.Time) {
+	minute := now.Unix() / 60
+	idx := minute % throughputWindowMinutes
+
+	// Reuse the bucket once its minute has rolled out of the window
+	if c.minutes[idx] != minute {
+		c.minutes[idx] = minute
+		c.counts[idx] = 0
+	}
+	c.counts[idx]++
+}
+
+// perMinute returns the average events per minute over the window ending at now
+func (c *throughputCounter) perMinute(now time.Time) float64 {
+	minute := now.Unix() / 60
+
+	total := 0
+	for i := range c.counts {
+		if age := minute - c.minutes[i]; age >= 0 && age < throughputWindowMinutes {
+			total += c.counts[i]
+		}
+	}
+
+	return float64(total) / throughputWindowMinutes
+}
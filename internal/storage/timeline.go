@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// SessionTimeline merges a session's creation, thoughts, mental models and
+// audited tool calls into one list ordered by time. Entries at the same
+// instant keep that order, so an entity precedes the tool call that stored
+// it. Debugging approaches appear as mental models named with
+// types.DebuggingApproachPrefix.
+func (s *Storage) SessionTimeline(sessionID string) ([]types.TimelineEvent, error) {
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	thoughts, err := s.GetThoughts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	mentalModels, err := s.GetMentalModels(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := s.GetSessionAudit(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := make([]types.TimelineEvent, 0, 1+len(thoughts)+len(mentalModels)+len(audit))
+	timeline = append(timeline, types.TimelineEvent{Kind: types.TimelineSessionCreated, At: session.CreatedAt})
+	for _, thought := range thoughts {
+		timeline = append(timeline, types.TimelineEvent{Kind: types.TimelineThought, At: thought.CreatedAt, Thought: thought})
+	}
+	for _, model := range mentalModels {
+		timeline = append(timeline, types.TimelineEvent{Kind: types.TimelineMentalModel, At: model.CreatedAt, MentalModel: model})
+	}
+	for _, entry := range audit {
+		timeline = append(timeline, types.TimelineEvent{Kind: types.TimelineToolCall, At: entry.At, Tool: entry.Tool})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].At.Before(timeline[j].At)
+	})
+
+	return timeline, nil
+}
+
+// SessionDuration measures a session from its thoughts' creation times: the
+// wall-clock span from first to last thought, the average interval between
+// consecutive thoughts, and the active time, which sums those intervals but
+// skips any longer than SessionTimeout as idle.
+func (s *Storage) SessionDuration(sessionID string) (*types.SessionDuration, error) {
+	if _, err := s.GetSession(sessionID); err != nil {
+		return nil, err
+	}
+	thoughts, err := s.GetThoughts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := &types.SessionDuration{SessionID: sessionID, ThoughtCount: len(thoughts)}
+	if len(thoughts) == 0 {
+		return duration, nil
+	}
+
+	times := make([]time.Time, len(thoughts))
+	for i, thought := range thoughts {
+		times[i] = thought.CreatedAt
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	first, last := times[0], times[len(times)-1]
+	duration.FirstThoughtAt = &first
+	duration.LastThoughtAt = &last
+	wallClock := last.Sub(first)
+	duration.WallClockSeconds = wallClock.Seconds()
+
+	if len(times) > 1 {
+		duration.AverageIntervalSeconds = wallClock.Seconds() / float64(len(times)-1)
+	}
+
+	var active time.Duration
+	for i := 1; i < len(times); i++ {
+		gap := times[i].Sub(times[i-1])
+		if s.config.SessionTimeout <= 0 || gap <= s.config.SessionTimeout {
+			active += gap
+		}
+	}
+	duration.ActiveSeconds = active.Seconds()
+
+	return duration, nil
+}
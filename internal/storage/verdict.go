@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// SetSessionVerdict records a session's overall conclusion, replacing any
+// earlier verdict. Confidence is a fraction between 0 and 1.
+func (s *Storage) SetSessionVerdict(sessionID string, verdict *types.SessionVerdict) error {
+	if strings.TrimSpace(verdict.Conclusion) == "" {
+		return fmt.Errorf("verdict conclusion must not be empty")
+	}
+	if verdict.Confidence < 0 || verdict.Confidence > 1 {
+		return fmt.Errorf("verdict confidence %g is outside 0-1", verdict.Confidence)
+	}
+
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Archived {
+		return fmt.Errorf("session %s is %w", sessionID, ErrSessionArchived)
+	}
+
+	stored := *verdict
+	stored.SetAt = s.now()
+	session.Verdict = &stored
+	s.touchSession(session)
+
+	return nil
+}
+
+// GetSessionVerdict returns a copy of a session's verdict, or nil when none
+// has been set
+func (s *Storage) GetSessionVerdict(sessionID string) (*types.SessionVerdict, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Verdict == nil {
+		return nil, nil
+	}
+
+	verdict := *session.Verdict
+	return &verdict, nil
+}
@@ -0,0 +1,172 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateConfig represents the YAML configuration for session templates
+type TemplateConfig struct {
+	Templates map[string]SessionTemplate `yaml:"templates"`
+}
+
+// SessionTemplate represents a named starting point for a new session
+type SessionTemplate struct {
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description" json:"description"`
+	Thoughts    []string        `yaml:"thoughts" json:"thoughts"`
+	Models      []TemplateModel `yaml:"models" json:"models"`
+	// Source is the file the template was loaded from
+	Source string `yaml:"-" json:"source"`
+}
+
+// TemplateModel represents a mental model pre-applied when a template is instantiated
+type TemplateModel struct {
+	ModelName string `yaml:"model_name" json:"model_name"`
+	Problem   string `yaml:"problem" json:"problem"`
+}
+
+// Loader handles loading session templates
+type Loader struct {
+	logger *logrus.Logger
+}
+
+// NewLoader creates a new session templates loader
+func NewLoader(logger *logrus.Logger) *Loader {
+	return &Loader{
+		logger: logger,
+	}
+}
+
+// LoadTemplates loads session templates from a YAML file or directory
+func (l *Loader) LoadTemplates(path string) (map[string]SessionTemplate, error) {
+	templates := make(map[string]SessionTemplate)
+	if path == "" {
+		return templates, nil
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("session templates path does not exist: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat session templates path %s: %w", path, err)
+	}
+
+	if info.IsDir() {
+		err := filepath.WalkDir(path, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+				fileTemplates, err := l.loadTemplatesFromFile(path)
+				if err != nil {
+					l.logger.Warnf("Failed to load session templates from %s: %v", path, err)
+					return nil // Continue loading other files
+				}
+				for k, v := range fileTemplates {
+					templates[k] = v
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk directory: %w", err)
+		}
+	} else {
+		templates, err = l.loadTemplatesFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l.logger.Infof("Loaded %d session templates", len(templates))
+
+	return templates, nil
+}
+
+// loadTemplatesFromFile loads session templates from a single YAML file
+func (l *Loader) loadTemplatesFromFile(filePath string) (map[string]SessionTemplate, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session templates file: %w", err)
+	}
+
+	var config TemplateConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse session templates YAML: %w", err)
+	}
+
+	if err := l.validateTemplates(config.Templates); err != nil {
+		return nil, fmt.Errorf("invalid session templates configuration: %w", err)
+	}
+
+	for key, template := range config.Templates {
+		template.Source = filePath
+		config.Templates[key] = template
+	}
+
+	return config.Templates, nil
+}
+
+// TemplateSummary describes a session template without its contents
+type TemplateSummary struct {
+	Key          string `json:"key"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	ThoughtCount int    `json:"thought_count"`
+	ModelCount   int    `json:"model_count"`
+	Source       string `json:"source"`
+}
+
+// Summarize lists templates sorted by key with the number of thoughts and
+// mental models each seeds
+func Summarize(templates map[string]SessionTemplate) []TemplateSummary {
+	summaries := make([]TemplateSummary, 0, len(templates))
+	for key, template := range templates {
+		summaries = append(summaries, TemplateSummary{
+			Key:          key,
+			Name:         template.Name,
+			Description:  template.Description,
+			ThoughtCount: len(template.Thoughts),
+			ModelCount:   len(template.Models),
+			Source:       template.Source,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Key < summaries[j].Key })
+	return summaries
+}
+
+// validateTemplates validates the session templates configuration
+func (l *Loader) validateTemplates(templates map[string]SessionTemplate) error {
+	for key, template := range templates {
+		if strings.TrimSpace(template.Name) == "" {
+			return fmt.Errorf("template '%s' has empty name", key)
+		}
+		if len(template.Thoughts) == 0 && len(template.Models) == 0 {
+			return fmt.Errorf("template '%s' has no thoughts or models", key)
+		}
+
+		for i, thought := range template.Thoughts {
+			if strings.TrimSpace(thought) == "" {
+				return fmt.Errorf("template '%s' has empty thought at index %d", key, i)
+			}
+		}
+		for i, model := range template.Models {
+			if strings.TrimSpace(model.ModelName) == "" {
+				return fmt.Errorf("template '%s' has empty model name at index %d", key, i)
+			}
+			if strings.TrimSpace(model.Problem) == "" {
+				return fmt.Errorf("template '%s' has empty problem at index %d", key, i)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTemplates_EmptyPath(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	templates, err := loader.LoadTemplates("")
+
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+}
+
+func TestLoadTemplates_ValidFile(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	yamlContent := `
+templates:
+  incident_review:
+    name: "Incident Review"
+    description: "Standard incident investigation checklist"
+    thoughts:
+      - "What is the observed symptom?"
+      - "When did it start?"
+    models:
+      - model_name: "first_principles"
+        problem: "Why did the incident happen?"
+`
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "templates.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yamlContent), 0644))
+
+	templates, err := loader.LoadTemplates(path)
+
+	require.NoError(t, err)
+	require.Contains(t, templates, "incident_review")
+
+	template := templates["incident_review"]
+	assert.Equal(t, "Incident Review", template.Name)
+	assert.Equal(t, path, template.Source)
+	assert.Len(t, template.Thoughts, 2)
+	require.Len(t, template.Models, 1)
+	assert.Equal(t, "first_principles", template.Models[0].ModelName)
+}
+
+func TestLoadTemplates_NotExists(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	templates, err := loader.LoadTemplates("/nonexistent/templates.yaml")
+
+	require.Error(t, err)
+	assert.Nil(t, templates)
+	assert.Contains(t, err.Error(), "does not exist")
+}
+
+func TestLoadTemplates_StatErrorReturned(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	// A path below a regular file fails to stat without being missing
+	file := filepath.Join(t.TempDir(), "templates.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("templates: {}"), 0644))
+
+	templates, err := loader.LoadTemplates(filepath.Join(file, "nested.yaml"))
+
+	require.Error(t, err)
+	assert.Nil(t, templates)
+	assert.Contains(t, err.Error(), "failed to stat")
+}
+
+func TestValidateTemplates(t *testing.T) {
+	loader := NewLoader(logrus.New())
+
+	tests := []struct {
+		name      string
+		templates map[string]SessionTemplate
+		errMsg    string
+	}{
+		{
+			name:      "empty name",
+			templates: map[string]SessionTemplate{"t": {Thoughts: []string{"one"}}},
+			errMsg:    "empty name",
+		},
+		{
+			name:      "no content",
+			templates: map[string]SessionTemplate{"t": {Name: "T"}},
+			errMsg:    "no thoughts or models",
+		},
+		{
+			name:      "empty thought",
+			templates: map[string]SessionTemplate{"t": {Name: "T", Thoughts: []string{"one", " "}}},
+			errMsg:    "empty thought at index 1",
+		},
+		{
+			name: "empty problem",
+			templates: map[string]SessionTemplate{"t": {
+				Name:   "T",
+				Models: []TemplateModel{{ModelName: "first_principles"}},
+			}},
+			errMsg: "empty problem at index 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loader.validateTemplates(tt.templates)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
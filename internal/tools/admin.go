@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/jsonstyle"
+	"github.com/rainmana/gothink/internal/models"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// AddAdminTools registers operator tools; they are only available when
+// admin tools are enabled in the configuration
+func AddAdminTools(s *server.MCPServer, store *storage.Storage, modelsLoader *models.Loader, cfg *config.Config) {
+	if !cfg.EnableAdminTools {
+		return
+	}
+
+	// Purge Inactive Sessions Tool
+	s.AddTool(
+		mcp.NewTool("purge_inactive",
+			mcp.WithDescription("Immediately evict every session idle for at least the given duration, reporting the memory reclaimed"),
+			mcp.WithString("idle_for", mcp.Required(), mcp.Description("Minimum idle time as a Go duration, e.g. \"30m\" or \"2h\"")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			idleFor, _ := req.RequireString("idle_for")
+
+			threshold, err := time.ParseDuration(idleFor)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid idle_for duration: %v", err)), nil
+			}
+
+			purge, err := store.PurgeInactive(threshold)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to purge sessions: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":          "success",
+				"purged":          purge.Purged,
+				"count":           len(purge.Purged),
+				"items_removed":   purge.ItemsRemoved,
+				"reclaimed_bytes": purge.ReclaimedBytes,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Delete Sessions Tool
+	s.AddTool(
+		mcp.NewTool("delete_sessions",
+			mcp.WithDescription("Delete every session matching all of the given filters, reporting the count and memory reclaimed; requires confirm"),
+			mcp.WithBoolean("inactive", mcp.Description("Only delete sessions marked idle")),
+			mcp.WithString("older_than", mcp.Description("Only delete sessions created at least this long ago, as a Go duration, e.g. \"24h\"")),
+			mcp.WithString("tag", mcp.Description("Only delete sessions carrying this tag")),
+			mcp.WithString("id_prefix", mcp.Description("Only delete sessions whose ID starts with this prefix")),
+			mcp.WithBoolean("confirm", mcp.Required(), mcp.Description("Must be true to delete anything")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !req.GetBool("confirm", false) {
+				return mcp.NewToolResultError("Refusing to delete sessions without confirm=true"), nil
+			}
+
+			filter := storage.SessionFilter{
+				Inactive: req.GetBool("inactive", false),
+				Tag:      req.GetString("tag", ""),
+				IDPrefix: req.GetString("id_prefix", ""),
+			}
+			if olderThan := req.GetString("older_than", ""); olderThan != "" {
+				threshold, err := time.ParseDuration(olderThan)
+				if err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("Invalid older_than duration: %v", err)), nil
+				}
+				filter.OlderThan = threshold
+			}
+
+			deletion, err := store.DeleteSessions(filter)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to delete sessions: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":          "success",
+				"deleted":         deletion.Purged,
+				"count":           len(deletion.Purged),
+				"items_removed":   deletion.ItemsRemoved,
+				"reclaimed_bytes": deletion.ReclaimedBytes,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Reload Models Tool
+	s.AddTool(
+		mcp.NewTool("reload_models",
+			mcp.WithDescription("Reload only the mental models from the configured models path, leaving other settings and sessions untouched; a failed reload keeps the current set"),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			// Pick up an edited models path without applying any other change
+			reloadCfg := *cfg
+			if latest, err := config.Load(); err == nil {
+				reloadCfg.MentalModelsPath = latest.MentalModelsPath
+			}
+
+			reload, err := modelsLoader.Reload(&reloadCfg)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to reload mental models, keeping current set: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":      "success",
+				"models_path": reloadCfg.MentalModelsPath,
+				"added":       reload.Added,
+				"removed":     reload.Removed,
+				"changed":     reload.Changed,
+				"total":       reload.Total,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Config Diff Tool
+	s.AddTool(
+		mcp.NewTool("config_diff",
+			mcp.WithDescription("Report the settings of the running server that differ from the defaults, with secrets redacted"),
+			mcp.WithReadOnlyHintAnnotation(true),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			diff := config.Diff(config.DefaultConfig(), cfg)
+
+			response := map[string]interface{}{
+				"status":  "success",
+				"changed": len(diff),
+				"diff":    diff,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Model Applications Tool
+	s.AddTool(
+		mcp.NewTool("model_applications",
+			mcp.WithDescription("List every application of a mental model across all sessions, oldest first, with problems and conclusions"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("model_name", mcp.Required(), mcp.Description("Name of the mental model")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			modelName, _ := req.RequireString("model_name")
+
+			applications := store.ModelApplications(modelName)
+			sessions := make(map[string]bool)
+			for i := range applications {
+				sessions[applications[i].SessionID] = true
+				applications[i].Confidence = types.ScaleConfidence(applications[i].Confidence, cfg.ConfidenceScale)
+			}
+
+			response := map[string]interface{}{
+				"status":           "success",
+				"model_name":       modelName,
+				"applications":     applications,
+				"count":            len(applications),
+				"session_count":    len(sessions),
+				"confidence_scale": cfg.ConfidenceScale,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rainmana/gothink/internal/jsonstyle"
+	"github.com/rainmana/gothink/internal/storage"
+)
+
+// checkArgumentLength rejects a string argument longer than its configured limit
+func checkArgumentLength(name, value string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	if length := utf8.RuneCountInString(value); length > limit {
+		return fmt.Errorf("argument '%s' exceeds maximum length of %d characters by %d", name, limit, length-limit)
+	}
+	return nil
+}
+
+// truncationMarker is appended to arguments cut to their configured limit
+const truncationMarker = " [truncated]"
+
+// truncateArgument cuts a string argument to its configured limit, appending
+// truncationMarker, and reports whether anything was cut
+func truncateArgument(value string, limit int) (string, bool) {
+	if limit <= 0 || utf8.RuneCountInString(value) <= limit {
+		return value, false
+	}
+	return string([]rune(value)[:limit]) + truncationMarker, true
+}
+
+// checkSteps applies the per-call step count limit and the step length limit
+// to a steps argument, naming the bound that was exceeded
+func checkSteps(steps []string, maxSteps, maxStepLength int) error {
+	if maxSteps > 0 && len(steps) > maxSteps {
+		return fmt.Errorf("argument 'steps' exceeds maximum of %d steps per call by %d", maxSteps, len(steps)-maxSteps)
+	}
+	for i, step := range steps {
+		if err := checkArgumentLength(fmt.Sprintf("steps[%d]", i), step, maxStepLength); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errorCodeThoughtLimitReached marks tool failures caused by a session
+// reaching its thought limit
+const errorCodeThoughtLimitReached = "thought_limit_reached"
+
+// toolErrorWithCode reports a tool failure as a JSON object carrying a
+// machine-readable code alongside the message
+func toolErrorWithCode(code string, err error, fieldStyle string) *mcp.CallToolResult {
+	response := map[string]interface{}{
+		"status":  "error",
+		"code":    code,
+		"message": err.Error(),
+	}
+
+	result, _ := jsonstyle.Marshal(response, fieldStyle)
+	return mcp.NewToolResultError(string(result))
+}
+
+// errorCodeModelCooldown marks tool failures caused by re-applying a mental
+// model to the same problem within the configured cooldown
+const errorCodeModelCooldown = "model_cooldown"
+
+// cooldownToolError reports a cooldown rejection with the ID of the earlier
+// application and how long until the model may be applied again
+func cooldownToolError(err *storage.CooldownError, fieldStyle string) *mcp.CallToolResult {
+	response := map[string]interface{}{
+		"status":              "error",
+		"code":                errorCodeModelCooldown,
+		"message":             err.Error(),
+		"prior_id":            err.PriorID,
+		"retry_after_seconds": err.RetryAfter.Seconds(),
+	}
+
+	result, _ := jsonstyle.Marshal(response, fieldStyle)
+	return mcp.NewToolResultError(string(result))
+}
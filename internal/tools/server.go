@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/tracing"
+)
+
+// NewServer creates the MCP server advertising the configured name and
+// version, rejecting unknown arguments when strict and recording tool use
+// against sessions; extra middlewares wrap every tool call after those
+func NewServer(cfg *config.Config, store *storage.Storage, middlewares ...server.ToolHandlerMiddleware) *server.MCPServer {
+	var s *server.MCPServer
+	lookup := func(name string) *server.ServerTool {
+		return s.GetTool(name)
+	}
+	options := []server.ServerOption{
+		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, false),
+		server.WithPromptCapabilities(false),
+		server.WithToolHandlerMiddleware(tracing.ToolMiddleware()),
+	}
+	if cfg.StrictToolArguments {
+		options = append(options, server.WithToolHandlerMiddleware(strictArgumentsMiddleware(lookup)))
+	}
+	options = append(options, server.WithToolHandlerMiddleware(toolUsageMiddleware(store, lookup)))
+	for _, middleware := range middlewares {
+		options = append(options, server.WithToolHandlerMiddleware(middleware))
+	}
+
+	s = server.NewMCPServer(cfg.ServerName, cfg.ServerVersion, options...)
+	return s
+}
+
+// strictArgumentsMiddleware rejects tool calls passing arguments the tool's
+// input schema does not declare, listing the accepted ones, so a misspelled
+// argument fails loudly instead of leaving the intended one empty
+func strictArgumentsMiddleware(lookup func(name string) *server.ServerTool) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			tool := lookup(req.Params.Name)
+			if tool == nil {
+				return next(ctx, req)
+			}
+
+			var unexpected []string
+			for key := range req.GetArguments() {
+				if _, declared := tool.Tool.InputSchema.Properties[key]; !declared {
+					unexpected = append(unexpected, key)
+				}
+			}
+			if len(unexpected) == 0 {
+				return next(ctx, req)
+			}
+
+			accepted := make([]string, 0, len(tool.Tool.InputSchema.Properties))
+			for key := range tool.Tool.InputSchema.Properties {
+				accepted = append(accepted, key)
+			}
+			sort.Strings(unexpected)
+			sort.Strings(accepted)
+			return mcp.NewToolResultError(fmt.Sprintf("Unexpected arguments for %s: %s; accepted arguments: %s",
+				req.Params.Name, strings.Join(unexpected, ", "), strings.Join(accepted, ", "))), nil
+		}
+	}
+}
+
+// toolUsageMiddleware records every call of a tool that is not annotated
+// read-only and names a session in that session's tools used and audit trail,
+// so reads never change a session. A call is refused when a full collection
+// rejects it; calls that create their session are recorded once they return.
+func toolUsageMiddleware(store *storage.Storage, lookup func(name string) *server.ServerTool) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := req.GetString("session_id", "")
+			if sessionID == "" || isReadOnlyTool(lookup(req.Params.Name)) {
+				return next(ctx, req)
+			}
+
+			if _, err := store.GetSession(sessionID); err != nil {
+				result, err := next(ctx, req)
+				_ = store.RecordToolUse(sessionID, req.Params.Name)
+				return result, err
+			}
+
+			if err := store.RecordToolUse(sessionID, req.Params.Name); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to record tool use: %v", err)), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// isReadOnlyTool reports whether a registered tool is annotated read-only
+func isReadOnlyTool(tool *server.ServerTool) bool {
+	if tool == nil {
+		return false
+	}
+	readOnly := tool.Tool.Annotations.ReadOnlyHint
+	return readOnly != nil && *readOnly
+}
@@ -0,0 +1,1268 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/jsonstyle"
+	"github.com/rainmana/gothink/internal/sharing"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// AddSessionTools registers the tools that inspect, export and manage sessions
+func AddSessionTools(s *server.MCPServer, store *storage.Storage, cfg *config.Config) {
+	// Create Session Tool
+	s.AddTool(
+		mcp.NewTool("create_session",
+			mcp.WithDescription("Start an empty session, generating its ID when none is given"),
+			mcp.WithString("session_id", mcp.Description("Identifier for the new session; omit to have one generated")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := req.GetString("session_id", "")
+			generated := sessionID == ""
+			if generated {
+				sessionID = store.NewSessionID()
+			} else if _, err := store.GetSession(sessionID); err == nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Session %s already exists", sessionID)), nil
+			}
+
+			session, err := store.CreateSession(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to create session: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":             "success",
+				"session_id":         sessionID,
+				"generated":          generated,
+				"created_at":         session.CreatedAt.Format(time.RFC3339),
+				"remaining_thoughts": session.RemainingThoughts,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// New Session ID Tool
+	s.AddTool(
+		mcp.NewTool("new_session_id",
+			mcp.WithDescription("Generate an unused session ID in the configured style without creating the session"),
+			mcp.WithReadOnlyHintAnnotation(true),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": store.NewSessionID(),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Stats Tool
+	s.AddTool(
+		mcp.NewTool("session_stats",
+			mcp.WithDescription("Get statistics for a session"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			// Get session stats
+			stats, err := store.GetSessionStats(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session stats: %v", err)), nil
+			}
+
+			// Create response
+			response := map[string]interface{}{
+				"session_id":         sessionID,
+				"created_at":         stats.CreatedAt.Format(time.RFC3339),
+				"last_accessed_at":   stats.LastAccessedAt.Format(time.RFC3339),
+				"thought_count":      stats.ThoughtCount,
+				"tools_used":         stats.ToolsUsed,
+				"total_operations":   stats.TotalOperations,
+				"is_active":          stats.IsActive,
+				"state":              stats.State,
+				"archived":           stats.Archived,
+				"remaining_thoughts": stats.RemainingThoughts,
+				"revision_count":     stats.RevisionCount,
+				"revision_ratio":     stats.RevisionRatio,
+				"stores":             stats.Stores,
+			}
+			if stats.Verdict != nil {
+				verdict := *stats.Verdict
+				verdict.Confidence = types.ScaleConfidence(verdict.Confidence, cfg.ConfidenceScale)
+				response["verdict"] = verdict
+				response["confidence_scale"] = cfg.ConfidenceScale
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Set Session Verdict Tool
+	s.AddTool(
+		mcp.NewTool("set_session_verdict",
+			mcp.WithDescription("Record a session's overall conclusion with a confidence and an optional recommended next action, replacing any earlier verdict; it appears in session stats and exports"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("conclusion", mcp.Required(), mcp.Description("Overall conclusion reached in the session")),
+			mcp.WithNumber("confidence", mcp.Required(), mcp.Description("Confidence in the conclusion, on the server's configured confidence scale")),
+			mcp.WithString("next_action", mcp.Description("Recommended next action")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			conclusion, _ := req.RequireString("conclusion")
+			rawConfidence, err := req.RequireFloat("confidence")
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			confidence, err := types.NormalizeConfidence(rawConfidence, cfg.ConfidenceScale)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid confidence: %v", err)), nil
+			}
+
+			verdict := &types.SessionVerdict{
+				Conclusion: conclusion,
+				Confidence: confidence,
+				NextAction: req.GetString("next_action", ""),
+			}
+			if err := store.SetSessionVerdict(sessionID, verdict); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to set session verdict: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":           "success",
+				"session_id":       sessionID,
+				"conclusion":       verdict.Conclusion,
+				"confidence":       types.ScaleConfidence(confidence, cfg.ConfidenceScale),
+				"confidence_scale": cfg.ConfidenceScale,
+				"next_action":      verdict.NextAction,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Export Tool
+	s.AddTool(
+		mcp.NewTool("session_export",
+			mcp.WithDescription("Export all data for a session, or only the lineage of one branch"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("branch_id", mcp.Description("Export only this branch's thoughts and the trunk up to its branch point, leaving out other branches")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			// Export session data, narrowed to one branch when requested
+			var exportData *types.SessionExport
+			var err error
+			if branchID := req.GetString("branch_id", ""); branchID != "" {
+				exportData, err = store.ExportSessionBranch(sessionID, branchID)
+			} else {
+				exportData, err = store.ExportSession(sessionID)
+			}
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to export session: %v", err)), nil
+			}
+
+			result, _ := jsonstyle.Marshal(SessionExportResponse(sessionID, exportData), cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Create Export Token Tool
+	s.AddTool(
+		mcp.NewTool("create_export_token",
+			mcp.WithDescription("Create a signed, expiring token for a read-only session export served by the HTTP server at /exports/{token}, for sharing without an API key"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("ttl", mcp.Description("How long the token stays valid as a Go duration such as \"1h\" (defaults to and may not exceed the configured maximum)")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			if cfg.ExportTokenSecret == "" {
+				return mcp.NewToolResultError("Export links are disabled; set GOTHINK_EXPORT_TOKEN_SECRET to enable them"), nil
+			}
+
+			ttl := cfg.ExportTokenMaxTTL
+			if raw := req.GetString("ttl", ""); raw != "" {
+				parsed, err := time.ParseDuration(raw)
+				if err != nil || parsed <= 0 {
+					return mcp.NewToolResultError(fmt.Sprintf("Invalid ttl %q: must be a positive duration such as \"1h\"", raw)), nil
+				}
+				if parsed > cfg.ExportTokenMaxTTL {
+					return mcp.NewToolResultError(fmt.Sprintf("Invalid ttl %q: exceeds the maximum of %s", raw, cfg.ExportTokenMaxTTL)), nil
+				}
+				ttl = parsed
+			}
+
+			if _, err := store.GetSession(sessionID); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to create export token: %v", err)), nil
+			}
+
+			expiresAt := time.Now().Add(ttl)
+			token := sharing.NewToken(cfg.ExportTokenSecret, sessionID, expiresAt)
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"token":      token,
+				"path":       "/exports/" + token,
+				"expires_at": expiresAt.UTC(),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Size Tool
+	s.AddTool(
+		mcp.NewTool("session_size",
+			mcp.WithDescription("Estimate the size of a session in characters and tokens, overall and per export format, to decide whether to summarize it before reuse"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			exportData, err := store.ExportSession(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to export session: %v", err)), nil
+			}
+			thoughts, _ := store.GetThoughts(sessionID)
+			mentalModels, _ := store.GetMentalModels(sessionID)
+
+			thoughtChars := 0
+			for _, thought := range thoughts {
+				thoughtChars += utf8.RuneCountInString(thought.Thought)
+			}
+			modelChars := 0
+			for _, model := range mentalModels {
+				modelChars += mentalModelChars(model)
+			}
+
+			exportJSON, _ := jsonstyle.Marshal(SessionExportResponse(sessionID, exportData), cfg.JSONFieldStyle)
+			jsonChars := utf8.RuneCount(exportJSON)
+			markdownChars := utf8.RuneCountInString(renderSessionMarkdown(sessionID, thoughts, mentalModels))
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"thoughts": map[string]interface{}{
+					"count": len(thoughts),
+					"chars": thoughtChars,
+				},
+				"mental_models": map[string]interface{}{
+					"count": len(mentalModels),
+					"chars": modelChars,
+				},
+				"export_chars": map[string]interface{}{
+					"json":     jsonChars,
+					"markdown": markdownChars,
+				},
+				"estimated_tokens": map[string]interface{}{
+					"json":     estimateTokens(jsonChars),
+					"markdown": estimateTokens(markdownChars),
+				},
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Model Summary Tool
+	s.AddTool(
+		mcp.NewTool("session_model_summary",
+			mcp.WithDescription("List the mental models applied in a session, grouped by model name with their problem statements"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			summary, err := store.SessionModelSummary(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to summarize session models: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"models":     summary,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Decisions Tool
+	s.AddTool(
+		mcp.NewTool("session_decisions",
+			mcp.WithDescription("List only the conclusions reached in a session, including debugging resolutions, with model or approach names and confidence, skipping inconclusive applications"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			decisions, err := store.SessionDecisions(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session decisions: %v", err)), nil
+			}
+			// Report confidence on the scale clients declared it in
+			for i := range decisions {
+				decisions[i].Confidence = types.ScaleConfidence(decisions[i].Confidence, cfg.ConfidenceScale)
+			}
+
+			response := map[string]interface{}{
+				"status":           "success",
+				"session_id":       sessionID,
+				"decisions":        decisions,
+				"count":            len(decisions),
+				"confidence_scale": cfg.ConfidenceScale,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Problems Tool
+	s.AddTool(
+		mcp.NewTool("session_problems",
+			mcp.WithDescription("List the distinct problems explored in a session, with the number of mental models applied to each"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			problems, err := store.SessionProblems(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session problems: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"problems":   problems,
+				"count":      len(problems),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Diff Sessions Tool
+	s.AddTool(
+		mcp.NewTool("diff_sessions",
+			mcp.WithDescription("Compare the reasoning of two sessions, reporting differing thoughts, the divergence point, and model differences"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_a", mcp.Required(), mcp.Description("First session identifier")),
+			mcp.WithString("session_b", mcp.Required(), mcp.Description("Second session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionA, _ := req.RequireString("session_a")
+			sessionB, _ := req.RequireString("session_b")
+
+			diff, err := store.DiffSessions(sessionA, sessionB)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to diff sessions: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status": "success",
+				"diff":   diff,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Recent Sessions Tool
+	s.AddTool(
+		mcp.NewTool("recent_sessions",
+			mcp.WithDescription("List the most recently accessed sessions with summary statistics"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithNumber("limit", mcp.Description("Maximum number of sessions to return")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			limit := req.GetInt("limit", cfg.RecentSessionsLimit)
+			if limit <= 0 {
+				return mcp.NewToolResultError("limit must be positive"), nil
+			}
+
+			sessions := store.RecentSessions(limit)
+
+			response := map[string]interface{}{
+				"status":   "success",
+				"count":    len(sessions),
+				"sessions": sessions,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Get Thoughts Tool
+	s.AddTool(
+		mcp.NewTool("get_thoughts",
+			mcp.WithDescription("Retrieve a session's thoughts in order, including their references; pass the returned last_id as after_id to fetch only thoughts added since"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("after_id", mcp.Description("Return only thoughts stored after the thought with this ID")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			afterID := req.GetString("after_id", "")
+
+			if _, err := store.GetSession(sessionID); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get thoughts: %v", err)), nil
+			}
+			thoughts, err := store.ThoughtsAfter(sessionID, afterID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get thoughts: %v", err)), nil
+			}
+			if thoughts == nil {
+				thoughts = []*types.ThoughtData{}
+			}
+
+			// With nothing new the cursor stays where it was
+			lastID := afterID
+			if len(thoughts) > 0 {
+				lastID = thoughts[len(thoughts)-1].ID
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"count":      len(thoughts),
+				"thoughts":   thoughts,
+				"last_id":    lastID,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Annotate Thought Tool
+	s.AddTool(
+		mcp.NewTool("annotate_thought",
+			mcp.WithDescription("Link a stored thought to external references such as a commit, document, issue or URL"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("thought_id", mcp.Required(), mcp.Description("ID of the thought to annotate")),
+			mcp.WithArray("references", mcp.Required(),
+				mcp.Description("References to attach, each an object with a type (url, commit, doc or issue) and a value"),
+				mcp.Items(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"type":  map[string]interface{}{"type": "string", "enum": []string{types.ReferenceURL, types.ReferenceCommit, types.ReferenceDoc, types.ReferenceIssue}},
+						"value": map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"type", "value"},
+				}),
+			),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			thoughtID, _ := req.RequireString("thought_id")
+
+			references, err := parseReferences(req.GetArguments()["references"])
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid references: %v", err)), nil
+			}
+
+			references, err = store.AnnotateThought(sessionID, thoughtID, references)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to annotate thought: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"thought_id": thoughtID,
+				"references": references,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Count Thoughts Tool
+	s.AddTool(
+		mcp.NewTool("count_thoughts",
+			mcp.WithDescription("Count a session's thoughts matching simple filters without returning them"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithBoolean("is_revision", mcp.Description("Only count revisions (true) or non-revisions (false)")),
+			mcp.WithString("branch_id", mcp.Description("Only count thoughts on this branch")),
+			mcp.WithString("since", mcp.Description("Only count thoughts created at or after this RFC3339 timestamp")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			filter := storage.ThoughtFilter{BranchID: req.GetString("branch_id", "")}
+			if isRevision, ok := req.GetArguments()["is_revision"].(bool); ok {
+				filter.IsRevision = &isRevision
+			}
+			if since := req.GetString("since", ""); since != "" {
+				parsed, err := time.Parse(time.RFC3339, since)
+				if err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("Invalid since timestamp: %v", err)), nil
+				}
+				filter.Since = parsed
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"count":      store.CountThoughts(sessionID, filter),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Bulk Tag Thoughts Tool
+	s.AddTool(
+		mcp.NewTool("bulk_tag_thoughts",
+			mcp.WithDescription("Tag every thought in a session matching a filter, such as all revisions or all thoughts on a branch, returning the count tagged"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("tag", mcp.Required(), mcp.Description("Tag to apply to the matching thoughts")),
+			mcp.WithBoolean("is_revision", mcp.Description("Only tag revisions (true) or non-revisions (false)")),
+			mcp.WithString("branch_id", mcp.Description("Only tag thoughts on this branch")),
+			mcp.WithNumber("from_number", mcp.Description("Only tag thoughts numbered at least this")),
+			mcp.WithNumber("to_number", mcp.Description("Only tag thoughts numbered at most this")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			tag, _ := req.RequireString("tag")
+
+			filter := storage.ThoughtFilter{BranchID: req.GetString("branch_id", "")}
+			if isRevision, ok := req.GetArguments()["is_revision"].(bool); ok {
+				filter.IsRevision = &isRevision
+			}
+			if fromNumber, ok := req.GetArguments()["from_number"].(float64); ok {
+				minNumber := int(fromNumber)
+				filter.MinNumber = &minNumber
+			}
+			if toNumber, ok := req.GetArguments()["to_number"].(float64); ok {
+				maxNumber := int(toNumber)
+				filter.MaxNumber = &maxNumber
+			}
+
+			tagged, err := store.TagThoughts(sessionID, filter, tag)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to tag thoughts: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"tag":        strings.TrimSpace(tag),
+				"tagged":     tagged,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Pending Thoughts Tool
+	s.AddTool(
+		mcp.NewTool("pending_thoughts",
+			mcp.WithDescription("List thoughts flagged as needing a next thought or more thoughts, grouped by session"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Description("Restrict the search to one session (defaults to all sessions)")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := req.GetString("session_id", "")
+
+			pending := store.GetPendingThoughts(sessionID)
+
+			total := 0
+			for _, thoughts := range pending {
+				total += len(thoughts)
+			}
+
+			response := map[string]interface{}{
+				"status":        "success",
+				"total_pending": total,
+				"sessions":      pending,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Resolved Thoughts Tool
+	s.AddTool(
+		mcp.NewTool("resolved_thoughts",
+			mcp.WithDescription("List a session's current line of reasoning: one entry per original trunk thought holding its latest revision, with superseded versions hidden"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			resolved, err := store.ResolvedThoughts(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to resolve thoughts: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"count":      len(resolved),
+				"thoughts":   resolved,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Find Similar Thoughts Tool
+	s.AddTool(
+		mcp.NewTool("find_similar_thoughts",
+			mcp.WithDescription("Find clusters of near-duplicate thoughts in a session by trigram similarity, so they can be consolidated"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithNumber("threshold", mcp.Description("Minimum similarity between 0 and 1 for thoughts to cluster; defaults to the configured threshold")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			threshold := req.GetFloat("threshold", cfg.SimilarityThreshold)
+
+			clusters, err := store.SimilarThoughts(sessionID, threshold)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to find similar thoughts: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"threshold":  threshold,
+				"clusters":   clusters,
+				"count":      len(clusters),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Transcript Tool
+	s.AddTool(
+		mcp.NewTool("session_transcript",
+			mcp.WithDescription("Render a session's thoughts as a plain numbered text transcript with revisions and branches annotated"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			thoughts, err := store.GetThoughts(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get thoughts: %v", err)), nil
+			}
+			if len(thoughts) == 0 {
+				return mcp.NewToolResultError(fmt.Sprintf("Session %s has no thoughts", sessionID)), nil
+			}
+
+			return mcp.NewToolResultText(renderTranscript(thoughts)), nil
+		},
+	)
+
+	// Session Timeline Tool
+	s.AddTool(
+		mcp.NewTool("session_timeline",
+			mcp.WithDescription("Retrieve a session's full history as one time-ordered list of creation, thought, mental model and tool call events"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			timeline, err := store.SessionTimeline(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session timeline: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"count":      len(timeline),
+				"events":     timeline,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Tool Sequence Tool
+	s.AddTool(
+		mcp.NewTool("tool_sequence",
+			mcp.WithDescription("List the state-changing tools invoked against a session in call order with their timestamps, from its audit trail, for replaying a workflow; read-only tools are not recorded, repeated calls are kept and the trail is capped by max_audit_entries"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			audit, err := store.GetSessionAudit(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get tool sequence: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"count":      len(audit),
+				"sequence":   audit,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Duration Tool
+	s.AddTool(
+		mcp.NewTool("session_duration",
+			mcp.WithDescription("Measure how long a session's reasoning took from thought timestamps: wall-clock time from first to last thought, the average interval between thoughts, and the active time excluding idle gaps longer than the session timeout"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			duration, err := store.SessionDuration(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session duration: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":   "success",
+				"duration": duration,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Verify Session Tool
+	s.AddTool(
+		mcp.NewTool("verify_session",
+			mcp.WithDescription("Check a session's internal consistency and report any violated invariants"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			verification, err := store.VerifySession(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to verify session: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":       "success",
+				"verification": verification,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Repair Session Tool
+	s.AddTool(
+		mcp.NewTool("repair_session",
+			mcp.WithDescription("Fix common session inconsistencies (numbering gaps, dangling revision/branch pointers, thought count drift) and report every change"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithBoolean("dry_run", mcp.Description("Report the changes without applying them (default: false)")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			dryRun := req.GetBool("dry_run", false)
+
+			repair, err := store.RepairSession(sessionID, dryRun)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to repair session: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status": "success",
+				"repair": repair,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Recompute Stats Tool
+	s.AddTool(
+		mcp.NewTool("recompute_stats",
+			mcp.WithDescription("Recount a session's thoughts and mental models and correct its stored thought count, remaining thoughts and total operations, reporting every counter that had drifted"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			recomputation, err := store.RecomputeStats(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to recompute stats: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status": "success",
+				"stats":  recomputation,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Promote Branch Tool
+	s.AddTool(
+		mcp.NewTool("promote_branch",
+			mcp.WithDescription("Append a branch's thoughts onto the end of the trunk, renumbered, to adopt it as the way forward"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("branch_id", mcp.Required(), mcp.Description("Branch to promote")),
+			mcp.WithBoolean("delete_branch", mcp.Description("Move the branch thoughts into the trunk instead of copying them (default: false)")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			branchID, _ := req.RequireString("branch_id")
+			deleteBranch := req.GetBool("delete_branch", false)
+
+			trunkLength, err := store.PromoteBranch(sessionID, branchID, deleteBranch)
+			if errors.Is(err, storage.ErrThoughtLimitReached) {
+				return toolErrorWithCode(errorCodeThoughtLimitReached, err, cfg.JSONFieldStyle), nil
+			}
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to promote branch: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":         "success",
+				"session_id":     sessionID,
+				"branch_id":      branchID,
+				"branch_deleted": deleteBranch,
+				"trunk_length":   trunkLength,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Archive Session Tool
+	s.AddTool(
+		mcp.NewTool("archive_session",
+			mcp.WithDescription("Mark a session read-only so that further writes are rejected"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			if err := store.ArchiveSession(sessionID); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to archive session: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"archived":   true,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Unarchive Session Tool
+	s.AddTool(
+		mcp.NewTool("unarchive_session",
+			mcp.WithDescription("Make an archived session writable again"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			if err := store.UnarchiveSession(sessionID); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to unarchive session: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"archived":   false,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Checkpoint Session Tool
+	s.AddTool(
+		mcp.NewTool("checkpoint_session",
+			mcp.WithDescription("Save a named snapshot of a session's thoughts and mental models to roll back to later"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("name", mcp.Required(), mcp.Description("Checkpoint name, unique within the session")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			name, _ := req.RequireString("name")
+
+			checkpoint, err := store.CheckpointSession(sessionID, name)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to checkpoint session: %v", err)), nil
+			}
+			checkpoints, _ := store.ListCheckpoints(sessionID)
+
+			response := map[string]interface{}{
+				"status":      "success",
+				"session_id":  sessionID,
+				"checkpoint":  checkpoint,
+				"checkpoints": checkpoints,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Restore Checkpoint Tool
+	s.AddTool(
+		mcp.NewTool("restore_checkpoint",
+			mcp.WithDescription("Roll a session's thoughts and mental models back to a named checkpoint, discarding later changes"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("name", mcp.Required(), mcp.Description("Name of the checkpoint to restore")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			name, _ := req.RequireString("name")
+
+			checkpoint, err := store.RestoreCheckpoint(sessionID, name)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to restore checkpoint: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"checkpoint": checkpoint,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Set Session Metadata Tool
+	s.AddTool(
+		mcp.NewTool("set_session_metadata",
+			mcp.WithDescription("Store a client-defined key/value on a session (e.g. a project ID or ticket link)"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("key", mcp.Required(), mcp.Description("Metadata key")),
+			mcp.WithString("value", mcp.Required(), mcp.Description("Metadata value; overwrites any existing value for the key")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			key, _ := req.RequireString("key")
+			value, _ := req.RequireString("value")
+
+			if err := store.SetSessionMetadata(sessionID, key, value); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to set session metadata: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"key":        key,
+				"value":      value,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Get Session Metadata Tool
+	s.AddTool(
+		mcp.NewTool("get_session_metadata",
+			mcp.WithDescription("Retrieve the client-defined metadata stored on a session"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			metadata, err := store.GetSessionMetadata(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session metadata: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"metadata":   metadata,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Tag Session Tool
+	s.AddTool(
+		mcp.NewTool("tag_session",
+			mcp.WithDescription("Add tags to a session, e.g. to group sessions by project"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithArray("tags", mcp.Required(), mcp.Description("Tags to add"), mcp.WithStringItems()),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			tags := req.GetStringSlice("tags", []string{})
+
+			tags, err := store.TagSession(sessionID, tags)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to tag session: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"tags":       tags,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Untag Session Tool
+	s.AddTool(
+		mcp.NewTool("untag_session",
+			mcp.WithDescription("Remove tags from a session"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithArray("tags", mcp.Required(), mcp.Description("Tags to remove"), mcp.WithStringItems()),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			tags := req.GetStringSlice("tags", []string{})
+
+			tags, err := store.UntagSession(sessionID, tags)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to untag session: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"tags":       tags,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Sessions By Tag Tool
+	s.AddTool(
+		mcp.NewTool("sessions_by_tag",
+			mcp.WithDescription("List the IDs of sessions carrying the given tags, most recently accessed first"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithArray("tags", mcp.Required(), mcp.Description("Tags to match"), mcp.WithStringItems()),
+			mcp.WithString("match", mcp.Enum("any", "all"), mcp.Description("Match sessions with any of the tags (default) or all of them")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			tags := req.GetStringSlice("tags", []string{})
+			match := req.GetString("match", "any")
+			if match != "any" && match != "all" {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid match '%s': expected any or all", match)), nil
+			}
+
+			sessionIDs, err := store.SessionsByTag(tags, match == "all")
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to find sessions by tag: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":      "success",
+				"tags":        tags,
+				"match":       match,
+				"session_ids": sessionIDs,
+				"count":       len(sessionIDs),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Global Stats Tool
+	s.AddTool(
+		mcp.NewTool("global_stats",
+			mcp.WithDescription("Get server-wide statistics including thought ingestion rates and session lock contention"),
+			mcp.WithReadOnlyHintAnnotation(true),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			stats := store.GetGlobalStats()
+
+			response := map[string]interface{}{
+				"status": "success",
+				"stats":  stats,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+}
+
+// renderTranscript renders thoughts in recorded order as "N. <thought>" lines,
+// annotating revisions and branch points inline
+func renderTranscript(thoughts []*types.ThoughtData) string {
+	var transcript strings.Builder
+	for _, thought := range thoughts {
+		var annotations []string
+		if thought.BranchID != "" {
+			branch := fmt.Sprintf("branch %s", thought.BranchID)
+			if thought.BranchFromThought != nil {
+				branch += fmt.Sprintf(" from %d", *thought.BranchFromThought)
+			}
+			annotations = append(annotations, branch)
+		}
+		if thought.IsRevision && thought.RevisesThought != nil {
+			annotations = append(annotations, fmt.Sprintf("revises %d", *thought.RevisesThought))
+		}
+
+		fmt.Fprintf(&transcript, "%d. ", thought.ThoughtNumber)
+		if len(annotations) > 0 {
+			fmt.Fprintf(&transcript, "[%s] ", strings.Join(annotations, ", "))
+		}
+		transcript.WriteString(thought.Thought)
+		transcript.WriteString("\n")
+		for _, reference := range thought.References {
+			fmt.Fprintf(&transcript, "   - %s: %s\n", reference.Type, reference.Value)
+		}
+	}
+	return transcript.String()
+}
+
+// parseReferences reads the references argument of annotate_thought, a list
+// of objects with a type and value
+func parseReferences(raw interface{}) ([]types.Reference, error) {
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("references must be a list")
+	}
+
+	references := make([]types.Reference, 0, len(items))
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reference %d must be an object with a type and value", i)
+		}
+		referenceType, _ := fields["type"].(string)
+		value, _ := fields["value"].(string)
+		references = append(references, types.Reference{Type: referenceType, Value: value})
+	}
+	return references, nil
+}
+
+// SessionExportResponse wraps exported session data in the session_export
+// response envelope
+func SessionExportResponse(sessionID string, exportData *types.SessionExport) map[string]interface{} {
+	return map[string]interface{}{
+		"version":      storage.CurrentExportVersion,
+		"timestamp":    time.Now().Format(time.RFC3339),
+		"session_id":   sessionID,
+		"session_type": "hybrid",
+		"data":         exportData,
+		"metadata": map[string]interface{}{
+			"exported_at": time.Now().Format(time.RFC3339),
+			"version":     "0.1.0",
+		},
+	}
+}
+
+// mentalModelChars counts the characters of a mental model application's text
+func mentalModelChars(model *types.MentalModelData) int {
+	chars := utf8.RuneCountInString(model.ModelName) + utf8.RuneCountInString(model.Problem) +
+		utf8.RuneCountInString(model.Reasoning) + utf8.RuneCountInString(model.Conclusion)
+	for _, step := range model.Steps {
+		chars += utf8.RuneCountInString(step)
+	}
+	return chars
+}
+
+// renderSessionMarkdown renders a session as a Markdown document: the thought
+// transcript followed by each mental model application
+func renderSessionMarkdown(sessionID string, thoughts []*types.ThoughtData, mentalModels []*types.MentalModelData) string {
+	var markdown strings.Builder
+	fmt.Fprintf(&markdown, "# Session %s\n", sessionID)
+
+	if len(thoughts) > 0 {
+		markdown.WriteString("\n## Thoughts\n\n")
+		markdown.WriteString(renderTranscript(thoughts))
+	}
+
+	if len(mentalModels) > 0 {
+		markdown.WriteString("\n## Mental Models\n")
+		for _, model := range mentalModels {
+			fmt.Fprintf(&markdown, "\n### %s\n\n%s\n", model.ModelName, model.Problem)
+			for _, step := range model.Steps {
+				fmt.Fprintf(&markdown, "- %s\n", step)
+			}
+			if model.Reasoning != "" {
+				fmt.Fprintf(&markdown, "\n%s\n", model.Reasoning)
+			}
+			if model.Conclusion != "" {
+				fmt.Fprintf(&markdown, "\n**Conclusion:** %s\n", model.Conclusion)
+			}
+		}
+	}
+
+	return markdown.String()
+}
+
+// estimateTokens approximates a token count from a character count at the
+// common rule of thumb of four characters per token
+func estimateTokens(chars int) int {
+	return (chars + 3) / 4
+}
@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/jsonstyle"
+	"github.com/rainmana/gothink/internal/models"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/templates"
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// AddTemplateTools registers the tools that list session templates and seed
+// sessions from them
+func AddTemplateTools(s *server.MCPServer, store *storage.Storage, modelsLoader *models.Loader, templatesLoader *templates.Loader, cfg *config.Config) {
+	// List Session Templates Tool
+	s.AddTool(
+		mcp.NewTool("list_session_templates",
+			mcp.WithDescription("List the session templates available to create_session_from_template, with the number of thoughts and mental models each seeds and the file it came from"),
+			mcp.WithReadOnlyHintAnnotation(true),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			availableTemplates, err := templatesLoader.LoadTemplates(cfg.SessionTemplatesPath)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load session templates: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":          "success",
+				"total_templates": len(availableTemplates),
+				"templates":       templates.Summarize(availableTemplates),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Create Session From Template Tool
+	s.AddTool(
+		mcp.NewTool("create_session_from_template",
+			mcp.WithDescription("Create a new session seeded with a template's thoughts and mental models"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Identifier for the new session")),
+			mcp.WithString("template_name", mcp.Required(), mcp.Description("Name of the session template to instantiate")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			templateName, _ := req.RequireString("template_name")
+
+			// Load available session templates
+			availableTemplates, err := templatesLoader.LoadTemplates(cfg.SessionTemplatesPath)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load session templates: %v", err)), nil
+			}
+
+			template, exists := availableTemplates[templateName]
+			if !exists {
+				return mcp.NewToolResultError(fmt.Sprintf("Session template '%s' not found", templateName)), nil
+			}
+
+			// Load available mental models for pre-applied models
+			availableModels, err := modelsLoader.Models()
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
+			}
+
+			result, err := handleCreateSessionFromTemplate(store, availableModels, sessionID, templateName, template, cfg.JSONFieldStyle)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+}
+
+// handleCreateSessionFromTemplate instantiates a session template into a new session
+func handleCreateSessionFromTemplate(store *storage.Storage, availableModels map[string]models.MentalModel, sessionID, templateName string, template templates.SessionTemplate, fieldStyle string) (string, error) {
+	if _, err := store.GetSession(sessionID); err == nil {
+		return "", fmt.Errorf("session %s already exists", sessionID)
+	}
+
+	// Validate referenced models before creating anything
+	for _, templateModel := range template.Models {
+		if _, exists := availableModels[templateModel.ModelName]; !exists {
+			return "", fmt.Errorf("template '%s' references unknown mental model '%s'", templateName, templateModel.ModelName)
+		}
+	}
+
+	if _, err := store.CreateSession(sessionID); err != nil {
+		return "", err
+	}
+
+	// Remove a partly seeded session if a limit stops seeding, so a retry can
+	// reuse the ID
+	seeded := false
+	defer func() {
+		if !seeded {
+			_, _ = store.ClearSession(sessionID)
+		}
+	}()
+
+	// Seed the thought sequence
+	thoughtIDs := make([]string, 0, len(template.Thoughts))
+	for i, thought := range template.Thoughts {
+		thoughtData := &types.ThoughtData{
+			Thought:           thought,
+			ThoughtNumber:     store.ThoughtNumberBase() + i,
+			TotalThoughts:     len(template.Thoughts),
+			NextThoughtNeeded: i < len(template.Thoughts)-1,
+		}
+		if err := store.AddThought(sessionID, thoughtData); err != nil {
+			return "", err
+		}
+		thoughtIDs = append(thoughtIDs, thoughtData.ID)
+	}
+
+	// Apply the template's mental models
+	modelIDs := make([]string, 0, len(template.Models))
+	for _, templateModel := range template.Models {
+		modelData := &types.MentalModelData{
+			ModelName: templateModel.ModelName,
+			Problem:   templateModel.Problem,
+			Steps:     availableModels[templateModel.ModelName].Steps,
+		}
+		if err := store.AddMentalModel(sessionID, modelData); err != nil {
+			return "", err
+		}
+		modelIDs = append(modelIDs, modelData.ID)
+	}
+
+	seeded = true
+
+	response := map[string]interface{}{
+		"status":        "success",
+		"session_id":    sessionID,
+		"template_name": templateName,
+		"thought_ids":   thoughtIDs,
+		"model_ids":     modelIDs,
+	}
+
+	result, err := jsonstyle.Marshal(response, fieldStyle)
+	if err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
@@ -0,0 +1,541 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/jsonstyle"
+	"github.com/rainmana/gothink/internal/models"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/types"
+)
+
+// AddThinkingTools registers the reasoning tools (sequential thinking, mental
+// models and debugging approaches) and the mental model library lookups
+func AddThinkingTools(s *server.MCPServer, store *storage.Storage, modelsLoader *models.Loader, cfg *config.Config) {
+	// Sequential Thinking Tool
+	s.AddTool(
+		mcp.NewTool("sequential_thinking",
+			mcp.WithDescription("Perform sequential thinking operations with structured thought progression"),
+			mcp.WithString("session_id", mcp.Description("Session identifier; omit to start a new session with a generated ID")),
+			mcp.WithString("thought", mcp.Required(), mcp.Description("Current thought content")),
+			mcp.WithNumber("thought_number", mcp.Required(), mcp.Description("Current thought number in sequence, counted from the configured thought number base")),
+			mcp.WithNumber("total_thoughts", mcp.Required(), mcp.Description("Total number of thoughts planned")),
+			mcp.WithBoolean("next_thought_needed", mcp.Required(), mcp.Description("Whether another thought is needed")),
+			mcp.WithBoolean("is_revision", mcp.Description("Whether this thought revises an earlier one")),
+			mcp.WithNumber("revises_thought", mcp.Description("Number of the earlier thought this one revises; it must exist in the session")),
+			mcp.WithNumber("branch_from_thought", mcp.Description("Number of the thought this one branches from; it must exist in the session")),
+			mcp.WithString("branch_id", mcp.Description("Identifier of the branch this thought belongs to")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := req.GetString("session_id", "")
+			if sessionID == "" {
+				sessionID = store.NewSessionID()
+			}
+			thought, _ := req.RequireString("thought")
+			thoughtNumber, _ := req.RequireInt("thought_number")
+			totalThoughts, _ := req.RequireInt("total_thoughts")
+			nextThoughtNeeded, _ := req.RequireBool("next_thought_needed")
+
+			truncated := false
+			if cfg.ThoughtLengthMode == config.ThoughtLengthTruncate {
+				thought, truncated = truncateArgument(thought, cfg.MaxThoughtLength)
+			} else if err := checkArgumentLength("thought", thought, cfg.MaxThoughtLength); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			thoughtData := &types.ThoughtData{
+				Thought:           thought,
+				ThoughtNumber:     thoughtNumber,
+				TotalThoughts:     totalThoughts,
+				NextThoughtNeeded: nextThoughtNeeded,
+				IsRevision:        req.GetBool("is_revision", false),
+				BranchID:          req.GetString("branch_id", ""),
+				Truncated:         truncated,
+			}
+			if _, ok := req.GetArguments()["revises_thought"]; ok {
+				revisesThought := req.GetInt("revises_thought", 0)
+				thoughtData.RevisesThought = &revisesThought
+			}
+			if _, ok := req.GetArguments()["branch_from_thought"]; ok {
+				branchFromThought := req.GetInt("branch_from_thought", 0)
+				thoughtData.BranchFromThought = &branchFromThought
+			}
+
+			result, err := handleSequentialThinking(store, sessionID, thoughtData, cfg.JSONFieldStyle)
+			if errors.Is(err, storage.ErrThoughtLimitReached) {
+				return toolErrorWithCode(errorCodeThoughtLimitReached, err, cfg.JSONFieldStyle), nil
+			}
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+
+	// Mental Model Tool
+	s.AddTool(
+		mcp.NewTool("mental_model",
+			mcp.WithDescription("Apply mental models to solve problems using structured thinking frameworks"),
+			mcp.WithString("session_id", mcp.Description("Session identifier; omit to start a new session with a generated ID")),
+			mcp.WithString("model_name", mcp.Required(), mcp.Description("Name of the mental model to apply")),
+			mcp.WithString("problem", mcp.Required(), mcp.Description("Problem statement to analyze")),
+			mcp.WithArray("steps", mcp.Description("Steps to follow for the mental model")),
+			mcp.WithNumber("confidence", mcp.Description("Confidence in the analysis, on the server's configured confidence scale")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := req.GetString("session_id", "")
+			if sessionID == "" {
+				sessionID = store.NewSessionID()
+			}
+			modelName, _ := req.RequireString("model_name")
+			problem, _ := req.RequireString("problem")
+			steps := req.GetStringSlice("steps", []string{})
+
+			if err := checkArgumentLength("problem", problem, cfg.MaxProblemLength); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if err := checkSteps(steps, cfg.MaxStepsPerCall, cfg.MaxStepLength); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			confidence, err := types.NormalizeConfidence(req.GetFloat("confidence", 0), cfg.ConfidenceScale)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid confidence: %v", err)), nil
+			}
+
+			// Load available mental models
+			availableModels, err := modelsLoader.Models()
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
+			}
+
+			// Check if the requested model exists, resolving it to its canonical key
+			modelName, model, exists := modelsLoader.Lookup(availableModels, modelName)
+			if !exists {
+				// Return available models for reference
+				available := modelsLoader.GetAvailableModels(availableModels)
+				return mcp.NewToolResultError(fmt.Sprintf("Mental model '%s' not found. Available models: %v", modelName, available)), nil
+			}
+
+			// Use model steps if no custom steps provided
+			if len(steps) == 0 {
+				steps = model.Steps
+			}
+
+			// Create mental model data
+			modelData := &types.MentalModelData{
+				ModelName:  modelName,
+				Problem:    problem,
+				Steps:      steps,
+				Confidence: confidence,
+				CreatedAt:  time.Now(),
+			}
+
+			// Store the mental model
+			if err := store.AddMentalModel(sessionID, modelData); err != nil {
+				var cooldown *storage.CooldownError
+				if errors.As(err, &cooldown) {
+					return cooldownToolError(cooldown, cfg.JSONFieldStyle), nil
+				}
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to store mental model: %v", err)), nil
+			}
+
+			// Get session stats
+			stats, _ := store.GetSessionStats(sessionID)
+
+			// Create response
+			response := map[string]interface{}{
+				"status":     "success",
+				"model_id":   modelData.ID,
+				"model_name": modelName,
+				"model_info": map[string]interface{}{
+					"name":        model.Name,
+					"description": model.Description,
+					"category":    model.Category,
+					"priority":    model.Priority,
+				},
+				"steps_used":       steps,
+				"has_steps":        len(steps) > 0,
+				"has_conclusion":   false,
+				"confidence":       types.ScaleConfidence(confidence, cfg.ConfidenceScale),
+				"confidence_scale": cfg.ConfidenceScale,
+				"session_context": map[string]interface{}{
+					"session_id":          sessionID,
+					"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
+				},
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Mental Model Batch Tool
+	s.AddTool(
+		mcp.NewTool("mental_model_batch",
+			mcp.WithDescription("Apply one mental model to several problems in a single call"),
+			mcp.WithString("session_id", mcp.Description("Session identifier; omit to start a new session with a generated ID")),
+			mcp.WithString("model_name", mcp.Required(), mcp.Description("Name of the mental model to apply")),
+			mcp.WithArray("problems", mcp.Required(), mcp.Description("Problem statements to analyze"), mcp.WithStringItems()),
+			mcp.WithArray("steps", mcp.Description("Steps to follow for the mental model")),
+			mcp.WithNumber("confidence", mcp.Description("Confidence applied to every analysis, on the server's configured confidence scale")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := req.GetString("session_id", "")
+			if sessionID == "" {
+				sessionID = store.NewSessionID()
+			}
+			modelName, _ := req.RequireString("model_name")
+			problems := req.GetStringSlice("problems", []string{})
+			steps := req.GetStringSlice("steps", []string{})
+
+			if len(problems) == 0 {
+				return mcp.NewToolResultError("At least one problem is required"), nil
+			}
+			for i, problem := range problems {
+				if err := checkArgumentLength(fmt.Sprintf("problems[%d]", i), problem, cfg.MaxProblemLength); err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+			}
+			if err := checkSteps(steps, cfg.MaxStepsPerCall, cfg.MaxStepLength); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			confidence, err := types.NormalizeConfidence(req.GetFloat("confidence", 0), cfg.ConfidenceScale)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid confidence: %v", err)), nil
+			}
+
+			// Load available mental models
+			availableModels, err := modelsLoader.Models()
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
+			}
+
+			modelName, model, exists := modelsLoader.Lookup(availableModels, modelName)
+			if !exists {
+				available := modelsLoader.GetAvailableModels(availableModels)
+				return mcp.NewToolResultError(fmt.Sprintf("Mental model '%s' not found. Available models: %v", modelName, available)), nil
+			}
+
+			// Use model steps if no custom steps provided
+			if len(steps) == 0 {
+				steps = model.Steps
+			}
+
+			batch := make([]*types.MentalModelData, 0, len(problems))
+			for _, problem := range problems {
+				batch = append(batch, &types.MentalModelData{
+					ModelName:  modelName,
+					Problem:    problem,
+					Steps:      steps,
+					Confidence: confidence,
+				})
+			}
+
+			// Store the whole batch or nothing
+			if err := store.AddMentalModels(sessionID, batch); err != nil {
+				var cooldown *storage.CooldownError
+				if errors.As(err, &cooldown) {
+					return cooldownToolError(cooldown, cfg.JSONFieldStyle), nil
+				}
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to store mental models: %v", err)), nil
+			}
+
+			modelIDs := make([]string, 0, len(batch))
+			for _, modelData := range batch {
+				modelIDs = append(modelIDs, modelData.ID)
+			}
+
+			stats, _ := store.GetSessionStats(sessionID)
+
+			response := map[string]interface{}{
+				"status":           "success",
+				"model_name":       modelName,
+				"model_ids":        modelIDs,
+				"count":            len(modelIDs),
+				"confidence":       types.ScaleConfidence(confidence, cfg.ConfidenceScale),
+				"confidence_scale": cfg.ConfidenceScale,
+				"session_context": map[string]interface{}{
+					"session_id":          sessionID,
+					"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
+				},
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Debugging Approach Tool
+	s.AddTool(
+		mcp.NewTool("debugging_approach",
+			mcp.WithDescription("Apply systematic debugging approaches to identify and resolve issues"),
+			mcp.WithString("session_id", mcp.Description("Session identifier; omit to start a new session with a generated ID")),
+			mcp.WithString("approach_name", mcp.Required(), mcp.Description("Name of the debugging approach")),
+			mcp.WithString("issue", mcp.Required(), mcp.Description("Issue description to debug")),
+			mcp.WithArray("steps", mcp.Description("Debugging steps to follow")),
+			mcp.WithString("findings", mcp.Description("What the investigation found so far")),
+			mcp.WithString("resolution", mcp.Description("How the issue was resolved, if it was")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := req.GetString("session_id", "")
+			if sessionID == "" {
+				sessionID = store.NewSessionID()
+			}
+			approachName, _ := req.RequireString("approach_name")
+			approachName = modelsLoader.NormalizeApproachName(approachName)
+			issue, _ := req.RequireString("issue")
+			steps := req.GetStringSlice("steps", []string{})
+			findings := req.GetString("findings", "")
+			resolution := req.GetString("resolution", "")
+
+			if err := checkArgumentLength("issue", issue, cfg.MaxIssueLength); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if err := checkSteps(steps, cfg.MaxStepsPerCall, cfg.MaxStepLength); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			// Debugging approaches are stored as specially named mental models
+			approach := &types.MentalModelData{
+				ModelName:  types.DebuggingApproachPrefix + approachName,
+				Problem:    issue,
+				Steps:      steps,
+				Reasoning:  findings,
+				Conclusion: resolution,
+			}
+			if err := store.AddMentalModel(sessionID, approach); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to store debugging approach: %v", err)), nil
+			}
+
+			// Get session stats
+			stats, err := store.GetSessionStats(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get session stats: %v", err)), nil
+			}
+
+			// Create response
+			response := map[string]interface{}{
+				"status":         "success",
+				"approach_id":    approach.ID,
+				"approach_name":  approachName,
+				"has_steps":      len(steps) > 0,
+				"has_findings":   findings != "",
+				"has_resolution": resolution != "",
+				"session_context": map[string]interface{}{
+					"session_id":          sessionID,
+					"thought_count":       stats.ThoughtCount,
+					"total_mental_models": stats.Stores["mental_models"].(map[string]int)["count"],
+					"tools_used":          stats.ToolsUsed,
+				},
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Check Model Coverage Tool
+	s.AddTool(
+		mcp.NewTool("check_model_coverage",
+			mcp.WithDescription("Report which of a mental model's defined steps an application addressed and its coverage percentage"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+			mcp.WithString("model_id", mcp.Required(), mcp.Description("ID of the mental model application, as returned by mental_model")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+			modelID, _ := req.RequireString("model_id")
+
+			application, err := store.GetMentalModel(sessionID, modelID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to check model coverage: %v", err)), nil
+			}
+
+			availableModels, err := modelsLoader.Models()
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
+			}
+			_, model, exists := modelsLoader.Lookup(availableModels, application.ModelName)
+			if !exists {
+				return mcp.NewToolResultError(fmt.Sprintf("Mental model '%s' is no longer defined", application.ModelName)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"session_id": sessionID,
+				"coverage":   models.CheckCoverage(model, application),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Session Category Breakdown Tool
+	s.AddTool(
+		mcp.NewTool("session_category_breakdown",
+			mcp.WithDescription("Count a session's mental model applications per model category, to see which kinds of thinking dominated"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("Session identifier")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID, _ := req.RequireString("session_id")
+
+			applications, err := store.GetMentalModels(sessionID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get mental models: %v", err)), nil
+			}
+			availableModels, err := modelsLoader.Models()
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":       "success",
+				"session_id":   sessionID,
+				"categories":   modelsLoader.CategoryBreakdown(availableModels, applications),
+				"applications": len(applications),
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// Get Mental Model Tool
+	s.AddTool(
+		mcp.NewTool("get_mental_model",
+			mcp.WithDescription("Get a mental model's definition, including whether it came from core or a custom file"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("model_name", mcp.Required(), mcp.Description("Key of the mental model")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			modelName, _ := req.RequireString("model_name")
+
+			availableModels, err := modelsLoader.Models()
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
+			}
+
+			modelName, model, exists := modelsLoader.Lookup(availableModels, modelName)
+			if !exists {
+				return mcp.NewToolResultError(fmt.Sprintf("Mental model '%s' not found", modelName)), nil
+			}
+
+			response := map[string]interface{}{
+				"status":     "success",
+				"model_name": modelName,
+				"model":      model,
+				"source":     model.Source,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+
+	// List Available Mental Models Tool
+	s.AddTool(
+		mcp.NewTool("list_mental_models",
+			mcp.WithDescription("List all available mental models with their details"),
+			mcp.WithReadOnlyHintAnnotation(true),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			// Load available mental models
+			availableModels, err := modelsLoader.Models()
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to load mental models: %v", err)), nil
+			}
+
+			// Get models sorted by priority
+			modelsByPriority := modelsLoader.GetModelsByPriority(availableModels)
+			modelsByCategory := modelsLoader.GetSortedCategories(availableModels)
+
+			// Tell clients whether a small library is by design or a failed load
+			customPath := modelsLoader.Path()
+			coreModels, customModels := modelsLoader.CountBySource(availableModels)
+			library := map[string]interface{}{
+				"custom_path_configured": customPath != "",
+				"core_models":            coreModels,
+				"custom_models":          customModels,
+			}
+			switch {
+			case customPath == "":
+				library["hint"] = "Only the built-in core models are loaded; set GOTHINK_MENTAL_MODELS_PATH to add custom models"
+			case customModels == 0:
+				library["custom_path"] = customPath
+				library["hint"] = fmt.Sprintf("No custom models were loaded from %s; check the server logs for load errors", customPath)
+			default:
+				library["custom_path"] = customPath
+			}
+
+			// Create response
+			response := map[string]interface{}{
+				"status":             "success",
+				"total_models":       len(availableModels),
+				"models_by_priority": modelsByPriority,
+				"models_by_category": modelsByCategory,
+				"available_models":   modelsLoader.GetAvailableModels(availableModels),
+				"library":            library,
+			}
+
+			result, _ := jsonstyle.Marshal(response, cfg.JSONFieldStyle)
+			return mcp.NewToolResultText(string(result)), nil
+		},
+	)
+}
+
+// handleSequentialThinking processes sequential thinking requests. Revision
+// and branch links must refer to thoughts already stored in the session.
+func handleSequentialThinking(store *storage.Storage, sessionID string, thoughtData *types.ThoughtData, fieldStyle string) (string, error) {
+	if thoughtData.RevisesThought != nil && !hasThoughtNumber(store, sessionID, *thoughtData.RevisesThought) {
+		return "", fmt.Errorf("revises_thought %d does not match any thought in session %s", *thoughtData.RevisesThought, sessionID)
+	}
+	if thoughtData.BranchFromThought != nil && !hasThoughtNumber(store, sessionID, *thoughtData.BranchFromThought) {
+		return "", fmt.Errorf("branch_from_thought %d does not match any thought in session %s", *thoughtData.BranchFromThought, sessionID)
+	}
+
+	// Store the thought
+	if err := store.AddThought(sessionID, thoughtData); err != nil {
+		return "", err
+	}
+
+	// Get session stats
+	stats, err := store.GetSessionStats(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	// Create response
+	response := map[string]interface{}{
+		"status":     "success",
+		"thought_id": thoughtData.ID,
+		"session_context": map[string]interface{}{
+			"session_id":         sessionID,
+			"total_thoughts":     stats.ThoughtCount,
+			"remaining_thoughts": stats.RemainingThoughts,
+		},
+	}
+	if thoughtData.Truncated {
+		response["truncated"] = true
+	}
+
+	result, err := jsonstyle.Marshal(response, fieldStyle)
+	if err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
+
+// hasThoughtNumber reports whether a session holds a thought with the number
+func hasThoughtNumber(store *storage.Storage, sessionID string, number int) bool {
+	return store.CountThoughts(sessionID, storage.ThoughtFilter{MinNumber: &number, MaxNumber: &number}) > 0
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rainmana/gothink/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName identifies spans created by GoThink
+	tracerName = "github.com/rainmana/gothink"
+	// serviceName is reported as the service.name resource attribute
+	serviceName = "gothink"
+)
+
+// Setup installs the W3C trace context propagator and, when tracing is
+// enabled, a global tracer provider exporting spans over OTLP/HTTP. The
+// returned function flushes and stops the exporter.
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.EnableTracing {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var options []otlptracehttp.Option
+	if cfg.OTLPEndpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// ToolMiddleware wraps every tool call in a span carrying the tool name and,
+// when present, the session ID. Spans are no-ops unless Setup enabled tracing.
+func ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			attributes := []attribute.KeyValue{attribute.String("mcp.tool.name", req.Params.Name)}
+			if sessionID := req.GetString("session_id", ""); sessionID != "" {
+				attributes = append(attributes, attribute.String("gothink.session_id", sessionID))
+			}
+
+			ctx, span := otel.Tracer(tracerName).Start(ctx, "tools/call "+req.Params.Name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attributes...),
+			)
+			defer span.End()
+
+			result, err := next(ctx, req)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			} else if result != nil && result.IsError {
+				span.SetStatus(codes.Error, "tool returned an error result")
+			}
+
+			return result, err
+		}
+	}
+}
+
+// ContextFromRequest extracts incoming trace context from HTTP headers so
+// tool spans join the caller's trace
+func ContextFromRequest(ctx context.Context, r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+}
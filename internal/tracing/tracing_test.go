@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useRecorder installs an in-memory span recorder as the global tracer provider
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func callTool(t *testing.T, s *server.MCPServer, name string, args map[string]interface{}) *mcp.CallToolResult {
+	t.Helper()
+
+	tool := s.GetTool(name)
+	require.NotNil(t, tool)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+
+	// Registered handlers are wrapped by the server, so apply the middleware directly
+	result, err := ToolMiddleware()(tool.Handler)(context.Background(), req)
+	require.NoError(t, err)
+	return result
+}
+
+func TestToolMiddleware_SpanPerToolCall(t *testing.T) {
+	recorder := useRecorder(t)
+
+	s := server.NewMCPServer("Test", "1.0.0")
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	s.AddTool(mcp.NewTool("fail"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("boom"), nil
+	})
+
+	callTool(t, s, "echo", map[string]interface{}{"session_id": "traced-session"})
+	callTool(t, s, "echo", map[string]interface{}{})
+	callTool(t, s, "fail", map[string]interface{}{"session_id": "traced-session"})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "tools/call echo", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("mcp.tool.name", "echo"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("gothink.session_id", "traced-session"))
+
+	assert.Equal(t, []attribute.KeyValue{attribute.String("mcp.tool.name", "echo")}, spans[1].Attributes())
+
+	assert.Equal(t, "tools/call fail", spans[2].Name())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
+
+func TestContextFromRequest_PropagatesTraceContext(t *testing.T) {
+	recorder := useRecorder(t)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := httptest.NewRequest("POST", "/message", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	handler := ToolMiddleware()(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	_, err := handler(ContextFromRequest(context.Background(), r), mcp.CallToolRequest{})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
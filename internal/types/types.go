@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
 
 // ============================================================================
 // Core Thinking Types
@@ -8,29 +14,191 @@ import "time"
 
 // ThoughtData represents a single thought in a sequential thinking process
 type ThoughtData struct {
-	ID                string    `json:"id"`
-	Thought           string    `json:"thought"`
-	ThoughtNumber     int       `json:"thought_number"`
-	TotalThoughts     int       `json:"total_thoughts"`
-	IsRevision        bool      `json:"is_revision,omitempty"`
-	RevisesThought    *int      `json:"revises_thought,omitempty"`
-	BranchFromThought *int      `json:"branch_from_thought,omitempty"`
-	BranchID          string    `json:"branch_id,omitempty"`
-	NeedsMoreThoughts bool      `json:"needs_more_thoughts,omitempty"`
-	NextThoughtNeeded bool      `json:"next_thought_needed"`
-	CreatedAt         time.Time `json:"created_at"`
-}
-
-// MentalModelData represents the application of a mental model to a problem
+	ID                string      `json:"id"`
+	SessionID         string      `json:"session_id,omitempty"`
+	Thought           string      `json:"thought"`
+	ThoughtNumber     int         `json:"thought_number"`
+	TotalThoughts     int         `json:"total_thoughts"`
+	IsRevision        bool        `json:"is_revision,omitempty"`
+	RevisesThought    *int        `json:"revises_thought,omitempty"`
+	BranchFromThought *int        `json:"branch_from_thought,omitempty"`
+	BranchID          string      `json:"branch_id,omitempty"`
+	NeedsMoreThoughts bool        `json:"needs_more_thoughts,omitempty"`
+	NextThoughtNeeded bool        `json:"next_thought_needed"`
+	References        []Reference `json:"references,omitempty"`
+	Tags              []string    `json:"tags,omitempty"`
+	// Truncated marks a thought cut to the configured maximum length
+	Truncated bool      `json:"truncated,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Reference types a thought can be annotated with
+const (
+	ReferenceURL    = "url"
+	ReferenceCommit = "commit"
+	ReferenceDoc    = "doc"
+	ReferenceIssue  = "issue"
+)
+
+// commitPattern matches abbreviated and full git commit hashes
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// Reference links a thought to something outside the session, such as a
+// commit, document or URL
+type Reference struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Validate checks the reference type and that the value suits it: URLs must
+// be absolute http(s) URLs and commits hexadecimal hashes
+func (r Reference) Validate() error {
+	if strings.TrimSpace(r.Value) == "" {
+		return fmt.Errorf("%s reference value must not be empty", r.Type)
+	}
+	switch r.Type {
+	case ReferenceURL:
+		parsed, err := url.ParseRequestURI(r.Value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid url reference %q: expected an absolute http or https URL", r.Value)
+		}
+	case ReferenceCommit:
+		if !commitPattern.MatchString(r.Value) {
+			return fmt.Errorf("invalid commit reference %q: expected a hexadecimal hash of 7 to 64 characters", r.Value)
+		}
+	case ReferenceDoc, ReferenceIssue:
+	default:
+		return fmt.Errorf("unknown reference type %q (expected %s, %s, %s or %s)", r.Type, ReferenceURL, ReferenceCommit, ReferenceDoc, ReferenceIssue)
+	}
+	return nil
+}
+
+// MentalModelData represents the application of a mental model to a problem;
+// Confidence is always stored as a fraction between 0 and 1
 type MentalModelData struct {
-	ID         string    `json:"id"`
-	ModelName  string    `json:"model_name"`
-	Problem    string    `json:"problem"`
-	Steps      []string  `json:"steps"`
-	Reasoning  string    `json:"reasoning"`
-	Conclusion string    `json:"conclusion"`
-	Confidence float64   `json:"confidence,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID         string   `json:"id"`
+	SessionID  string   `json:"session_id,omitempty"`
+	ModelName  string   `json:"model_name"`
+	Problem    string   `json:"problem"`
+	Steps      []string `json:"steps"`
+	Reasoning  string   `json:"reasoning"`
+	Conclusion string   `json:"conclusion"`
+	Confidence float64  `json:"confidence,omitempty"`
+	// FeedbackLoops are the loops between components recorded by a systems
+	// thinking application, whose Steps list the components
+	FeedbackLoops []FeedbackLoop `json:"feedback_loops,omitempty"`
+	// ScientificMethod is the cycle recorded by a scientific method
+	// application, whose Problem and Conclusion repeat its hypothesis and
+	// conclusion
+	ScientificMethod *ScientificMethodData `json:"scientific_method,omitempty"`
+	// Personas and Contributions are recorded by a collaborative reasoning
+	// application, whose Problem holds the topic debated
+	Personas      []Persona      `json:"personas,omitempty"`
+	Contributions []Contribution `json:"contributions,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// Feedback loop polarities: a positive loop reinforces change, a negative
+// one balances it
+const (
+	PolarityPositive = "positive"
+	PolarityNegative = "negative"
+)
+
+// FeedbackLoop is an influence of one system component on another
+type FeedbackLoop struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Polarity string `json:"polarity"`
+}
+
+// CollaborativeReasoningModelName is the model name of mental model
+// applications that record a debate between personas
+const CollaborativeReasoningModelName = "collaborative_reasoning"
+
+// Persona is a participant in a collaborative reasoning debate
+type Persona struct {
+	Name   string `json:"name"`
+	Stance string `json:"stance"`
+}
+
+// Contribution is a statement made by a persona during a debate
+type Contribution struct {
+	Persona   string `json:"persona"`
+	Statement string `json:"statement"`
+}
+
+// ScientificMethodModelName is the model name of mental model applications
+// that record a scientific method cycle
+const ScientificMethodModelName = "scientific_method"
+
+// ScientificMethodData is a scientific method cycle: a hypothesis, the
+// experiments testing it, and what was observed and concluded
+type ScientificMethodData struct {
+	Hypothesis  string       `json:"hypothesis"`
+	Experiments []Experiment `json:"experiments,omitempty"`
+	Observation string       `json:"observation,omitempty"`
+	Conclusion  string       `json:"conclusion,omitempty"`
+}
+
+// Experiment is a test of a hypothesis and the result it should produce if
+// the hypothesis holds
+type Experiment struct {
+	Description    string `json:"description"`
+	ExpectedResult string `json:"expected_result"`
+}
+
+// DebuggingApproachPrefix marks mental model applications that record a
+// debugging approach: the model name is the prefix followed by the approach
+// name, Problem holds the issue, Reasoning the findings and Conclusion the
+// resolution
+const DebuggingApproachPrefix = "debugging_"
+
+// SocraticModelName is the model name of mental model applications that
+// record a Socratic dialogue: Problem holds the claim examined and each step
+// one exchange, formatted by SocraticExchange
+const SocraticModelName = "socratic"
+
+// SocraticExchange formats a question and its answer, if any, as a step of
+// a Socratic dialogue
+func SocraticExchange(question, answer string) string {
+	if answer == "" {
+		return "Q: " + question
+	}
+	return "Q: " + question + "\nA: " + answer
+}
+
+// CreativeThinkingPrefix marks mental model applications that record a
+// creative thinking exercise: the model name is the prefix followed by the
+// technique, Problem holds the prompt and Steps the idea slots
+const CreativeThinkingPrefix = "creative_"
+
+// CreativeTechniques lists the prompts of the idea slots each creative
+// thinking technique asks the caller to fill
+var CreativeTechniques = map[string][]string{
+	"scamper": {
+		"Substitute: what could be replaced?",
+		"Combine: what could be merged?",
+		"Adapt: what could be borrowed from elsewhere?",
+		"Modify: what could be enlarged, shrunk or reshaped?",
+		"Put to another use: where else could this apply?",
+		"Eliminate: what could be removed?",
+		"Reverse: what could be reordered or inverted?",
+	},
+	"brainstorm": {
+		"The obvious idea",
+		"An idea with no budget or time limit",
+		"An idea that breaks a rule of the domain",
+		"An idea combining two earlier ideas",
+		"The idea nobody would propose",
+	},
+	"lateral": {
+		"Challenge an assumption the prompt takes for granted",
+		"Provocation: state something deliberately unreasonable and follow it",
+		"Random entry: connect the prompt to an unrelated object",
+		"Reverse the problem",
+		"Extract the concept and find another way to achieve it",
+	},
 }
 
 // ============================================================================
@@ -61,6 +229,12 @@ type ProcessResult struct {
 	} `json:"error,omitempty"`
 }
 
+// Session lifecycle states
+const (
+	SessionStateActive = "active"
+	SessionStateIdle   = "idle"
+)
+
 // SessionStatistics represents comprehensive session statistics
 type SessionStatistics struct {
 	SessionID         string                 `json:"session_id"`
@@ -70,8 +244,287 @@ type SessionStatistics struct {
 	ToolsUsed         []string               `json:"tools_used"`
 	TotalOperations   int                    `json:"total_operations"`
 	IsActive          bool                   `json:"is_active"`
+	State             string                 `json:"state"`
+	Archived          bool                   `json:"archived"`
 	RemainingThoughts int                    `json:"remaining_thoughts"`
+	RevisionCount     int                    `json:"revision_count"`
+	RevisionRatio     float64                `json:"revision_ratio"`
 	Stores            map[string]interface{} `json:"stores"`
+	Verdict           *SessionVerdict        `json:"verdict,omitempty"`
+}
+
+// SessionVerdict is the overall conclusion a client reached for a session
+type SessionVerdict struct {
+	Conclusion string `json:"conclusion"`
+	// Confidence is stored as a fraction between 0 and 1
+	Confidence float64   `json:"confidence"`
+	NextAction string    `json:"next_action,omitempty"`
+	SetAt      time.Time `json:"set_at"`
+}
+
+// GlobalStatistics represents server-wide statistics across all sessions
+type GlobalStatistics struct {
+	TotalSessions            int                `json:"total_sessions"`
+	ActiveSessions           int                `json:"active_sessions"`
+	TotalThoughts            int                `json:"total_thoughts"`
+	TotalMentalModels        int                `json:"total_mental_models"`
+	ThoughtsPerMinute        float64            `json:"thoughts_per_minute"`
+	SessionThoughtsPerMinute map[string]float64 `json:"session_thoughts_per_minute"`
+	// LockContention counts session lock acquisitions that had to wait
+	LockContention uint64 `json:"lock_contention"`
+}
+
+// ModelUsage summarizes the applications of one mental model within a session
+type ModelUsage struct {
+	ModelName string   `json:"model_name"`
+	Count     int      `json:"count"`
+	Problems  []string `json:"problems"`
+}
+
+// SessionProblem is a distinct problem explored in a session
+type SessionProblem struct {
+	Problem    string   `json:"problem"`
+	ModelCount int      `json:"model_count"`
+	Models     []string `json:"models"`
+}
+
+const (
+	// DecisionKindMentalModel marks a decision taken from a mental model conclusion
+	DecisionKindMentalModel = "mental_model"
+	// DecisionKindDebugging marks the resolution of a debugging approach
+	DecisionKindDebugging = "debugging"
+)
+
+// Decision is a conclusion reached in a session, without the reasoning behind it
+type Decision struct {
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	Problem    string    `json:"problem"`
+	Conclusion string    `json:"conclusion"`
+	Confidence float64   `json:"confidence,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Scales clients may declare for confidence values
+const (
+	// ConfidenceScaleFraction reads confidence between 0 and 1
+	ConfidenceScaleFraction = "fraction"
+	// ConfidenceScalePercent reads confidence between 0 and 100
+	ConfidenceScalePercent = "percent"
+)
+
+// confidenceScaleMax maps each confidence scale to its upper bound
+var confidenceScaleMax = map[string]float64{
+	ConfidenceScaleFraction: 1,
+	ConfidenceScalePercent:  100,
+}
+
+// ValidateConfidenceScale checks a confidence scale name
+func ValidateConfidenceScale(scale string) error {
+	if _, ok := confidenceScaleMax[scale]; !ok {
+		return fmt.Errorf("unknown confidence scale %q (expected %q or %q)", scale, ConfidenceScaleFraction, ConfidenceScalePercent)
+	}
+	return nil
+}
+
+// NormalizeConfidence converts a confidence given in scale to the 0-1
+// fraction stored internally, rejecting values outside the scale
+func NormalizeConfidence(value float64, scale string) (float64, error) {
+	if err := ValidateConfidenceScale(scale); err != nil {
+		return 0, err
+	}
+	upper := confidenceScaleMax[scale]
+	if value < 0 || value > upper {
+		return 0, fmt.Errorf("confidence %g is outside the %s scale (0-%g)", value, scale, upper)
+	}
+	return value / upper, nil
+}
+
+// ScaleConfidence converts a stored 0-1 confidence back to scale
+func ScaleConfidence(value float64, scale string) float64 {
+	if upper, ok := confidenceScaleMax[scale]; ok {
+		return value * upper
+	}
+	return value
+}
+
+// ModelApplication is one application of a mental model, located by session
+type ModelApplication struct {
+	SessionID  string    `json:"session_id"`
+	ModelID    string    `json:"model_id"`
+	ModelName  string    `json:"model_name"`
+	Problem    string    `json:"problem"`
+	Conclusion string    `json:"conclusion,omitempty"`
+	Confidence float64   `json:"confidence,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ThoughtCluster is a group of near-duplicate thoughts within a session
+type ThoughtCluster struct {
+	Thoughts      []*ThoughtData `json:"thoughts"`
+	MaxSimilarity float64        `json:"max_similarity"`
+}
+
+// StepCoverage reports whether an application addressed one defined step of
+// its mental model and where the evidence was found
+type StepCoverage struct {
+	Number    int    `json:"number"`
+	Step      string `json:"step"`
+	Addressed bool   `json:"addressed"`
+	Evidence  string `json:"evidence,omitempty"`
+}
+
+// ModelCoverage summarizes how many of a mental model's defined steps an
+// application addressed
+type ModelCoverage struct {
+	ModelID         string         `json:"model_id"`
+	ModelName       string         `json:"model_name"`
+	Steps           []StepCoverage `json:"steps"`
+	Addressed       int            `json:"addressed"`
+	Unaddressed     int            `json:"unaddressed"`
+	CoveragePercent float64        `json:"coverage_percent"`
+}
+
+// Checkpoint describes a named snapshot of a session
+type Checkpoint struct {
+	Name             string    `json:"name"`
+	CreatedAt        time.Time `json:"created_at"`
+	ThoughtCount     int       `json:"thought_count"`
+	MentalModelCount int       `json:"mental_model_count"`
+}
+
+// PurgeResult reports the sessions removed by a purge and the memory reclaimed
+type PurgeResult struct {
+	Purged         []string `json:"purged"`
+	ItemsRemoved   int      `json:"items_removed"`
+	ReclaimedBytes int      `json:"reclaimed_bytes"`
+}
+
+// AuditEntry records a tool call made against a session
+type AuditEntry struct {
+	Tool string    `json:"tool"`
+	At   time.Time `json:"at"`
+}
+
+// Timeline event kinds
+const (
+	TimelineSessionCreated = "session_created"
+	TimelineThought        = "thought"
+	TimelineMentalModel    = "mental_model"
+	TimelineToolCall       = "tool_call"
+)
+
+// TimelineEvent is one entry of a session's history; the field matching
+// Kind carries the entity
+type TimelineEvent struct {
+	Kind        string           `json:"kind"`
+	At          time.Time        `json:"at"`
+	Thought     *ThoughtData     `json:"thought,omitempty"`
+	MentalModel *MentalModelData `json:"mental_model,omitempty"`
+	Tool        string           `json:"tool,omitempty"`
+}
+
+// SessionDuration reports how long a session's reasoning took, measured
+// from thought creation times. Gaps between consecutive thoughts longer than
+// the session timeout count as idle and are left out of ActiveSeconds.
+type SessionDuration struct {
+	SessionID              string     `json:"session_id"`
+	ThoughtCount           int        `json:"thought_count"`
+	FirstThoughtAt         *time.Time `json:"first_thought_at,omitempty"`
+	LastThoughtAt          *time.Time `json:"last_thought_at,omitempty"`
+	WallClockSeconds       float64    `json:"wall_clock_seconds"`
+	AverageIntervalSeconds float64    `json:"average_interval_seconds"`
+	ActiveSeconds          float64    `json:"active_seconds"`
+}
+
+// ResolvedThought is the current version of one thought: the latest
+// revision reachable through revision links from the original, or the
+// original itself when it was never revised
+type ResolvedThought struct {
+	// ThoughtNumber is the number of the original thought
+	ThoughtNumber int `json:"thought_number"`
+	// Superseded lists the numbers of the versions replaced by Thought
+	Superseded []int        `json:"superseded"`
+	Thought    *ThoughtData `json:"thought"`
+}
+
+// SessionDiff represents the differences between two sessions' reasoning
+type SessionDiff struct {
+	SessionA        string             `json:"session_a"`
+	SessionB        string             `json:"session_b"`
+	Identical       bool               `json:"identical"`
+	DivergencePoint *int               `json:"divergence_point"`
+	ThoughtsOnlyInA []*ThoughtData     `json:"thoughts_only_in_a"`
+	ThoughtsOnlyInB []*ThoughtData     `json:"thoughts_only_in_b"`
+	ModelsOnlyInA   []*MentalModelData `json:"models_only_in_a"`
+	ModelsOnlyInB   []*MentalModelData `json:"models_only_in_b"`
+}
+
+// Session integrity violation kinds
+const (
+	ViolationNumberingGap     = "numbering_gap"
+	ViolationDuplicateNumber  = "duplicate_number"
+	ViolationNumberBelowBase  = "number_below_base"
+	ViolationDanglingRevision = "dangling_revision"
+	ViolationDanglingBranch   = "dangling_branch"
+	ViolationMissingEntity    = "missing_entity"
+	ViolationCountMismatch    = "count_mismatch"
+)
+
+// IntegrityViolation describes one broken invariant in a session
+type IntegrityViolation struct {
+	Kind          string `json:"kind"`
+	Message       string `json:"message"`
+	ThoughtID     string `json:"thought_id,omitempty"`
+	ThoughtNumber int    `json:"thought_number,omitempty"`
+}
+
+// SessionVerification represents the result of checking a session's invariants
+type SessionVerification struct {
+	SessionID  string               `json:"session_id"`
+	Valid      bool                 `json:"valid"`
+	Violations []IntegrityViolation `json:"violations"`
+}
+
+// Session repair change kinds
+const (
+	RepairRenumbered        = "renumbered"
+	RepairDroppedRevision   = "dropped_revision"
+	RepairDroppedBranch     = "dropped_branch"
+	RepairDroppedIndexEntry = "dropped_index_entry"
+	RepairReconciledCount   = "reconciled_count"
+)
+
+// RepairChange describes one fix applied (or planned) by a session repair
+type RepairChange struct {
+	Kind      string `json:"kind"`
+	Message   string `json:"message"`
+	ThoughtID string `json:"thought_id,omitempty"`
+}
+
+// SessionRepair represents the result of repairing a session
+type SessionRepair struct {
+	SessionID string         `json:"session_id"`
+	DryRun    bool           `json:"dry_run"`
+	Changes   []RepairChange `json:"changes"`
+}
+
+// StatCorrection records a stored session counter that disagreed with the
+// session's entities and the value it was corrected to
+type StatCorrection struct {
+	Field  string `json:"field"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+// StatsRecomputation represents the counters recomputed for a session
+type StatsRecomputation struct {
+	SessionID         string           `json:"session_id"`
+	ThoughtCount      int              `json:"thought_count"`
+	RemainingThoughts int              `json:"remaining_thoughts"`
+	TotalOperations   int              `json:"total_operations"`
+	Stores            map[string]int   `json:"stores"`
+	Corrections       []StatCorrection `json:"corrections"`
 }
 
 // ============================================================================
@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// EventHeader names the event kind of a delivery
+	EventHeader = "X-GoThink-Event"
+	// SignatureHeader carries "sha256=" followed by the hex HMAC of the body
+	SignatureHeader = "X-GoThink-Signature"
+
+	// queueSize is how many events may await delivery before new ones are dropped
+	queueSize = 256
+	// requestTimeout bounds a single delivery attempt
+	requestTimeout = 10 * time.Second
+)
+
+// DefaultEvents are delivered when no events are configured
+var DefaultEvents = []storage.EventKind{
+	storage.EventSessionCreated,
+	storage.EventThoughtLimitReached,
+	storage.EventSessionArchived,
+}
+
+// Dispatcher posts selected storage events to a webhook URL, retrying
+// failed deliveries with exponential backoff
+type Dispatcher struct {
+	url         string
+	secret      string
+	events      map[storage.EventKind]bool
+	maxAttempts int
+	backoff     time.Duration
+
+	client *http.Client
+	logger *logrus.Logger
+
+	queue  chan storage.Event
+	cancel func()
+	ctx    context.Context
+	stop   context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// New subscribes to every session's events and starts delivering the
+// configured ones to cfg.WebhookURL until Close is called
+func New(cfg *config.Config, store *storage.Storage, logger *logrus.Logger) *Dispatcher {
+	events := make(map[storage.EventKind]bool)
+	for _, event := range cfg.WebhookEvents {
+		events[storage.EventKind(event)] = true
+	}
+	if len(events) == 0 {
+		for _, event := range DefaultEvents {
+			events[event] = true
+		}
+	}
+
+	maxAttempts := cfg.WebhookMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	d := &Dispatcher{
+		url:         cfg.WebhookURL,
+		secret:      cfg.WebhookSecret,
+		events:      events,
+		maxAttempts: maxAttempts,
+		backoff:     cfg.WebhookRetryBackoff,
+		client:      &http.Client{Timeout: requestTimeout},
+		logger:      logger,
+		queue:       make(chan storage.Event, queueSize),
+	}
+	d.ctx, d.stop = context.WithCancel(context.Background())
+
+	subscription, cancel := store.Subscribe("")
+	d.cancel = cancel
+
+	d.done.Add(2)
+	go d.enqueue(subscription)
+	go d.deliverQueued()
+
+	return d
+}
+
+// Close stops accepting events, abandons pending retries and waits for the
+// workers to exit
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.stop()
+	d.done.Wait()
+}
+
+// enqueue forwards selected events to the delivery queue without blocking
+// storage, dropping events when the queue is full
+func (d *Dispatcher) enqueue(subscription <-chan storage.Event) {
+	defer d.done.Done()
+	defer close(d.queue)
+
+	for event := range subscription {
+		if !d.events[event.Kind] {
+			continue
+		}
+		select {
+		case d.queue <- event:
+		default:
+			d.logger.WithFields(logrus.Fields{
+				"event":      event.Kind,
+				"session_id": event.SessionID,
+			}).Warn("Webhook queue full, dropping event")
+		}
+	}
+}
+
+// deliverQueued delivers queued events one at a time, in order
+func (d *Dispatcher) deliverQueued() {
+	defer d.done.Done()
+
+	for event := range d.queue {
+		if d.ctx.Err() != nil {
+			continue
+		}
+		if err := d.deliver(event); err != nil {
+			d.logger.WithFields(logrus.Fields{
+				"event":      event.Kind,
+				"session_id": event.SessionID,
+			}).Errorf("Webhook delivery failed: %v", err)
+		}
+	}
+}
+
+// deliver posts one event, retrying until it is accepted or attempts run out
+func (d *Dispatcher) deliver(event storage.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		err = d.post(event.Kind, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= d.maxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		d.logger.WithFields(logrus.Fields{
+			"event":   event.Kind,
+			"attempt": attempt,
+		}).Debugf("Retrying webhook delivery: %v", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+			return fmt.Errorf("shut down before delivery: %w", err)
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single delivery attempt
+func (d *Dispatcher) post(kind storage.EventKind, body []byte) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(kind))
+	if d.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for a payload: "sha256=" followed
+// by the hex-encoded HMAC-SHA256 of the body keyed with the secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
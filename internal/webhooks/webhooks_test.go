@@ -0,0 +1,89 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rainmana/gothink/internal/config"
+	"github.com/rainmana/gothink/internal/storage"
+	"github.com/rainmana/gothink/internal/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// delivery is one request seen by the test receiver
+type delivery struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+func TestDispatcher_DeliversSignedThoughtLimitEvent(t *testing.T) {
+	var attempts int32
+	deliveries := make(chan delivery, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// Fail the first attempt to exercise the retry
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		deliveries <- delivery{event: r.Header.Get(EventHeader), signature: r.Header.Get(SignatureHeader), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.MaxThoughtsPerSession = 2
+	cfg.WebhookURL = receiver.URL
+	cfg.WebhookEvents = []string{string(storage.EventThoughtLimitReached)}
+	cfg.WebhookSecret = "shh"
+	cfg.WebhookRetryBackoff = 10 * time.Millisecond
+
+	store, err := storage.New(cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	dispatcher := New(cfg, store, logrus.New())
+	defer dispatcher.Close()
+
+	for n := 1; n <= 2; n++ {
+		require.NoError(t, store.AddThought("capped", &types.ThoughtData{Thought: "step", ThoughtNumber: n}))
+	}
+
+	var got delivery
+	select {
+	case got = <-deliveries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	assert.Equal(t, string(storage.EventThoughtLimitReached), got.event)
+	assert.Equal(t, Sign("shh", got.body), got.signature)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	var payload storage.Event
+	require.NoError(t, json.Unmarshal(got.body, &payload))
+	assert.Equal(t, storage.EventThoughtLimitReached, payload.Kind)
+	assert.Equal(t, "capped", payload.SessionID)
+	require.NotNil(t, payload.Thought)
+	assert.Equal(t, 2, payload.Thought.ThoughtNumber)
+
+	// Unselected events such as session_created are not delivered
+	select {
+	case extra := <-deliveries:
+		t.Fatalf("unexpected delivery of %s", extra.event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Reference value from: printf '{"kind":"x"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=fffdaa92f2a4c88115aa67e0dada01fca7f5487c4b7db268b6a714bfaf4cf4a6", Sign("secret", []byte(`{"kind":"x"}`)))
+}